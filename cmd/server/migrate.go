@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/config"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/dbstorage/migrations"
+)
+
+// runMigrate выполняет подкоманду migrate: server migrate <command> [version]
+func runMigrate(ctx context.Context, cfg *config.ClientFlags, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: server migrate <command>, commands: %s", migrations.Commands)
+	}
+	if cfg.DBDSN == "" {
+		return errors.New("migrate requires DBDSN")
+	}
+
+	db, err := sql.Open("postgres", cfg.DBDSN)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return migrations.Run(ctx, db, os.Stdout, args[0], args[1:]...)
+}
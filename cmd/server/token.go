@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/auth"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/config"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/dbstorage"
+)
+
+const tokenUsage = "usage: server token add <scope,...> | list | delete <id>"
+
+// tokenStore выбирает хранилище токенов доступа. nil означает, что аутентификация отключена
+func tokenStore(cfg *config.ClientFlags, database *dbstorage.PostgresStorage) (auth.Store, error) {
+	switch {
+	case cfg.AuthTokensDB:
+		if database == nil {
+			return nil, errors.New("auth-tokens-db requires DBDSN")
+		}
+		return database, nil
+	case cfg.AuthTokensFile != "":
+		return auth.NewFileStore(cfg.AuthTokensFile)
+	default:
+		return nil, nil
+	}
+}
+
+// runToken выполняет подкоманду token: выпуск, просмотр и отзыв токенов
+func runToken(ctx context.Context, store auth.Store, out io.Writer, args []string) error {
+	if store == nil {
+		return errors.New("token requires auth-tokens-file or auth-tokens-db")
+	}
+	if len(args) == 0 {
+		return errors.New(tokenUsage)
+	}
+
+	switch {
+	case args[0] == "add" && len(args) == 2:
+		scopes, err := auth.ParseScopes(strings.Split(args[1], ","))
+		if err != nil {
+			return err
+		}
+		raw, token, err := auth.NewToken(scopes)
+		if err != nil {
+			return err
+		}
+		if err := store.AddToken(ctx, token); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "id: %s\ntoken: %s\n", token.ID, raw)
+	case args[0] == "list" && len(args) == 1:
+		tokens, err := store.ListTokens(ctx)
+		if err != nil {
+			return err
+		}
+		for _, token := range tokens {
+			fmt.Fprintf(out, "%s\t%s\t%s\n", token.ID, token.CreatedAt.Format("2006-01-02 15:04:05"), joinScopes(token.Scopes))
+		}
+	case args[0] == "delete" && len(args) == 2:
+		return store.DeleteToken(ctx, args[1])
+	default:
+		return errors.New(tokenUsage)
+	}
+
+	return nil
+}
+
+func joinScopes(scopes []auth.Scope) string {
+	values := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		values = append(values, string(scope))
+	}
+	return strings.Join(values, ",")
+}
@@ -3,6 +3,7 @@ package app
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"os/signal"
 	"sync"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/romanmendelproject/go-yandex-metrics/internal/agent/config"
 	"github.com/romanmendelproject/go-yandex-metrics/internal/agent/metrics"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/agent/pull"
 	"github.com/romanmendelproject/go-yandex-metrics/internal/agent/report"
 
 	log "github.com/sirupsen/logrus"
@@ -24,6 +26,59 @@ func RunWorkers(ctx context.Context, cfg *config.ClientFlags, wg *sync.WaitGroup
 	}
 }
 
+// StartCollectors запускает включенные в конфигурации сборщики метрик,
+// каждый со своим интервалом опроса
+func StartCollectors(ctx context.Context, cfg *config.ClientFlags, wg *sync.WaitGroup, metricsChannel chan *[]metrics.Metric) error {
+	known := make(map[string]bool)
+	for _, r := range metrics.Registered() {
+		known[r.Name] = true
+	}
+	for _, name := range cfg.EnabledCollectors {
+		if !known[name] {
+			return fmt.Errorf("unknown collector %q", name)
+		}
+	}
+	for name := range cfg.Collectors {
+		if !known[name] {
+			return fmt.Errorf("unknown collector %q", name)
+		}
+	}
+
+	for _, r := range metrics.Registered() {
+		settings, enabled := cfg.Collector(r.Name, r.Enabled)
+		if !enabled {
+			continue
+		}
+		collector, err := r.New(settings.Options)
+		if err != nil {
+			return fmt.Errorf("collector %s: %w", r.Name, err)
+		}
+
+		log.Infof("Starting collector %s every %ds", r.Name, settings.Interval)
+		wg.Add(1)
+		go metrics.Run(ctx, wg, collector, time.Duration(settings.Interval)*time.Second, metricsChannel)
+	}
+	return nil
+}
+
+// StartPull запускает HTTP сервер режима pull и возвращает канал, в который сборщики
+// отправляют метрики. Метрики из него попадают в кэш сервера и, если включена отправка,
+// в metricsChannel
+func StartPull(ctx context.Context, cfg *config.ClientFlags, wg *sync.WaitGroup, metricsChannel chan *[]metrics.Metric) chan *[]metrics.Metric {
+	cache := pull.NewCache()
+	collected := make(chan *[]metrics.Metric, cap(metricsChannel))
+
+	var out chan<- *[]metrics.Metric
+	if cfg.Push {
+		out = metricsChannel
+	}
+
+	wg.Add(2)
+	go pull.Forward(ctx, wg, collected, cache, out)
+	go pull.Serve(ctx, wg, cfg.PullAddress, pull.Handler(cache, cfg.PullToken))
+	return collected
+}
+
 // StartAgent запускает программу-агента
 func StartAgent() {
 	cfg, err := config.ParseFlags()
@@ -36,34 +91,38 @@ func StartAgent() {
 		log.Fatalf(err.Error(), "event", "read config")
 	}
 
+	if err := cfg.LoadKeys(); err != nil {
+		log.Fatal(err)
+	}
+
 	termChan := make(chan os.Signal, 1)
 	signal.Notify(termChan, os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT)
 
-	metricsChannel := make(chan *[]metrics.Metric, 100)
-	var metr metrics.Metrics
+	if !cfg.Push && cfg.PullAddress == "" {
+		log.Fatal("Metrics are neither pushed nor served, set push or pull-address")
+	}
 
-	tickerPool := time.NewTicker(time.Duration(cfg.PollInterval) * time.Second)
+	metricsChannel := make(chan *[]metrics.Metric, 100)
 
 	wg := &sync.WaitGroup{}
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// RunWorkers(ctx, cfg, wg, metricsChannel, report.ReportBatchMetric)
-	RunWorkers(ctx, cfg, wg, metricsChannel, report.ReportBatchMetricProto)
-
-	wg.Add(1)
-	go func(ctx context.Context) {
-		defer wg.Done()
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-tickerPool.C:
-				go metr.Update(metricsChannel)
-				go metr.UpdateGopsUtil(metricsChannel)
-			}
-		}
-	}(ctx)
+	if cfg.Push {
+		// RunWorkers(ctx, cfg, wg, metricsChannel, report.ReportBatchMetric)
+		RunWorkers(ctx, cfg, wg, metricsChannel, report.ReportBatchMetricProto)
+	}
+
+	collected := metricsChannel
+	if cfg.PullAddress != "" {
+		collected = StartPull(ctx, cfg, wg, metricsChannel)
+	}
+
+	if err := StartCollectors(ctx, cfg, wg, collected); err != nil {
+		log.Fatal(err)
+	}
+
+	go reloadKeysOnHUP(ctx, cfg)
 
 	<-termChan
 	log.Info("Closing main program")
@@ -71,3 +130,23 @@ func StartAgent() {
 
 	wg.Wait()
 }
+
+// reloadKeysOnHUP перечитывает ключи по сигналу SIGHUP
+func reloadKeysOnHUP(ctx context.Context, cfg *config.ClientFlags) {
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	defer signal.Stop(hupChan)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hupChan:
+			if err := cfg.Keys().Reload(); err != nil {
+				log.Error("Failed to reload keys: ", err)
+				continue
+			}
+			log.Info("Keys reloaded")
+		}
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"encoding/json"
 	"os"
+	"slices"
 
 	"github.com/caarlos0/env/v8"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/crypto"
 	"github.com/spf13/pflag"
 )
 
@@ -18,6 +20,59 @@ type ClientFlags struct {
 	RateLimit            int    `env:"RATE_LIMIT" json:"rate_limit"`
 	CryptoKey            string `env:"CRYPTO_KEY" json:"crypto_key"`
 	Config               string `env:"CONFIG" json:"config"`
+	APIKey               string `env:"API_KEY" json:"api_key"`
+	Tenant               string `env:"TENANT" json:"tenant"`
+	Token                string `env:"TOKEN" json:"token"`
+	KeysFile             string `env:"KEYS_FILE" json:"keys_file"`
+	Push                 bool   `env:"PUSH" json:"push"`                 // отправлять метрики на сервер
+	PullAddress          string `env:"PULL_ADDRESS" json:"pull_address"` // адрес HTTP сервера режима pull
+	PullToken            string `env:"PULL_TOKEN" json:"pull_token"`     // токен для запросов режима pull
+
+	EnabledCollectors []string                   `env:"COLLECTORS" json:"enabled_collectors"`
+	Collectors        map[string]CollectorConfig `json:"collectors"`
+
+	keys *crypto.KeyRing
+}
+
+// CollectorConfig настройки сборщика метрик
+type CollectorConfig struct {
+	Enabled  *bool           `json:"enabled,omitempty"`  // по умолчанию решает список collectors или сам сборщик
+	Interval int             `json:"interval,omitempty"` // в секундах, по умолчанию poll_interval
+	Options  json.RawMessage `json:"options,omitempty"`  // настройки, специфичные для сборщика
+}
+
+// Collector возвращает настройки сборщика name и признак, включен ли он.
+// Явный enabled в настройках важнее списка collectors, а список — значения по умолчанию
+func (c *ClientFlags) Collector(name string, enabledByDefault bool) (CollectorConfig, bool) {
+	collector := c.Collectors[name]
+	if collector.Interval <= 0 {
+		collector.Interval = c.PollInterval
+	}
+
+	switch {
+	case collector.Enabled != nil:
+		return collector, *collector.Enabled
+	case len(c.EnabledCollectors) > 0:
+		return collector, slices.Contains(c.EnabledCollectors, name)
+	default:
+		return collector, enabledByDefault
+	}
+}
+
+// LoadKeys загружает ключи подписи и шифрования из файла keys-file или из флагов key и crypto-key
+func (c *ClientFlags) LoadKeys() error {
+	var err error
+	if c.KeysFile != "" {
+		c.keys, err = crypto.LoadKeyRing(c.KeysFile)
+	} else {
+		c.keys, err = crypto.NewKeyRing(c.Key, c.CryptoKey)
+	}
+	return err
+}
+
+// Keys возвращает ключи, загруженные LoadKeys
+func (c *ClientFlags) Keys() *crypto.KeyRing {
+	return c.keys
 }
 
 func ParseFlags() (*ClientFlags, error) {
@@ -34,6 +89,14 @@ func ParseFlags() (*ClientFlags, error) {
 	pflag.IntVarP(&flags.RateLimit, "rateLimit", "l", 2,
 		"Max count of parallel outbound requests to server")
 	pflag.StringVarP(&flags.CryptoKey, "crypto-key", "e", "./certs/public.pem", "Path to public key RSA to encrypt messages")
+	pflag.StringVar(&flags.APIKey, "api-key", "", "API key identifying the tenant on server")
+	pflag.StringVar(&flags.Tenant, "tenant", "", "Tenant (namespace) of reported metrics")
+	pflag.StringVar(&flags.Token, "token", "", "Bearer token with write scope")
+	pflag.StringSliceVar(&flags.EnabledCollectors, "collectors", nil, "Comma separated collectors to run instead of the default ones")
+	pflag.StringVar(&flags.KeysFile, "keys-file", "", "File with active HMAC and RSA keys, the first key of each kind is used")
+	pflag.BoolVar(&flags.Push, "push", true, "Send collected metrics to server")
+	pflag.StringVar(&flags.PullAddress, "pull-address", "", "Address to serve collected metrics over HTTP, empty to disable")
+	pflag.StringVar(&flags.PullToken, "pull-token", "", "Bearer token required to read metrics served over HTTP")
 
 	pflag.Parse()
 
@@ -78,3 +78,34 @@ func TestReadConfig_Success(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
 }
+
+func TestCollector(t *testing.T) {
+	enabled, disabled := true, false
+	cfg := &ClientFlags{
+		PollInterval: 2,
+		Collectors: map[string]CollectorConfig{
+			"runtime": {Interval: 10},
+			"system":  {Enabled: &disabled},
+			"disk":    {Enabled: &enabled},
+		},
+	}
+
+	settings, ok := cfg.Collector("runtime", true)
+	assert.True(t, ok)
+	assert.Equal(t, 10, settings.Interval)
+
+	settings, ok = cfg.Collector("system", true)
+	assert.False(t, ok, "explicitly disabled")
+	assert.Equal(t, 2, settings.Interval, "defaults to poll interval")
+
+	_, ok = cfg.Collector("disk", false)
+	assert.True(t, ok, "explicitly enabled")
+
+	cfg.EnabledCollectors = []string{"process"}
+	_, ok = cfg.Collector("runtime", true)
+	assert.False(t, ok, "not in collectors list")
+	_, ok = cfg.Collector("process", false)
+	assert.True(t, ok)
+	_, ok = cfg.Collector("disk", false)
+	assert.True(t, ok, "explicit setting wins over the list")
+}
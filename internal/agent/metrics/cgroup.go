@@ -0,0 +1,326 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	Register("cgroup", false, func(options json.RawMessage) (Collector, error) {
+		return NewCgroupCollector(options)
+	})
+}
+
+// cgroupUnlimited граница, начиная с которой лимит cgroup v1 считается неустановленным
+const cgroupUnlimited = 1 << 62
+
+// CgroupOptions настройки сборщика cgroup
+type CgroupOptions struct {
+	Root  string   `json:"root"`  // точка монтирования cgroupfs
+	Paths []string `json:"paths"` // пути cgroup относительно root, по умолчанию cgroup самого агента
+}
+
+// cgroupTarget файлы одной cgroup. Для cgroup v2 все контроллеры в одном каталоге
+type cgroupTarget struct {
+	label string
+	dirs  map[string]string // каталог по контроллеру cgroup v1, для v2 по ключу ""
+}
+
+// dir возвращает каталог контроллера
+func (t cgroupTarget) dir(controller string) (string, bool) {
+	if dir, ok := t.dirs[""]; ok {
+		return dir, true
+	}
+	dir, ok := t.dirs[controller]
+	return dir, ok
+}
+
+// CgroupCollector собирает потребление памяти, CPU, процессов и ввода-вывода cgroup
+// по файлам cgroup v1 или v2. Накопительные значения отправляются счетчиками
+type CgroupCollector struct {
+	options  CgroupOptions
+	procSelf string // файл с cgroup агента
+	last     *deltas
+}
+
+// NewCgroupCollector создает CgroupCollector
+func NewCgroupCollector(options json.RawMessage) (*CgroupCollector, error) {
+	c := &CgroupCollector{
+		options:  CgroupOptions{Root: "/sys/fs/cgroup"},
+		procSelf: "/proc/self/cgroup",
+		last:     newDeltas(),
+	}
+	if err := decodeOptions(options, &c.options); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Name возвращает имя сборщика
+func (c *CgroupCollector) Name() string {
+	return "cgroup"
+}
+
+// Collect возвращает показатели каждой cgroup. Файлы отключенных контроллеров пропускаются.
+// Метрики cgroup из настройки paths помечаются ее путем
+func (c *CgroupCollector) Collect(ctx context.Context) ([]Metric, error) {
+	targets, err := c.targets()
+	if err != nil {
+		return nil, err
+	}
+
+	var data []Metric
+	for _, t := range targets {
+		name := func(id string) string {
+			if t.label == "" {
+				return id
+			}
+			return labeled(id, t.label)
+		}
+		addGauge := func(id string, value uint64, ok bool) {
+			if ok {
+				data = append(data, gauge(name(id), float64(value)))
+			}
+		}
+		addCounter := func(id string, value uint64, ok bool) {
+			if !ok {
+				return
+			}
+			if delta, ok := c.last.delta(name(id), value); ok {
+				data = append(data, counter(name(id), delta))
+			}
+		}
+
+		if _, v2 := t.dirs[""]; v2 {
+			c.collectV2(t, addGauge, addCounter)
+		} else {
+			c.collectV1(t, addGauge, addCounter)
+		}
+	}
+	c.last.prune()
+	return data, nil
+}
+
+type addFunc func(id string, value uint64, ok bool)
+
+// collectV2 читает файлы cgroup v2
+func (c *CgroupCollector) collectV2(t cgroupTarget, addGauge, addCounter addFunc) {
+	dir := t.dirs[""]
+
+	addGauge(cgroupValue(filepath.Join(dir, "memory.current")))
+	addGauge(cgroupValue(filepath.Join(dir, "memory.max")))
+
+	cpu := cgroupKeyed(filepath.Join(dir, "cpu.stat"))
+	addCounter(cgroupField(cpu, "usage_usec"))
+	addCounter(cgroupField(cpu, "nr_periods"))
+	addCounter(cgroupField(cpu, "nr_throttled"))
+	addCounter(cgroupField(cpu, "throttled_usec"))
+
+	addGauge(cgroupValue(filepath.Join(dir, "pids.current")))
+	addGauge(cgroupValue(filepath.Join(dir, "pids.max")))
+
+	io, ok := cgroupIOStatV2(filepath.Join(dir, "io.stat"))
+	addCounter("CgroupIOReadBytes", io["rbytes"], ok)
+	addCounter("CgroupIOWriteBytes", io["wbytes"], ok)
+	addCounter("CgroupIOReadOps", io["rios"], ok)
+	addCounter("CgroupIOWriteOps", io["wios"], ok)
+}
+
+// collectV1 читает файлы контроллеров cgroup v1
+func (c *CgroupCollector) collectV1(t cgroupTarget, addGauge, addCounter addFunc) {
+	if dir, ok := t.dir("memory"); ok {
+		addGauge(cgroupValue(filepath.Join(dir, "memory.usage_in_bytes")))
+		addGauge(cgroupValue(filepath.Join(dir, "memory.limit_in_bytes")))
+	}
+	if dir, ok := t.dir("cpuacct"); ok {
+		usage, ok := cgroupUint(filepath.Join(dir, "cpuacct.usage"))
+		addCounter("CgroupCPUUsageMicros", usage/1000, ok)
+	}
+	if dir, ok := t.dir("cpu"); ok {
+		cpu := cgroupKeyed(filepath.Join(dir, "cpu.stat"))
+		addCounter(cgroupField(cpu, "nr_periods"))
+		addCounter(cgroupField(cpu, "nr_throttled"))
+		throttled, ok := cpu["throttled_time"]
+		addCounter("CgroupCPUThrottledMicros", throttled/1000, ok)
+	}
+	if dir, ok := t.dir("pids"); ok {
+		addGauge(cgroupValue(filepath.Join(dir, "pids.current")))
+		addGauge(cgroupValue(filepath.Join(dir, "pids.max")))
+	}
+	if dir, ok := t.dir("blkio"); ok {
+		bytes, ok := cgroupIOStatV1(filepath.Join(dir, "blkio.throttle.io_service_bytes"))
+		addCounter("CgroupIOReadBytes", bytes["Read"], ok)
+		addCounter("CgroupIOWriteBytes", bytes["Write"], ok)
+		ops, ok := cgroupIOStatV1(filepath.Join(dir, "blkio.throttle.io_serviced"))
+		addCounter("CgroupIOReadOps", ops["Read"], ok)
+		addCounter("CgroupIOWriteOps", ops["Write"], ok)
+	}
+}
+
+// cgroupMetrics имена метрик по файлам и полям cgroup
+var cgroupMetrics = map[string]string{
+	"memory.current":        "CgroupMemoryUsageBytes",
+	"memory.usage_in_bytes": "CgroupMemoryUsageBytes",
+	"memory.max":            "CgroupMemoryLimitBytes",
+	"memory.limit_in_bytes": "CgroupMemoryLimitBytes",
+	"pids.current":          "CgroupPids",
+	"pids.max":              "CgroupPidsLimit",
+	"usage_usec":            "CgroupCPUUsageMicros",
+	"nr_periods":            "CgroupCPUPeriods",
+	"nr_throttled":          "CgroupCPUThrottledPeriods",
+	"throttled_usec":        "CgroupCPUThrottledMicros",
+}
+
+// cgroupValue читает файл с одним значением и возвращает имя метрики для него
+func cgroupValue(file string) (string, uint64, bool) {
+	value, ok := cgroupUint(file)
+	return cgroupMetrics[filepath.Base(file)], value, ok
+}
+
+// cgroupField возвращает поле файла вида "ключ значение" и имя метрики для него
+func cgroupField(fields map[string]uint64, key string) (string, uint64, bool) {
+	value, ok := fields[key]
+	return cgroupMetrics[key], value, ok
+}
+
+// cgroupUint читает число из файла. Отсутствующий файл и лимит max считаются отсутствием значения
+func cgroupUint(file string) (uint64, bool) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return 0, false
+	}
+	value, err := strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+	if err != nil || value >= cgroupUnlimited {
+		return 0, false
+	}
+	return value, true
+}
+
+// cgroupKeyed читает файл из строк "ключ значение", например cpu.stat
+func cgroupKeyed(file string) map[string]uint64 {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+	fields := make(map[string]uint64)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+		if len(parts) != 2 {
+			continue
+		}
+		if value, err := strconv.ParseUint(parts[1], 10, 64); err == nil {
+			fields[parts[0]] = value
+		}
+	}
+	return fields
+}
+
+// cgroupIOStatV2 суммирует по устройствам io.stat вида "8:0 rbytes=1 wbytes=2 rios=3 wios=4"
+func cgroupIOStatV2(file string) (map[string]uint64, bool) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, false
+	}
+	total := make(map[string]uint64)
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		for _, field := range fields[min(1, len(fields)):] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				continue
+			}
+			if v, err := strconv.ParseUint(value, 10, 64); err == nil {
+				total[key] += v
+			}
+		}
+	}
+	return total, true
+}
+
+// cgroupIOStatV1 суммирует по устройствам файлы blkio вида "8:0 Read 123"
+func cgroupIOStatV1(file string) (map[string]uint64, bool) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, false
+	}
+	total := make(map[string]uint64)
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		if v, err := strconv.ParseUint(fields[2], 10, 64); err == nil {
+			total[fields[1]] += v
+		}
+	}
+	return total, true
+}
+
+// targets определяет каталоги cgroup: из настройки paths или по /proc/self/cgroup
+func (c *CgroupCollector) targets() ([]cgroupTarget, error) {
+	root := c.options.Root
+	_, err := os.Stat(filepath.Join(root, "cgroup.controllers"))
+	v2 := err == nil
+
+	if len(c.options.Paths) > 0 {
+		targets := make([]cgroupTarget, 0, len(c.options.Paths))
+		for _, p := range c.options.Paths {
+			t := cgroupTarget{label: path.Join("/", p), dirs: make(map[string]string)}
+			if v2 {
+				t.dirs[""] = filepath.Join(root, p)
+			} else {
+				for _, controller := range []string{"memory", "cpu", "cpuacct", "pids", "blkio"} {
+					t.dirs[controller] = filepath.Join(root, controller, p)
+				}
+			}
+			targets = append(targets, t)
+		}
+		return targets, nil
+	}
+
+	content, err := os.ReadFile(c.procSelf)
+	if err != nil {
+		return nil, err
+	}
+	t := cgroupTarget{dirs: make(map[string]string)}
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		// Формат строки: иерархия:контроллеры:путь
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if v2 && parts[0] == "0" && parts[1] == "" {
+			t.dirs[""] = cgroupDir(root, parts[2])
+			break
+		}
+		for _, controller := range strings.Split(parts[1], ",") {
+			if controller != "" && !strings.HasPrefix(controller, "name=") {
+				t.dirs[controller] = cgroupDir(filepath.Join(root, controller), parts[2])
+			}
+		}
+	}
+	if len(t.dirs) == 0 {
+		return nil, fmt.Errorf("no cgroup found in %s", c.procSelf)
+	}
+	return []cgroupTarget{t}, nil
+}
+
+// cgroupDir возвращает каталог cgroup. Без отдельного пространства имен cgroup путь
+// в /proc/self/cgroup указан от корня хоста, а в контейнере смонтирован только каталог
+// самой cgroup, поэтому при его отсутствии используется корень иерархии
+func cgroupDir(root, p string) string {
+	dir := filepath.Join(root, p)
+	if _, err := os.Stat(dir); err != nil {
+		return root
+	}
+	return dir
+}
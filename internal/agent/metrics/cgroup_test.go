@@ -0,0 +1,139 @@
+package metrics
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeTree создает файлы с содержимым по путям относительно dir
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		file := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(file), 0o755))
+		require.NoError(t, os.WriteFile(file, []byte(content), 0o644))
+	}
+}
+
+func TestCgroupCollectorV2(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"cgroup.controllers":       "cpu io memory pids\n",
+		"app/memory.current":       "1048576\n",
+		"app/memory.max":           "max\n",
+		"app/cpu.stat":             "usage_usec 1000\nuser_usec 600\nsystem_usec 400\nnr_periods 10\nnr_throttled 1\nthrottled_usec 50\n",
+		"app/pids.current":         "7\n",
+		"app/pids.max":             "100\n",
+		"app/io.stat":              "8:0 rbytes=100 wbytes=200 rios=1 wios=2 dbytes=0 dios=0\n8:16 rbytes=10 wbytes=20 rios=1 wios=1\n",
+		"proc/self/cgroup":         "0::/app\n",
+		"other/memory.current":     "42\n",
+		"other/memory.max":         "1024\n",
+		"other/cgroup.controllers": "",
+	}
+	writeTree(t, root, files)
+
+	c, err := NewCgroupCollector([]byte(`{"root": "` + root + `"}`))
+	require.NoError(t, err)
+	c.procSelf = filepath.Join(root, "proc/self/cgroup")
+
+	data, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	ids := byID(data)
+	require.Len(t, ids, 3, "counters are reported from the second poll")
+	require.Equal(t, float64(1048576), *ids["CgroupMemoryUsageBytes"].Value)
+	require.NotContains(t, ids, "CgroupMemoryLimitBytes")
+	require.Equal(t, float64(7), *ids["CgroupPids"].Value)
+	require.Equal(t, float64(100), *ids["CgroupPidsLimit"].Value)
+
+	writeTree(t, root, map[string]string{
+		"app/cpu.stat": "usage_usec 3000\nnr_periods 20\nnr_throttled 4\nthrottled_usec 150\n",
+		"app/io.stat":  "8:0 rbytes=150 wbytes=200 rios=2 wios=2\n8:16 rbytes=10 wbytes=30 rios=1 wios=2\n",
+	})
+	data, err = c.Collect(context.Background())
+	require.NoError(t, err)
+	ids = byID(data)
+	require.Equal(t, int64(2000), *ids["CgroupCPUUsageMicros"].Delta)
+	require.Equal(t, int64(10), *ids["CgroupCPUPeriods"].Delta)
+	require.Equal(t, int64(3), *ids["CgroupCPUThrottledPeriods"].Delta)
+	require.Equal(t, int64(100), *ids["CgroupCPUThrottledMicros"].Delta)
+	require.Equal(t, int64(50), *ids["CgroupIOReadBytes"].Delta)
+	require.Equal(t, int64(10), *ids["CgroupIOWriteBytes"].Delta)
+	require.Equal(t, int64(1), *ids["CgroupIOReadOps"].Delta)
+	require.Equal(t, int64(1), *ids["CgroupIOWriteOps"].Delta)
+
+	c, err = NewCgroupCollector([]byte(`{"root": "` + root + `", "paths": ["other"]}`))
+	require.NoError(t, err)
+	data, err = c.Collect(context.Background())
+	require.NoError(t, err)
+	ids = byID(data)
+	require.Equal(t, float64(42), *ids["CgroupMemoryUsageBytes._other"].Value)
+	require.Equal(t, float64(1024), *ids["CgroupMemoryLimitBytes._other"].Value)
+}
+
+func TestCgroupCollectorV1(t *testing.T) {
+	root := t.TempDir()
+	// Без пространства имен cgroup путь из /proc/self/cgroup отсутствует в контейнере
+	writeTree(t, root, map[string]string{
+		"memory/memory.usage_in_bytes":                  "2048\n",
+		"memory/memory.limit_in_bytes":                  "9223372036854771712\n",
+		"cpuacct/cpuacct.usage":                         "5000000\n",
+		"cpu/cpu.stat":                                  "nr_periods 5\nnr_throttled 0\nthrottled_time 0\n",
+		"pids/pids.current":                             "3\n",
+		"pids/pids.max":                                 "max\n",
+		"blkio/blkio.throttle.io_service_bytes":         "8:0 Read 100\n8:0 Write 50\n8:0 Total 150\nTotal 150\n",
+		"blkio/blkio.throttle.io_serviced":              "8:0 Read 1\n8:0 Write 1\n",
+		"proc/self/cgroup":                              "12:memory:/docker/abc\n11:cpu,cpuacct:/docker/abc\n10:pids:/docker/abc\n9:blkio:/docker/abc\n1:name=systemd:/docker/abc\n",
+		"memory/docker/other/memory.usage_in_bytes":     "1\n",
+		"memory/docker/other/memory.limit_in_bytes":     "4096\n",
+		"cpuacct/docker/other/cpuacct.usage":            "0\n",
+		"pids/docker/other/pids.current":                "1\n",
+		"blkio/docker/other/blkio.throttle.io_serviced": "",
+	})
+
+	c, err := NewCgroupCollector([]byte(`{"root": "` + root + `"}`))
+	require.NoError(t, err)
+	c.procSelf = filepath.Join(root, "proc/self/cgroup")
+
+	data, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	ids := byID(data)
+	require.Len(t, ids, 2)
+	require.Equal(t, float64(2048), *ids["CgroupMemoryUsageBytes"].Value)
+	require.Equal(t, float64(3), *ids["CgroupPids"].Value)
+
+	writeTree(t, root, map[string]string{
+		"cpuacct/cpuacct.usage":                 "7000000\n",
+		"cpu/cpu.stat":                          "nr_periods 8\nnr_throttled 2\nthrottled_time 3000000\n",
+		"blkio/blkio.throttle.io_service_bytes": "8:0 Read 300\n8:0 Write 50\n",
+	})
+	data, err = c.Collect(context.Background())
+	require.NoError(t, err)
+	ids = byID(data)
+	require.Equal(t, int64(2000), *ids["CgroupCPUUsageMicros"].Delta)
+	require.Equal(t, int64(3), *ids["CgroupCPUPeriods"].Delta)
+	require.Equal(t, int64(2), *ids["CgroupCPUThrottledPeriods"].Delta)
+	require.Equal(t, int64(3000), *ids["CgroupCPUThrottledMicros"].Delta)
+	require.Equal(t, int64(200), *ids["CgroupIOReadBytes"].Delta)
+	require.Equal(t, int64(0), *ids["CgroupIOWriteOps"].Delta)
+
+	c, err = NewCgroupCollector([]byte(`{"root": "` + root + `", "paths": ["docker/other"]}`))
+	require.NoError(t, err)
+	data, err = c.Collect(context.Background())
+	require.NoError(t, err)
+	ids = byID(data)
+	require.Equal(t, float64(4096), *ids["CgroupMemoryLimitBytes._docker_other"].Value)
+	require.Equal(t, float64(1), *ids["CgroupPids._docker_other"].Value)
+}
+
+func TestCgroupCollectorNoCgroup(t *testing.T) {
+	c, err := NewCgroupCollector([]byte(`{"root": "` + t.TempDir() + `"}`))
+	require.NoError(t, err)
+	c.procSelf = filepath.Join(t.TempDir(), "missing")
+
+	_, err = c.Collect(context.Background())
+	require.Error(t, err)
+}
@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/load"
+)
+
+func init() {
+	Register("cpu", true, func(options json.RawMessage) (Collector, error) {
+		return NewCPUCollector(), nil
+	})
+	Register("load", true, func(options json.RawMessage) (Collector, error) {
+		return NewLoadCollector(), nil
+	})
+}
+
+// CPUCollector собирает доли процессорного времени по режимам работы
+type CPUCollector struct {
+	times func(ctx context.Context, percpu bool) ([]cpu.TimesStat, error)
+	last  *cpu.TimesStat
+}
+
+// NewCPUCollector создает CPUCollector
+func NewCPUCollector() *CPUCollector {
+	return &CPUCollector{times: cpu.TimesWithContext}
+}
+
+// Name возвращает имя сборщика
+func (c *CPUCollector) Name() string {
+	return "cpu"
+}
+
+// Collect возвращает доли времени user, system, iowait, steal и idle всех ядер в процентах
+// с прошлого опроса. Первый опрос только запоминает значения
+func (c *CPUCollector) Collect(ctx context.Context) ([]Metric, error) {
+	times, err := c.times(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(times) == 0 {
+		return nil, nil
+	}
+
+	prev, cur := c.last, times[0]
+	c.last = &cur
+	if prev == nil {
+		return nil, nil
+	}
+	total := cpuTotal(cur) - cpuTotal(*prev)
+	if total <= 0 {
+		return nil, nil
+	}
+
+	share := func(prev, cur float64) float64 {
+		return min(max((cur-prev)/total*100, 0), 100)
+	}
+	return []Metric{
+		gauge("CPUUser", share(prev.User+prev.Nice, cur.User+cur.Nice)),
+		gauge("CPUSystem", share(prev.System+prev.Irq+prev.Softirq, cur.System+cur.Irq+cur.Softirq)),
+		gauge("CPUIowait", share(prev.Iowait, cur.Iowait)),
+		gauge("CPUSteal", share(prev.Steal, cur.Steal)),
+		gauge("CPUIdle", share(prev.Idle, cur.Idle)),
+	}, nil
+}
+
+// LoadCollector собирает среднюю загрузку системы
+type LoadCollector struct {
+	avg func(ctx context.Context) (*load.AvgStat, error)
+}
+
+// NewLoadCollector создает LoadCollector
+func NewLoadCollector() *LoadCollector {
+	return &LoadCollector{avg: load.AvgWithContext}
+}
+
+// Name возвращает имя сборщика
+func (c *LoadCollector) Name() string {
+	return "load"
+}
+
+// Collect возвращает среднюю загрузку за 1, 5 и 15 минут
+func (c *LoadCollector) Collect(ctx context.Context) ([]Metric, error) {
+	avg, err := c.avg(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return []Metric{
+		gauge("Load1", avg.Load1),
+		gauge("Load5", avg.Load5),
+		gauge("Load15", avg.Load15),
+	}, nil
+}
+
+// cpuTotal возвращает все процессорное время. Время гостевых систем уже учтено в user и nice
+func cpuTotal(t cpu.TimesStat) float64 {
+	return t.Total() - t.Guest - t.GuestNice
+}
+
+// cpuBusy возвращает загрузку процессора в процентах между двумя замерами
+func cpuBusy(prev, cur cpu.TimesStat) (float64, bool) {
+	total := cpuTotal(cur) - cpuTotal(prev)
+	if total <= 0 {
+		return 0, false
+	}
+	idle := (cur.Idle + cur.Iowait) - (prev.Idle + prev.Iowait)
+	return min(max((total-idle)/total*100, 0), 100), true
+}
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/load"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCPUCollector(t *testing.T) {
+	c := NewCPUCollector()
+	times := cpu.TimesStat{User: 100, System: 50, Idle: 800, Iowait: 40, Steal: 10}
+	c.times = func(ctx context.Context, percpu bool) ([]cpu.TimesStat, error) {
+		require.False(t, percpu)
+		return []cpu.TimesStat{times}, nil
+	}
+
+	data, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, data, "first poll only remembers cpu times")
+
+	times = cpu.TimesStat{User: 130, Nice: 10, System: 60, Softirq: 10, Idle: 830, Iowait: 50, Steal: 10, Guest: 5}
+	data, err = c.Collect(context.Background())
+	require.NoError(t, err)
+
+	ids := byID(data)
+	require.Len(t, ids, 5)
+	require.InDelta(t, 40, *ids["CPUUser"].Value, 1e-9)
+	require.InDelta(t, 20, *ids["CPUSystem"].Value, 1e-9)
+	require.InDelta(t, 10, *ids["CPUIowait"].Value, 1e-9)
+	require.InDelta(t, 0, *ids["CPUSteal"].Value, 1e-9)
+	require.InDelta(t, 30, *ids["CPUIdle"].Value, 1e-9)
+}
+
+func TestLoadCollector(t *testing.T) {
+	c := NewLoadCollector()
+	c.avg = func(ctx context.Context) (*load.AvgStat, error) {
+		return &load.AvgStat{Load1: 1.5, Load5: 1, Load15: 0.5}, nil
+	}
+
+	data, err := c.Collect(context.Background())
+	require.NoError(t, err)
+
+	ids := byID(data)
+	require.Equal(t, 1.5, *ids["Load1"].Value)
+	require.Equal(t, float64(1), *ids["Load5"].Value)
+	require.Equal(t, 0.5, *ids["Load15"].Value)
+}
+
+func TestCPUBusy(t *testing.T) {
+	tests := []struct {
+		name     string
+		prev     cpu.TimesStat
+		cur      cpu.TimesStat
+		want     float64
+		wantBusy bool
+	}{
+		{name: "half busy", prev: cpu.TimesStat{User: 10, Idle: 10}, cur: cpu.TimesStat{User: 20, Idle: 15, Iowait: 5}, want: 50, wantBusy: true},
+		{name: "idle", prev: cpu.TimesStat{Idle: 10}, cur: cpu.TimesStat{Idle: 20}, want: 0, wantBusy: true},
+		{name: "no time passed", prev: cpu.TimesStat{User: 10}, cur: cpu.TimesStat{User: 10}, wantBusy: false},
+		{name: "counter reset", prev: cpu.TimesStat{User: 100}, cur: cpu.TimesStat{User: 10}, wantBusy: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			busy, ok := cpuBusy(tt.prev, tt.cur)
+			require.Equal(t, tt.wantBusy, ok)
+			require.InDelta(t, tt.want, busy, 1e-9)
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+func init() {
+	Register("disk", false, func(options json.RawMessage) (Collector, error) {
+		return NewDiskCollector(options)
+	})
+	Register("diskio", false, func(options json.RawMessage) (Collector, error) {
+		return NewDiskIOCollector(options)
+	})
+}
+
+// DiskOptions настройки сборщика disk
+type DiskOptions struct {
+	Mountpoints Filter `json:"mountpoints"`
+	Fstypes     Filter `json:"fstypes"`
+	All         bool   `json:"all"` // учитывать виртуальные файловые системы
+}
+
+// DiskCollector собирает заполнение файловых систем и их inode по точкам монтирования
+type DiskCollector struct {
+	options    DiskOptions
+	partitions func(ctx context.Context, all bool) ([]disk.PartitionStat, error)
+	usage      func(ctx context.Context, path string) (*disk.UsageStat, error)
+}
+
+// NewDiskCollector создает DiskCollector. По умолчанию исключены временные и образные файловые системы
+func NewDiskCollector(options json.RawMessage) (*DiskCollector, error) {
+	c := &DiskCollector{
+		options: DiskOptions{
+			Fstypes: Filter{Exclude: []string{"tmpfs", "devtmpfs", "overlay", "squashfs"}},
+		},
+		partitions: disk.PartitionsWithContext,
+		usage:      disk.UsageWithContext,
+	}
+	if err := decodeOptions(options, &c.options); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Name возвращает имя сборщика
+func (c *DiskCollector) Name() string {
+	return "disk"
+}
+
+// Collect возвращает объем, заполнение и inode каждой подходящей файловой системы
+func (c *DiskCollector) Collect(ctx context.Context) ([]Metric, error) {
+	partitions, err := c.partitions(ctx, c.options.All)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []Metric
+	seen := make(map[string]bool)
+	for _, p := range partitions {
+		if seen[p.Mountpoint] || !c.options.Mountpoints.Match(p.Mountpoint) || !c.options.Fstypes.Match(p.Fstype) {
+			continue
+		}
+		seen[p.Mountpoint] = true
+
+		usage, err := c.usage(ctx, p.Mountpoint)
+		if err != nil {
+			// Недоступная точка монтирования не мешает остальным
+			continue
+		}
+		data = append(data,
+			gauge(labeled("DiskTotalBytes", p.Mountpoint), float64(usage.Total)),
+			gauge(labeled("DiskUsedBytes", p.Mountpoint), float64(usage.Used)),
+			gauge(labeled("DiskFreeBytes", p.Mountpoint), float64(usage.Free)),
+			gauge(labeled("DiskUsedPercent", p.Mountpoint), usage.UsedPercent),
+		)
+		if usage.InodesTotal > 0 {
+			data = append(data,
+				gauge(labeled("InodesTotal", p.Mountpoint), float64(usage.InodesTotal)),
+				gauge(labeled("InodesUsed", p.Mountpoint), float64(usage.InodesUsed)),
+				gauge(labeled("InodesFree", p.Mountpoint), float64(usage.InodesFree)),
+				gauge(labeled("InodesUsedPercent", p.Mountpoint), usage.InodesUsedPercent),
+			)
+		}
+	}
+	return data, nil
+}
+
+// DiskIOOptions настройки сборщика diskio
+type DiskIOOptions struct {
+	Devices Filter `json:"devices"`
+}
+
+// DiskIOCollector собирает прочитанные и записанные байты и число операций по устройствам.
+// Значения отправляются счетчиками с приращением с прошлого опроса
+type DiskIOCollector struct {
+	options    DiskIOOptions
+	ioCounters func(ctx context.Context, names ...string) (map[string]disk.IOCountersStat, error)
+	last       *deltas
+}
+
+// NewDiskIOCollector создает DiskIOCollector. По умолчанию исключены loop и ram устройства
+func NewDiskIOCollector(options json.RawMessage) (*DiskIOCollector, error) {
+	c := &DiskIOCollector{
+		options:    DiskIOOptions{Devices: Filter{Exclude: []string{"loop*", "ram*"}}},
+		ioCounters: disk.IOCountersWithContext,
+		last:       newDeltas(),
+	}
+	if err := decodeOptions(options, &c.options); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Name возвращает имя сборщика
+func (c *DiskIOCollector) Name() string {
+	return "diskio"
+}
+
+// Collect возвращает приращения счетчиков ввода-вывода. Первый опрос только запоминает значения
+func (c *DiskIOCollector) Collect(ctx context.Context) ([]Metric, error) {
+	counters, err := c.ioCounters(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []Metric
+	for name, io := range counters {
+		if !c.options.Devices.Match(name) {
+			continue
+		}
+		for _, v := range []struct {
+			id    string
+			value uint64
+		}{
+			{labeled("DiskReadBytes", name), io.ReadBytes},
+			{labeled("DiskWriteBytes", name), io.WriteBytes},
+			{labeled("DiskReadOps", name), io.ReadCount},
+			{labeled("DiskWriteOps", name), io.WriteCount},
+		} {
+			if delta, ok := c.last.delta(v.id, v.value); ok {
+				data = append(data, counter(v.id, delta))
+			}
+		}
+	}
+	c.last.prune()
+	return data, nil
+}
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/stretchr/testify/require"
+)
+
+func byID(data []Metric) map[string]Metric {
+	ids := make(map[string]Metric, len(data))
+	for _, metric := range data {
+		ids[metric.ID] = metric
+	}
+	return ids
+}
+
+func TestDiskCollector(t *testing.T) {
+	c, err := NewDiskCollector([]byte(`{"mountpoints": {"exclude": ["/boot/*"]}}`))
+	require.NoError(t, err)
+	c.partitions = func(ctx context.Context, all bool) ([]disk.PartitionStat, error) {
+		return []disk.PartitionStat{
+			{Device: "/dev/sda1", Mountpoint: "/", Fstype: "ext4"},
+			{Device: "/dev/sda1", Mountpoint: "/", Fstype: "ext4"},
+			{Device: "/dev/sda2", Mountpoint: "/boot/efi", Fstype: "vfat"},
+			{Device: "/dev/sdb1", Mountpoint: "/var/lib/data", Fstype: "xfs"},
+			{Device: "tmpfs", Mountpoint: "/run", Fstype: "tmpfs"},
+			{Device: "/dev/sdc1", Mountpoint: "/mnt/broken", Fstype: "ext4"},
+		}, nil
+	}
+	c.usage = func(ctx context.Context, path string) (*disk.UsageStat, error) {
+		if path == "/mnt/broken" {
+			return nil, errors.New("stale file handle")
+		}
+		return &disk.UsageStat{Path: path, Total: 100, Used: 40, Free: 60, UsedPercent: 40, InodesTotal: 10, InodesUsed: 1, InodesFree: 9, InodesUsedPercent: 10}, nil
+	}
+
+	data, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	require.Len(t, data, 16)
+
+	ids := byID(data)
+	require.Equal(t, float64(40), *ids["DiskUsedBytes._"].Value)
+	require.Equal(t, float64(10), *ids["InodesUsedPercent._var_lib_data"].Value)
+	require.NotContains(t, ids, "DiskUsedBytes._boot_efi")
+	require.NotContains(t, ids, "DiskUsedBytes._run")
+}
+
+func TestDiskIOCollector(t *testing.T) {
+	c, err := NewDiskIOCollector(nil)
+	require.NoError(t, err)
+
+	stats := map[string]disk.IOCountersStat{
+		"sda":   {Name: "sda", ReadBytes: 1000, WriteBytes: 2000, ReadCount: 10, WriteCount: 20},
+		"loop0": {Name: "loop0", ReadBytes: 5},
+	}
+	c.ioCounters = func(ctx context.Context, names ...string) (map[string]disk.IOCountersStat, error) {
+		return stats, nil
+	}
+
+	data, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, data, "first poll only remembers counters")
+
+	stats["sda"] = disk.IOCountersStat{Name: "sda", ReadBytes: 1500, WriteBytes: 2000, ReadCount: 15, WriteCount: 3}
+	data, err = c.Collect(context.Background())
+	require.NoError(t, err)
+
+	ids := byID(data)
+	require.Len(t, ids, 4)
+	require.Equal(t, "counter", ids["DiskReadBytes.sda"].MType)
+	require.Equal(t, int64(500), *ids["DiskReadBytes.sda"].Delta)
+	require.Equal(t, int64(0), *ids["DiskWriteBytes.sda"].Delta)
+	require.Equal(t, int64(5), *ids["DiskReadOps.sda"].Delta)
+	require.Equal(t, int64(3), *ids["DiskWriteOps.sda"].Delta, "counter reset")
+}
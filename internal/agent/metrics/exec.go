@@ -0,0 +1,341 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	Register("exec", false, func(options json.RawMessage) (Collector, error) {
+		return NewExecCollector(options)
+	})
+}
+
+// Форматы вывода скриптов
+const (
+	FormatSimple     = "simple"     // строки "имя тип значение"
+	FormatPrometheus = "prometheus" // текстовый формат Prometheus
+	FormatJSON       = "json"       // JSON массив Metric
+)
+
+// defaultExecTimeout время выполнения скрипта по умолчанию
+const defaultExecTimeout = 10 * time.Second
+
+// ExecScript команда, выводящая метрики в stdout
+type ExecScript struct {
+	Name    string   `json:"name"`    // имя скрипта, метка счетчика ошибок ExecErrors
+	Command []string `json:"command"` // программа и ее аргументы, без оболочки
+	Timeout int      `json:"timeout"` // в секундах, по умолчанию 10
+	Format  string   `json:"format"`  // simple, prometheus или json, по умолчанию simple
+	Prefix  string   `json:"prefix"`  // добавляется к именам метрик скрипта
+}
+
+// ExecOptions настройки сборщика exec
+type ExecOptions struct {
+	Scripts []ExecScript `json:"scripts"`
+}
+
+// ExecCollector запускает скрипты и разбирает метрики из их вывода. Скрипты выполняются
+// параллельно; неудачный запуск или разбор добавляется в счетчик ExecErrors.<имя скрипта>
+type ExecCollector struct {
+	options ExecOptions
+
+	mu   sync.Mutex
+	last map[string]*deltas // накопленные значения счетчиков Prometheus по скриптам
+}
+
+// NewExecCollector создает ExecCollector. Каждый скрипт должен иметь уникальное имя и команду
+func NewExecCollector(options json.RawMessage) (*ExecCollector, error) {
+	c := &ExecCollector{last: make(map[string]*deltas)}
+	if err := decodeOptions(options, &c.options); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for i := range c.options.Scripts {
+		s := &c.options.Scripts[i]
+		if s.Name == "" {
+			return nil, errors.New("exec script without name")
+		}
+		if seen[s.Name] {
+			return nil, fmt.Errorf("exec script %s defined twice", s.Name)
+		}
+		seen[s.Name] = true
+		if len(s.Command) == 0 {
+			return nil, fmt.Errorf("exec script %s: command is required", s.Name)
+		}
+		switch s.Format {
+		case "":
+			s.Format = FormatSimple
+		case FormatSimple, FormatPrometheus, FormatJSON:
+		default:
+			return nil, fmt.Errorf("exec script %s: unknown format %q", s.Name, s.Format)
+		}
+		c.last[s.Name] = newDeltas()
+	}
+	return c, nil
+}
+
+// Name возвращает имя сборщика
+func (c *ExecCollector) Name() string {
+	return "exec"
+}
+
+// Collect запускает все скрипты и возвращает их метрики и счетчики ошибок
+func (c *ExecCollector) Collect(ctx context.Context) ([]Metric, error) {
+	results := make([][]Metric, len(c.options.Scripts))
+	var wg sync.WaitGroup
+	for i, s := range c.options.Scripts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, err := c.run(ctx, s)
+			var failed int64
+			if err != nil {
+				log.Errorf("Exec script %s: %s", s.Name, err)
+				data, failed = nil, 1
+			}
+			results[i] = append(data, counter(labeled("ExecErrors", s.Name), failed))
+		}()
+	}
+	wg.Wait()
+
+	var data []Metric
+	for _, result := range results {
+		data = append(data, result...)
+	}
+	return data, nil
+}
+
+// run выполняет скрипт с таймаутом и разбирает его вывод
+func (c *ExecCollector) run(ctx context.Context, s ExecScript) ([]Metric, error) {
+	timeout := defaultExecTimeout
+	if s.Timeout > 0 {
+		timeout = time.Duration(s.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.Command[0], s.Command[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Дочерние процессы скрипта могут удерживать вывод после его завершения
+	cmd.WaitDelay = time.Second
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("timed out after %s", timeout)
+		}
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var data []Metric
+	var err error
+	switch s.Format {
+	case FormatPrometheus:
+		c.mu.Lock()
+		data, err = parsePrometheus(stdout.Bytes(), c.last[s.Name])
+		c.mu.Unlock()
+	case FormatJSON:
+		data, err = parseJSON(stdout.Bytes())
+	default:
+		data, err = parseSimple(stdout.Bytes())
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range data {
+		data[i].ID = s.Prefix + data[i].ID
+	}
+	return data, nil
+}
+
+// parseSimple разбирает строки "имя тип значение". Пустые строки и строки с # пропускаются
+func parseSimple(output []byte) ([]Metric, error) {
+	var data []Metric
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: expected \"name type value\"", n)
+		}
+		metric, err := parseValue(fields[0], fields[1], fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		data = append(data, metric)
+	}
+	return data, scanner.Err()
+}
+
+// parseValue создает метрику по строковому типу и значению
+func parseValue(name, mType, value string) (Metric, error) {
+	switch mType {
+	case "gauge":
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return Metric{}, fmt.Errorf("invalid gauge value %q", value)
+		}
+		return gauge(name, v), nil
+	case "counter":
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return Metric{}, fmt.Errorf("invalid counter value %q", value)
+		}
+		return counter(name, v), nil
+	default:
+		return Metric{}, fmt.Errorf("unknown metric type %q", mType)
+	}
+}
+
+// parseJSON разбирает JSON массив метрик
+func parseJSON(output []byte) ([]Metric, error) {
+	var data []Metric
+	if err := json.Unmarshal(output, &data); err != nil {
+		return nil, err
+	}
+	for _, metric := range data {
+		switch {
+		case metric.ID == "":
+			return nil, errors.New("metric without id")
+		case metric.MType == "gauge" && metric.Value == nil, metric.MType == "counter" && metric.Delta == nil:
+			return nil, fmt.Errorf("metric %s without value", metric.ID)
+		case metric.MType != "gauge" && metric.MType != "counter":
+			return nil, fmt.Errorf("metric %s: unknown type %q", metric.ID, metric.MType)
+		}
+	}
+	return data, nil
+}
+
+// parsePrometheus разбирает текстовый формат Prometheus. Метки добавляются к имени
+// через точку, счетчики (TYPE counter) накопительные и отправляются приращением
+// с прошлого запуска, остальные значения отправляются как gauge. Счетчики сервера
+// целочисленные, поэтому дробное значение счетчика — ошибка разбора всего вывода
+// скрипта; такие значения нужно объявлять как gauge
+func parsePrometheus(output []byte, last *deltas) ([]Metric, error) {
+	types := make(map[string]string)
+	var data []Metric
+	// приращения считаются после разбора всего вывода, чтобы ошибка не сдвигала базу счетчиков
+	var totals []Metric
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			fields := strings.Fields(line)
+			if len(fields) >= 4 && fields[1] == "TYPE" {
+				types[fields[2]] = fields[3]
+			}
+			continue
+		}
+
+		name, labels, rest, err := splitPrometheusSample(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("line %d: missing value", n)
+		}
+		value, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid value %q", n, fields[0])
+		}
+
+		id := name
+		for _, label := range labels {
+			id = labeled(id, label)
+		}
+		if types[name] != "counter" {
+			data = append(data, gauge(id, value))
+			continue
+		}
+		if value < 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+			return nil, fmt.Errorf("line %d: invalid counter value %q", n, fields[0])
+		}
+		if value != math.Trunc(value) || value >= math.MaxInt64 {
+			return nil, fmt.Errorf("line %d: counter %s has non-integer value %q, declare it as gauge", n, name, fields[0])
+		}
+		totals = append(totals, counter(id, int64(value)))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, total := range totals {
+		if delta, ok := last.delta(total.ID, uint64(*total.Delta)); ok {
+			data = append(data, counter(total.ID, delta))
+		}
+	}
+	last.prune()
+	return data, nil
+}
+
+// splitPrometheusSample разделяет строку образца на имя, значения меток и остаток со значением
+func splitPrometheusSample(line string) (string, []string, string, error) {
+	open := strings.IndexByte(line, '{')
+	if open < 0 {
+		name, rest, _ := strings.Cut(line, " ")
+		return name, nil, rest, nil
+	}
+	end := strings.LastIndexByte(line, '}')
+	if end < open {
+		return "", nil, "", errors.New("unterminated labels")
+	}
+
+	var labels []string
+	for _, pair := range splitLabels(line[open+1 : end]) {
+		_, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return "", nil, "", fmt.Errorf("invalid label %q", pair)
+		}
+		unquoted, err := strconv.Unquote(strings.TrimSpace(value))
+		if err != nil {
+			return "", nil, "", fmt.Errorf("invalid label %q", pair)
+		}
+		labels = append(labels, unquoted)
+	}
+	return line[:open], labels, line[end+1:], nil
+}
+
+// splitLabels разделяет метки по запятым вне кавычек
+func splitLabels(s string) []string {
+	var labels []string
+	var quoted, escaped bool
+	start := 0
+	for i, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case r == ',' && !quoted:
+			labels = append(labels, s[start:i])
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		labels = append(labels, s[start:])
+	}
+	return labels
+}
@@ -0,0 +1,151 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewExecCollector(t *testing.T) {
+	tests := []struct {
+		name    string
+		options string
+		wantErr bool
+	}{
+		{name: "valid", options: `{"scripts": [{"name": "orders", "command": ["/opt/orders.sh"], "format": "json"}]}`},
+		{name: "without name", options: `{"scripts": [{"command": ["true"]}]}`, wantErr: true},
+		{name: "without command", options: `{"scripts": [{"name": "a"}]}`, wantErr: true},
+		{name: "duplicate name", options: `{"scripts": [{"name": "a", "command": ["true"]}, {"name": "a", "command": ["true"]}]}`, wantErr: true},
+		{name: "unknown format", options: `{"scripts": [{"name": "a", "command": ["true"], "format": "xml"}]}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewExecCollector([]byte(tt.options))
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestExecCollector(t *testing.T) {
+	c, err := NewExecCollector([]byte(`{"scripts": [
+		{"name": "orders", "command": ["sh", "-c", "echo 'Orders gauge 12.5'; echo 'Sales counter 3'"], "prefix": "shop."},
+		{"name": "json", "command": ["echo", "[{\"id\":\"Users\",\"type\":\"gauge\",\"value\":7}]"], "format": "json"},
+		{"name": "broken", "command": ["sh", "-c", "echo 'Orders gauge'"]},
+		{"name": "failing", "command": ["sh", "-c", "exit 3"]},
+		{"name": "slow", "command": ["sleep", "5"], "timeout": 1}
+	]}`))
+	require.NoError(t, err)
+
+	data, err := c.Collect(context.Background())
+	require.NoError(t, err)
+
+	ids := byID(data)
+	require.Len(t, ids, 8)
+	require.Equal(t, 12.5, *ids["shop.Orders"].Value)
+	require.Equal(t, int64(3), *ids["shop.Sales"].Delta)
+	require.Equal(t, float64(7), *ids["Users"].Value)
+	require.Equal(t, int64(0), *ids["ExecErrors.orders"].Delta)
+	require.Equal(t, int64(0), *ids["ExecErrors.json"].Delta)
+	require.Equal(t, int64(1), *ids["ExecErrors.broken"].Delta)
+	require.Equal(t, int64(1), *ids["ExecErrors.failing"].Delta)
+	require.Equal(t, int64(1), *ids["ExecErrors.slow"].Delta)
+}
+
+func TestParseSimple(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    int
+		wantErr bool
+	}{
+		{name: "valid", output: "# comment\nA gauge 1.5\n\nB counter 2\n", want: 2},
+		{name: "missing value", output: "A gauge\n", wantErr: true},
+		{name: "unknown type", output: "A histogram 1\n", wantErr: true},
+		{name: "fractional counter", output: "A counter 1.5\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := parseSimple([]byte(tt.output))
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, data, tt.want)
+		})
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		wantErr bool
+	}{
+		{name: "valid", output: `[{"id":"A","type":"gauge","value":1},{"id":"B","type":"counter","delta":2}]`},
+		{name: "not an array", output: `{"id":"A"}`, wantErr: true},
+		{name: "without value", output: `[{"id":"A","type":"gauge"}]`, wantErr: true},
+		{name: "unknown type", output: `[{"id":"A","type":"summary","value":1}]`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseJSON([]byte(tt.output))
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestParsePrometheus(t *testing.T) {
+	last := newDeltas()
+	output := `# HELP http_requests_total Requests.
+# TYPE http_requests_total counter
+http_requests_total{method="post",code="200"} 1027 1395066363000
+http_requests_total{method="post",code="400"} 3
+# TYPE queue_size gauge
+queue_size 12
+temperature{room="a,b"} -3.5
+`
+	data, err := parsePrometheus([]byte(output), last)
+	require.NoError(t, err)
+	ids := byID(data)
+	require.Len(t, ids, 2, "counters are reported from the second run")
+	require.Equal(t, float64(12), *ids["queue_size"].Value)
+	require.Equal(t, -3.5, *ids["temperature.a:2cb"].Value)
+
+	data, err = parsePrometheus([]byte(`# TYPE http_requests_total counter
+http_requests_total{method="post",code="200"} 1030
+http_requests_total{method="post",code="400"} 3
+`), last)
+	require.NoError(t, err)
+	ids = byID(data)
+	require.Equal(t, int64(3), *ids["http_requests_total.post.200"].Delta)
+	require.Equal(t, int64(0), *ids["http_requests_total.post.400"].Delta)
+
+	_, err = parsePrometheus([]byte("broken{a=\"1\" 1\n"), last)
+	require.Error(t, err)
+	_, err = parsePrometheus([]byte("broken NaNx\n"), last)
+	require.Error(t, err)
+
+	// дробный счетчик отклоняет весь вывод и не сдвигает базу остальных счетчиков
+	_, err = parsePrometheus([]byte(`# TYPE http_requests_total counter
+http_requests_total{method="post",code="200"} 1040
+# TYPE cpu_seconds_total counter
+cpu_seconds_total 12.5
+`), last)
+	require.ErrorContains(t, err, "counter cpu_seconds_total has non-integer value")
+
+	data, err = parsePrometheus([]byte(`# TYPE http_requests_total counter
+http_requests_total{method="post",code="200"} 1041
+`), last)
+	require.NoError(t, err)
+	require.Equal(t, int64(11), *byID(data)["http_requests_total.post.200"].Delta)
+}
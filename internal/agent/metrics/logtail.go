@@ -0,0 +1,389 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	Register("logtail", false, func(options json.RawMessage) (Collector, error) {
+		return NewLogTailCollector(options)
+	})
+}
+
+// fingerprintSize число первых байт файла, по которым после перезапуска узнается тот же файл
+const fingerprintSize = 256
+
+// Способы объединения значений gauge, найденных за один опрос
+const (
+	AggregateLast = "last"
+	AggregateMax  = "max"
+	AggregateAvg  = "avg"
+)
+
+// LogRule правило разбора строк журнала. Счетчик увеличивается на 1 за каждую подходящую строку
+// или на число из первой группы выражения; gauge принимает число из первой группы
+type LogRule struct {
+	Name      string `json:"name"`      // имя метрики
+	Pattern   string `json:"pattern"`   // регулярное выражение
+	Type      string `json:"type"`      // counter или gauge
+	Aggregate string `json:"aggregate"` // для gauge: last, max или avg, по умолчанию last
+
+	re *regexp.Regexp
+}
+
+// LogFile отслеживаемый файл журнала
+type LogFile struct {
+	Path          string    `json:"path"`
+	FromBeginning bool      `json:"from_beginning"` // без сохраненной позиции читать файл с начала, а не с конца
+	Rules         []LogRule `json:"rules"`
+}
+
+// LogTailOptions настройки сборщика logtail
+type LogTailOptions struct {
+	Files     []LogFile `json:"files"`
+	StateFile string    `json:"state_file"` // файл с позициями чтения, сохраняемыми между перезапусками
+}
+
+// logPosition позиция чтения файла. Fingerprint — хеш первых байт файла
+type logPosition struct {
+	Offset      int64  `json:"offset"`
+	Fingerprint string `json:"fingerprint"`
+	Size        int    `json:"size"` // число байт, по которым посчитан fingerprint
+}
+
+// logTail открытый файл журнала и позиция в нем
+type logTail struct {
+	file   *os.File
+	offset int64
+}
+
+// LogTailCollector читает новые строки журналов и считает по ним метрики.
+// Ротация определяется по смене файла по пути, а обрезка на месте — по уменьшению размера
+// или смене первых байт файла
+type LogTailCollector struct {
+	options LogTailOptions
+	tails   map[string]*logTail
+	state   map[string]logPosition
+	started bool
+}
+
+// NewLogTailCollector создает LogTailCollector и загружает сохраненные позиции чтения
+func NewLogTailCollector(options json.RawMessage) (*LogTailCollector, error) {
+	c := &LogTailCollector{
+		tails: make(map[string]*logTail),
+		state: make(map[string]logPosition),
+	}
+	if err := decodeOptions(options, &c.options); err != nil {
+		return nil, err
+	}
+
+	types := make(map[string]string)
+	for i := range c.options.Files {
+		f := &c.options.Files[i]
+		if f.Path == "" {
+			return nil, errors.New("log file without path")
+		}
+		for j := range f.Rules {
+			r := &f.Rules[j]
+			if err := r.compile(); err != nil {
+				return nil, fmt.Errorf("log file %s: %w", f.Path, err)
+			}
+			if t, ok := types[r.Name]; ok && t != r.Type {
+				return nil, fmt.Errorf("log file %s: rule %s is both %s and %s", f.Path, r.Name, t, r.Type)
+			}
+			types[r.Name] = r.Type
+		}
+	}
+
+	if c.options.StateFile != "" {
+		content, err := os.ReadFile(c.options.StateFile)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return nil, err
+		default:
+			if err := json.Unmarshal(content, &c.state); err != nil {
+				return nil, fmt.Errorf("log tail state %s: %w", c.options.StateFile, err)
+			}
+		}
+	}
+	return c, nil
+}
+
+// compile проверяет правило и компилирует его выражение
+func (r *LogRule) compile() error {
+	if r.Name == "" {
+		return errors.New("rule without name")
+	}
+	re, err := regexp.Compile(r.Pattern)
+	if err != nil {
+		return fmt.Errorf("rule %s: %w", r.Name, err)
+	}
+	r.re = re
+
+	switch r.Type {
+	case "counter":
+	case "gauge":
+		if re.NumSubexp() == 0 {
+			return fmt.Errorf("rule %s: gauge pattern must capture a value", r.Name)
+		}
+	default:
+		return fmt.Errorf("rule %s: unknown type %q", r.Name, r.Type)
+	}
+
+	switch r.Aggregate {
+	case "":
+		r.Aggregate = AggregateLast
+	case AggregateLast, AggregateMax, AggregateAvg:
+	default:
+		return fmt.Errorf("rule %s: unknown aggregate %q", r.Name, r.Aggregate)
+	}
+	return nil
+}
+
+// Name возвращает имя сборщика
+func (c *LogTailCollector) Name() string {
+	return "logtail"
+}
+
+// logValues значения правил, найденные за один опрос
+type logValues struct {
+	counts map[string]int64
+	sums   map[string]float64
+	values map[string]float64
+	hits   map[string]int
+}
+
+// Collect читает строки, добавленные с прошлого опроса, и сохраняет позиции чтения.
+// Счетчики отправляются всегда, gauge — только если в новых строках нашлось значение
+func (c *LogTailCollector) Collect(ctx context.Context) ([]Metric, error) {
+	values := logValues{
+		counts: make(map[string]int64),
+		sums:   make(map[string]float64),
+		values: make(map[string]float64),
+		hits:   make(map[string]int),
+	}
+	for _, f := range c.options.Files {
+		if err := c.tail(f, values); err != nil {
+			log.Errorf("Log file %s: %s", f.Path, err)
+		}
+	}
+	c.started = true
+
+	if err := c.saveState(); err != nil {
+		log.Errorf("Log tail state %s: %s", c.options.StateFile, err)
+	}
+
+	// Правила с одним именем в разных файлах дают одну общую метрику
+	var data []Metric
+	seen := make(map[string]bool)
+	for _, f := range c.options.Files {
+		for _, r := range f.Rules {
+			if seen[r.Name] {
+				continue
+			}
+			seen[r.Name] = true
+			if r.Type == "counter" {
+				data = append(data, counter(r.Name, values.counts[r.Name]))
+				continue
+			}
+			hits := values.hits[r.Name]
+			if hits == 0 {
+				continue
+			}
+			switch r.Aggregate {
+			case AggregateAvg:
+				data = append(data, gauge(r.Name, values.sums[r.Name]/float64(hits)))
+			default:
+				data = append(data, gauge(r.Name, values.values[r.Name]))
+			}
+		}
+	}
+	return data, nil
+}
+
+// tail дочитывает файл, а при ротации переходит к новому файлу по тому же пути
+func (c *LogTailCollector) tail(f LogFile, values logValues) error {
+	t, ok := c.tails[f.Path]
+	if ok {
+		current, err := t.file.Stat()
+		if err != nil {
+			return err
+		}
+		if current.Size() < t.offset || !c.unchanged(f.Path, t.file) {
+			// Файл обрезан на месте
+			t.offset = 0
+		}
+		if err := c.read(f, t, values); err != nil {
+			return err
+		}
+
+		info, err := os.Stat(f.Path)
+		if err != nil {
+			// Старый файл уже переименован, а новый еще не создан
+			return nil
+		}
+		if os.SameFile(info, current) {
+			return nil
+		}
+		t.file.Close()
+		delete(c.tails, f.Path)
+	}
+
+	file, err := os.Open(f.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	t = &logTail{file: file}
+	c.tails[f.Path] = t
+
+	if !ok && !c.started {
+		if t.offset, err = c.resume(f, file); err != nil {
+			return err
+		}
+	}
+	return c.read(f, t, values)
+}
+
+// resume возвращает позицию, с которой читать файл при запуске: сохраненную позицию,
+// если файл тот же, начало, если файл сменился, и иначе конец файла
+func (c *LogTailCollector) resume(f LogFile, file *os.File) (int64, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if pos, ok := c.state[f.Path]; ok {
+		fp, size, err := fingerprint(file, pos.Size)
+		if err != nil {
+			return 0, err
+		}
+		if size == pos.Size && fp == pos.Fingerprint && pos.Offset <= info.Size() {
+			return pos.Offset, nil
+		}
+		return 0, nil
+	}
+	if f.FromBeginning {
+		return 0, nil
+	}
+	return info.Size(), nil
+}
+
+// unchanged сообщает, что начало файла не изменилось с прошлого чтения,
+// то есть файл не был обрезан и записан заново
+func (c *LogTailCollector) unchanged(path string, file *os.File) bool {
+	pos, ok := c.state[path]
+	if !ok {
+		return true
+	}
+	fp, size, err := fingerprint(file, pos.Size)
+	return err == nil && size == pos.Size && fp == pos.Fingerprint
+}
+
+// read обрабатывает полные строки файла после текущей позиции. Незавершенная строка
+// остается до следующего опроса
+func (c *LogTailCollector) read(f LogFile, t *logTail, values logValues) error {
+	if _, err := t.file.Seek(t.offset, io.SeekStart); err != nil {
+		return err
+	}
+	reader := bufio.NewReader(t.file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		t.offset += int64(len(line))
+		match(f.Rules, bytes.TrimRight(line, "\r\n"), values)
+	}
+
+	fp, size, err := fingerprint(t.file, fingerprintSize)
+	if err != nil {
+		return err
+	}
+	c.state[f.Path] = logPosition{Offset: t.offset, Fingerprint: fp, Size: size}
+	return nil
+}
+
+// match применяет правила к строке
+func match(rules []LogRule, line []byte, values logValues) {
+	for _, r := range rules {
+		groups := r.re.FindSubmatch(line)
+		if groups == nil {
+			continue
+		}
+		if r.Type == "counter" {
+			if len(groups) < 2 {
+				values.counts[r.Name]++
+			} else if n, err := strconv.ParseInt(string(groups[1]), 10, 64); err == nil && n >= 0 {
+				// счетчик только растет, отрицательные значения пропускаются
+				values.counts[r.Name] += n
+			}
+			continue
+		}
+
+		v, err := strconv.ParseFloat(string(groups[1]), 64)
+		if err != nil {
+			continue
+		}
+		hits := values.hits[r.Name]
+		if r.Aggregate != AggregateMax || hits == 0 || v > values.values[r.Name] {
+			values.values[r.Name] = v
+		}
+		values.sums[r.Name] += v
+		values.hits[r.Name] = hits + 1
+	}
+}
+
+// fingerprint возвращает хеш не более size первых байт файла и их число
+func fingerprint(file *os.File, size int) (string, int, error) {
+	buf := make([]byte, size)
+	n, err := file.ReadAt(buf, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", 0, err
+	}
+	sum := sha256.Sum256(buf[:n])
+	return hex.EncodeToString(sum[:]), n, nil
+}
+
+// saveState атомарно записывает позиции чтения в файл состояния
+func (c *LogTailCollector) saveState() error {
+	if c.options.StateFile == "" {
+		return nil
+	}
+	content, err := json.Marshal(c.state)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.options.StateFile), filepath.Base(c.options.StateFile)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.options.StateFile)
+}
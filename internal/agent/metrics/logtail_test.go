@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func appendFile(t *testing.T, path, content string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = f.WriteString(content)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
+
+func newTestLogTail(t *testing.T, path, stateFile string) *LogTailCollector {
+	t.Helper()
+	options, err := json.Marshal(LogTailOptions{
+		StateFile: stateFile,
+		Files: []LogFile{{Path: path, Rules: []LogRule{
+			{Name: "AppErrors", Pattern: "ERROR", Type: "counter"},
+			{Name: "AppBytes", Pattern: `bytes=(\d+)`, Type: "counter"},
+			{Name: "AppLatencyMs", Pattern: `latency=(\d+)ms`, Type: "gauge", Aggregate: "max"},
+			{Name: "AppLastLatencyMs", Pattern: `latency=(\d+)ms`, Type: "gauge"},
+		}}},
+	})
+	require.NoError(t, err)
+	c, err := NewLogTailCollector(options)
+	require.NoError(t, err)
+	return c
+}
+
+func TestNewLogTailCollector(t *testing.T) {
+	tests := []struct {
+		name    string
+		options string
+		wantErr bool
+	}{
+		{name: "valid", options: `{"files": [{"path": "/var/log/app.log", "rules": [{"name": "Errors", "pattern": "ERROR", "type": "counter"}]}]}`},
+		{name: "without path", options: `{"files": [{"rules": []}]}`, wantErr: true},
+		{name: "invalid pattern", options: `{"files": [{"path": "a", "rules": [{"name": "A", "pattern": "(", "type": "counter"}]}]}`, wantErr: true},
+		{name: "gauge without group", options: `{"files": [{"path": "a", "rules": [{"name": "A", "pattern": "x", "type": "gauge"}]}]}`, wantErr: true},
+		{name: "unknown type", options: `{"files": [{"path": "a", "rules": [{"name": "A", "pattern": "x", "type": "summary"}]}]}`, wantErr: true},
+		{name: "unknown aggregate", options: `{"files": [{"path": "a", "rules": [{"name": "A", "pattern": "(x)", "type": "gauge", "aggregate": "p99"}]}]}`, wantErr: true},
+		{name: "conflicting types", options: `{"files": [
+			{"path": "a", "rules": [{"name": "A", "pattern": "(x)", "type": "gauge"}]},
+			{"path": "b", "rules": [{"name": "A", "pattern": "x", "type": "counter"}]}
+		]}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewLogTailCollector([]byte(tt.options))
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestLogTailCollector(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	appendFile(t, path, "ERROR old line before start\n")
+
+	c := newTestLogTail(t, path, "")
+	data, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	ids := byID(data)
+	require.Equal(t, int64(0), *ids["AppErrors"].Delta, "existing lines are skipped")
+	require.NotContains(t, ids, "AppLatencyMs")
+
+	appendFile(t, path, "INFO latency=120ms bytes=100\nERROR failed latency=300ms\nINFO latency=50ms bytes=20\nERROR partial")
+	data, err = c.Collect(context.Background())
+	require.NoError(t, err)
+	ids = byID(data)
+	require.Equal(t, int64(1), *ids["AppErrors"].Delta)
+	require.Equal(t, int64(120), *ids["AppBytes"].Delta)
+	require.Equal(t, float64(300), *ids["AppLatencyMs"].Value)
+	require.Equal(t, float64(50), *ids["AppLastLatencyMs"].Value)
+
+	// Строка дописана, затем файл ротирован переименованием
+	appendFile(t, path, " line\n")
+	require.NoError(t, os.Rename(path, path+".1"))
+	appendFile(t, path, "ERROR in new file\n")
+	data, err = c.Collect(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(2), *byID(data)["AppErrors"].Delta)
+
+	// Файл обрезан на месте
+	require.NoError(t, os.WriteFile(path, []byte("ERROR after truncate\n"), 0o644))
+	data, err = c.Collect(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(1), *byID(data)["AppErrors"].Delta)
+}
+
+func TestLogTailCollectorState(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	stateFile := filepath.Join(dir, "state.json")
+	appendFile(t, path, "INFO start\n")
+
+	c := newTestLogTail(t, path, stateFile)
+	_, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	appendFile(t, path, "ERROR counted once\n")
+	data, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(1), *byID(data)["AppErrors"].Delta)
+
+	// После перезапуска чтение продолжается с сохраненной позиции
+	appendFile(t, path, "ERROR while agent was down\n")
+	c = newTestLogTail(t, path, stateFile)
+	data, err = c.Collect(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(1), *byID(data)["AppErrors"].Delta)
+
+	// Если файл сменился, пока агент не работал, новый файл читается с начала
+	require.NoError(t, os.WriteFile(path, []byte("ERROR new file 1\nERROR new file 2\n"), 0o644))
+	c = newTestLogTail(t, path, stateFile)
+	data, err = c.Collect(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(2), *byID(data)["AppErrors"].Delta)
+}
+
+func TestLogTailMatchNegativeCounter(t *testing.T) {
+	rule := LogRule{Name: "Bytes", Pattern: `bytes=(-?\d+)`, Type: "counter", re: regexp.MustCompile(`bytes=(-?\d+)`)}
+	values := logValues{counts: map[string]int64{}, sums: map[string]float64{}, values: map[string]float64{}, hits: map[string]int{}}
+
+	match([]LogRule{rule}, []byte("bytes=10"), values)
+	match([]LogRule{rule}, []byte("bytes=-7"), values)
+	require.Equal(t, int64(10), values.counts["Bytes"])
+}
@@ -2,13 +2,16 @@
 package metrics
 
 import (
-	"math/rand"
-	"runtime"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/romanmendelproject/go-yandex-metrics/utils"
-	"github.com/shirou/gopsutil/v3/cpu"
-	"github.com/shirou/gopsutil/v3/mem"
+	log "github.com/sirupsen/logrus"
 )
 
 // Metric описывает обрабатываемые метрики
@@ -19,74 +22,180 @@ type Metric struct {
 	Value *float64 `json:"value,omitempty"` // значение метрики в случае передачи gauge
 }
 
-type Metrics struct {
-	Data      []Metric
-	PollCount int64
-}
-
-// Update получение базовых метрик
-func (m *Metrics) Update(metricsChannel chan *[]Metric) error {
-	var runtimeMetrics runtime.MemStats
-	runtime.ReadMemStats(&runtimeMetrics)
-	m.PollCount += 1
-
-	m.Data = []Metric{
-		{ID: "Alloc", MType: "gauge", Value: utils.GetFloatPtr(float64(runtimeMetrics.Alloc))},
-		{ID: "BuckHashSys", MType: "gauge", Value: utils.GetFloatPtr(float64(runtimeMetrics.BuckHashSys))},
-		{ID: "Frees", MType: "gauge", Value: utils.GetFloatPtr(float64(runtimeMetrics.Frees))},
-		{ID: "GCCPUFraction", MType: "gauge", Value: utils.GetFloatPtr(float64(runtimeMetrics.GCCPUFraction))},
-		{ID: "GCSys", MType: "gauge", Value: utils.GetFloatPtr(float64(runtimeMetrics.GCSys))},
-		{ID: "HeapAlloc", MType: "gauge", Value: utils.GetFloatPtr(float64(runtimeMetrics.HeapAlloc))},
-		{ID: "HeapIdle", MType: "gauge", Value: utils.GetFloatPtr(float64(runtimeMetrics.HeapIdle))},
-		{ID: "HeapInuse", MType: "gauge", Value: utils.GetFloatPtr(float64(runtimeMetrics.HeapInuse))},
-		{ID: "HeapObjects", MType: "gauge", Value: utils.GetFloatPtr(float64(runtimeMetrics.HeapObjects))},
-		{ID: "HeapReleased", MType: "gauge", Value: utils.GetFloatPtr(float64(runtimeMetrics.HeapReleased))},
-		{ID: "HeapSys", MType: "gauge", Value: utils.GetFloatPtr(float64(runtimeMetrics.HeapSys))},
-		{ID: "LastGC", MType: "gauge", Value: utils.GetFloatPtr(float64(runtimeMetrics.LastGC))},
-		{ID: "Lookups", MType: "gauge", Value: utils.GetFloatPtr(float64(runtimeMetrics.Lookups))},
-		{ID: "MCacheInuse", MType: "gauge", Value: utils.GetFloatPtr(float64(runtimeMetrics.MCacheInuse))},
-		{ID: "MCacheSys", MType: "gauge", Value: utils.GetFloatPtr(float64(runtimeMetrics.MCacheSys))},
-		{ID: "MSpanInuse", MType: "gauge", Value: utils.GetFloatPtr(float64(runtimeMetrics.MSpanInuse))},
-		{ID: "MSpanSys", MType: "gauge", Value: utils.GetFloatPtr(float64(runtimeMetrics.MSpanSys))},
-		{ID: "Mallocs", MType: "gauge", Value: utils.GetFloatPtr(float64(runtimeMetrics.Mallocs))},
-		{ID: "NextGC", MType: "gauge", Value: utils.GetFloatPtr(float64(runtimeMetrics.NextGC))},
-		{ID: "NumForcedGC", MType: "gauge", Value: utils.GetFloatPtr(float64(runtimeMetrics.NumForcedGC))},
-		{ID: "NumGC", MType: "gauge", Value: utils.GetFloatPtr(float64(runtimeMetrics.NumGC))},
-		{ID: "OtherSys", MType: "gauge", Value: utils.GetFloatPtr(float64(runtimeMetrics.OtherSys))},
-		{ID: "PauseTotalNs", MType: "gauge", Value: utils.GetFloatPtr(float64(runtimeMetrics.PauseTotalNs))},
-		{ID: "StackInuse", MType: "gauge", Value: utils.GetFloatPtr(float64(runtimeMetrics.StackInuse))},
-		{ID: "StackSys", MType: "gauge", Value: utils.GetFloatPtr(float64(runtimeMetrics.StackSys))},
-		{ID: "Sys", MType: "gauge", Value: utils.GetFloatPtr(float64(runtimeMetrics.Sys))},
-		{ID: "TotalAlloc", MType: "gauge", Value: utils.GetFloatPtr(float64(runtimeMetrics.TotalAlloc))},
-		{ID: "RandomValue", MType: "gauge", Value: utils.GetFloatPtr(rand.Float64())},
-		{ID: "PollCount", MType: "counter", Delta: &m.PollCount},
+// Collector источник метрик. Каждый вызов Collect возвращает отдельную пачку
+type Collector interface {
+	Name() string
+	Collect(ctx context.Context) ([]Metric, error)
+}
+
+// Factory создает сборщик по его настройкам из конфигурации агента
+type Factory func(options json.RawMessage) (Collector, error)
+
+// Registration описывает зарегистрированный сборщик
+type Registration struct {
+	Name    string
+	Enabled bool // включен ли сборщик, если в конфигурации не указано иное
+	New     Factory
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = make(map[string]Registration)
+)
+
+// Register регистрирует сборщик. Вызывается из init файла сборщика
+func Register(name string, enabled bool, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("metrics: collector %s registered twice", name))
+	}
+	registry[name] = Registration{Name: name, Enabled: enabled, New: factory}
+}
+
+// Registered возвращает зарегистрированные сборщики в порядке имен
+func Registered() []Registration {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	registrations := make([]Registration, 0, len(registry))
+	for _, r := range registry {
+		registrations = append(registrations, r)
+	}
+	sort.Slice(registrations, func(i, j int) bool {
+		return registrations[i].Name < registrations[j].Name
+	})
+	return registrations
+}
+
+// Run вызывает сборщик с интервалом interval и отправляет каждую пачку в metricsChannel
+func Run(ctx context.Context, wg *sync.WaitGroup, c Collector, interval time.Duration, metricsChannel chan<- *[]Metric) {
+	defer wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			data, err := c.Collect(ctx)
+			if err != nil {
+				log.Errorf("Collector %s: %s", c.Name(), err)
+				continue
+			}
+			if len(data) == 0 {
+				continue
+			}
+			select {
+			case metricsChannel <- &data:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// decodeOptions разбирает настройки сборщика. Пустые настройки оставляют значения по умолчанию
+func decodeOptions(options json.RawMessage, v any) error {
+	if len(options) == 0 {
+		return nil
 	}
-	metricsChannel <- &m.Data
+	return json.Unmarshal(options, v)
+}
+
+// gauge создает метрику типа gauge
+func gauge(id string, value float64) Metric {
+	return Metric{ID: id, MType: "gauge", Value: &value}
+}
 
-	return nil
+// counter создает метрику типа counter
+func counter(id string, delta int64) Metric {
+	return Metric{ID: id, MType: "counter", Delta: &delta}
 }
 
-// UpdateGopsUtil получение расширенных метрик
-func (m *Metrics) UpdateGopsUtil(metricsChannel chan *[]Metric) error {
-	memory, err := mem.VirtualMemory()
-	if err != nil {
-		return err
+// Filter отбирает значения по шаблонам path.Match. Пустой Include пропускает все,
+// что не попало в Exclude
+type Filter struct {
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// Match сообщает, проходит ли значение фильтр
+func (f Filter) Match(value string) bool {
+	matchAny := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, value); ok {
+				return true
+			}
+		}
+		return false
 	}
+	if len(f.Include) > 0 && !matchAny(f.Include) {
+		return false
+	}
+	return !matchAny(f.Exclude)
+}
 
-	cpuUtilMetrics, err := cpu.Percent(time.Millisecond*100, true)
-	if err != nil {
-		return err
+// labeled добавляет к имени метрики метку, например точку монтирования или устройство.
+// Метка кодируется без потерь, чтобы разные метки не давали одно имя. В абсолютном пути
+// / заменяется на _, а сам символ _ экранируется; в остальных метках _ сохраняется,
+// а / и _ в начале экранируются, поэтому только пути начинаются с _. Экранируются
+// символы, недопустимые в имени метрики, и : — как : и шестнадцатеричный код байта
+func labeled(name, label string) string {
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte('.')
+	absolute := strings.HasPrefix(label, "/")
+	for i := 0; i < len(label); i++ {
+		switch c := label[i]; {
+		case c == '/' && absolute:
+			b.WriteByte('_')
+		case c == '_' && !absolute && i > 0:
+			b.WriteByte(c)
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '.', c == '-':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, ":%02x", c)
+		}
 	}
+	return b.String()
+}
+
+// deltas вычисляет приращения накопительных счетчиков между вызовами сборщика.
+// Базы счетчиков, не встретившихся в очередном сборе, удаляются prune
+type deltas struct {
+	last map[string]uint64
+	seen map[string]bool
+}
+
+func newDeltas() *deltas {
+	return &deltas{last: make(map[string]uint64), seen: make(map[string]bool)}
+}
 
-	var cpuUtilMetric float64
-	for _, cpuUtilItem := range cpuUtilMetrics {
-		cpuUtilMetric += cpuUtilItem
+// delta возвращает приращение счетчика key с прошлого вызова. При первом вызове
+// значение только запоминается, при сбросе счетчика приращением считается текущее значение
+func (d *deltas) delta(key string, current uint64) (int64, bool) {
+	d.seen[key] = true
+	prev, ok := d.last[key]
+	d.last[key] = current
+	if !ok {
+		return 0, false
+	}
+	if current < prev {
+		return int64(current), true
 	}
-	m.Data = []Metric{
-		{ID: "TotalMemory", MType: "gauge", Value: utils.GetFloatPtr(float64(memory.Total))},
-		{ID: "FreeMemory", MType: "gauge", Value: utils.GetFloatPtr(float64(memory.Free))},
-		{ID: "CPUutilization1", MType: "gauge", Value: utils.GetFloatPtr(cpuUtilMetric)},
+	return int64(current - prev), true
+}
+
+// prune удаляет базы счетчиков, не встретившихся со времени прошлого вызова,
+// например исчезнувших устройств и интерфейсов
+func (d *deltas) prune() {
+	for key := range d.last {
+		if !d.seen[key] {
+			delete(d.last, key)
+		}
 	}
-	metricsChannel <- &m.Data
-	return nil
+	clear(d.seen)
 }
@@ -1,29 +1,21 @@
 package metrics
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
 	"testing"
-)
-
-func TestUpdate(t *testing.T) {
-	metrics := Metrics{}
-	metricsChannel := make(chan *[]Metric)
+	"time"
 
-	go func() {
-		err := metrics.Update(metricsChannel)
-		if err != nil {
-			t.Errorf("Expected no error, got %v", err)
-		}
-	}()
-
-	metricsData := <-metricsChannel
-
-	if len(*metricsData) == 0 {
-		t.Error("Expected metrics data, got none")
-	}
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/stretchr/testify/require"
+)
 
-	if metrics.PollCount != 1 {
-		t.Errorf("Expected PollCount 1, got %d", metrics.PollCount)
-	}
+func TestRuntimeCollector(t *testing.T) {
+	c := &RuntimeCollector{}
+	metricsData, err := c.Collect(context.Background())
+	require.NoError(t, err)
 
 	expectedIDs := []string{
 		"Alloc", "BuckHashSys", "Frees", "GCCPUFraction", "GCSys",
@@ -34,34 +26,164 @@ func TestUpdate(t *testing.T) {
 		"Sys", "TotalAlloc", "RandomValue", "PollCount",
 	}
 
+	ids := make(map[string]Metric)
+	for _, metric := range metricsData {
+		ids[metric.ID] = metric
+	}
 	for _, expectedID := range expectedIDs {
-		found := false
-		for _, metric := range *metricsData {
-			if metric.ID == expectedID {
-				found = true
-				break
-			}
-		}
-		if !found {
-			t.Errorf("Expected metric ID %s not found", expectedID)
-		}
+		require.Contains(t, ids, expectedID)
+	}
+	require.Equal(t, int64(1), *ids["PollCount"].Delta)
+}
+
+func TestSystemCollector(t *testing.T) {
+	c := NewSystemCollector()
+	metricsData, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	require.Len(t, metricsData, 2, "cpu utilization needs two polls")
+
+	times := []cpu.TimesStat{{User: 10, Idle: 90}, {User: 50, Idle: 50}}
+	c.times = func(ctx context.Context, percpu bool) ([]cpu.TimesStat, error) {
+		require.True(t, percpu)
+		return times, nil
+	}
+	_, err = c.Collect(context.Background())
+	require.NoError(t, err)
+
+	times = []cpu.TimesStat{{User: 20, Idle: 180}, {User: 150, Idle: 50}}
+	metricsData, err = c.Collect(context.Background())
+	require.NoError(t, err)
+
+	ids := byID(metricsData)
+	require.Len(t, ids, 4)
+	require.Contains(t, ids, "TotalMemory")
+	require.InDelta(t, 10, *ids["CPUutilization1"].Value, 1e-9)
+	require.InDelta(t, 100, *ids["CPUutilization2"].Value, 1e-9)
+}
+
+func TestRegistered(t *testing.T) {
+	var names []string
+	for _, r := range Registered() {
+		names = append(names, r.Name)
+	}
+	require.Subset(t, names, []string{"runtime", "system"})
+	require.IsIncreasing(t, names)
+
+	require.Panics(t, func() {
+		Register("runtime", true, func(options json.RawMessage) (Collector, error) { return nil, nil })
+	})
+}
+
+type stubCollector struct {
+	calls int
+	err   error
+}
+
+func (c *stubCollector) Name() string {
+	return "stub"
+}
+
+func (c *stubCollector) Collect(ctx context.Context) ([]Metric, error) {
+	c.calls++
+	if c.err != nil {
+		return nil, c.err
 	}
+	return []Metric{gauge("stub", float64(c.calls))}, nil
 }
 
-func TestUpdateGopsUtil(t *testing.T) {
-	metrics := Metrics{}
+func TestRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
 	metricsChannel := make(chan *[]Metric)
 
-	go func() {
-		err := metrics.UpdateGopsUtil(metricsChannel)
-		if err != nil {
-			t.Errorf("Expected no error, got %v", err)
-		}
-	}()
+	wg.Add(1)
+	go Run(ctx, wg, &stubCollector{}, time.Millisecond, metricsChannel)
+
+	// Каждый вызов сборщика отправляется отдельной пачкой
+	first := <-metricsChannel
+	second := <-metricsChannel
+	require.Equal(t, float64(1), *(*first)[0].Value)
+	require.Equal(t, float64(2), *(*second)[0].Value)
 
-	metricsData := <-metricsChannel
+	cancel()
+	wg.Wait()
+}
+
+func TestRunSkipsErrors(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	wg := &sync.WaitGroup{}
+	metricsChannel := make(chan *[]Metric, 10)
+
+	c := &stubCollector{err: errors.New("unavailable")}
+	wg.Add(1)
+	Run(ctx, wg, c, time.Millisecond, metricsChannel)
+
+	require.Positive(t, c.calls)
+	require.Empty(t, metricsChannel)
+}
 
-	if len(*metricsData) == 0 {
-		t.Error("Expected metrics data, got none")
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		value  string
+		want   bool
+	}{
+		{"empty filter", Filter{}, "/", true},
+		{"included", Filter{Include: []string{"/var/*"}}, "/var/lib", true},
+		{"not included", Filter{Include: []string{"/var/*"}}, "/home", false},
+		{"excluded", Filter{Exclude: []string{"loop*"}}, "loop0", false},
+		{"exclude wins", Filter{Include: []string{"*"}, Exclude: []string{"lo"}}, "lo", false},
 	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.filter.Match(tt.value))
+		})
+	}
+}
+
+func TestLabeled(t *testing.T) {
+	tests := []struct {
+		label string
+		want  string
+	}{
+		{"/", "DiskUsedBytes._"},
+		{"/root", "DiskUsedBytes._root"},
+		{"/var/log", "DiskUsedBytes._var_log"},
+		{"/var_log", "DiskUsedBytes._var:5flog"},
+		{`C:\`, "DiskUsedBytes.C:3a:5c"},
+		{"eth0.100", "DiskUsedBytes.eth0.100"},
+		{"CLOSE_WAIT", "DiskUsedBytes.CLOSE_WAIT"},
+		{"var/log", "DiskUsedBytes.var:2flog"},
+		{"_var_log", "DiskUsedBytes.:5fvar_log"},
+	}
+
+	seen := make(map[string]string)
+	for _, tt := range tests {
+		id := labeled("DiskUsedBytes", tt.label)
+		require.Equal(t, tt.want, id)
+		require.NotContains(t, seen, id, "label %q collides with %q", tt.label, seen[id])
+		seen[id] = tt.label
+	}
+}
+
+func TestDeltasPrune(t *testing.T) {
+	d := newDeltas()
+	d.delta("sda", 10)
+	d.delta("sdb", 10)
+	d.prune()
+
+	delta, ok := d.delta("sda", 15)
+	require.True(t, ok)
+	require.Equal(t, int64(5), delta)
+	d.prune()
+
+	// sdb не встретился в прошлом сборе, и его база удалена
+	_, ok = d.delta("sdb", 20)
+	require.False(t, ok)
+	require.Len(t, d.last, 2)
+	d.prune()
+	d.prune()
+	require.Empty(t, d.last)
 }
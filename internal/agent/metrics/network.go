@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/shirou/gopsutil/v3/net"
+)
+
+func init() {
+	Register("net", true, func(options json.RawMessage) (Collector, error) {
+		return NewNetCollector(options)
+	})
+	Register("tcp", false, func(options json.RawMessage) (Collector, error) {
+		return NewTCPCollector(), nil
+	})
+}
+
+// NetOptions настройки сборщика net
+type NetOptions struct {
+	Interfaces Filter `json:"interfaces"`
+}
+
+// NetCollector собирает переданные и полученные байты и пакеты, ошибки и потери
+// по сетевым интерфейсам. Значения отправляются счетчиками с приращением с прошлого опроса
+type NetCollector struct {
+	options    NetOptions
+	ioCounters func(ctx context.Context, pernic bool) ([]net.IOCountersStat, error)
+	last       *deltas
+}
+
+// NewNetCollector создает NetCollector. По умолчанию исключен интерфейс lo
+func NewNetCollector(options json.RawMessage) (*NetCollector, error) {
+	c := &NetCollector{
+		options:    NetOptions{Interfaces: Filter{Exclude: []string{"lo"}}},
+		ioCounters: net.IOCountersWithContext,
+		last:       newDeltas(),
+	}
+	if err := decodeOptions(options, &c.options); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Name возвращает имя сборщика
+func (c *NetCollector) Name() string {
+	return "net"
+}
+
+// Collect возвращает приращения счетчиков интерфейсов. Первый опрос только запоминает значения
+func (c *NetCollector) Collect(ctx context.Context) ([]Metric, error) {
+	counters, err := c.ioCounters(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []Metric
+	for _, io := range counters {
+		if !c.options.Interfaces.Match(io.Name) {
+			continue
+		}
+		for _, v := range []struct {
+			id    string
+			value uint64
+		}{
+			{labeled("NetBytesRecv", io.Name), io.BytesRecv},
+			{labeled("NetBytesSent", io.Name), io.BytesSent},
+			{labeled("NetPacketsRecv", io.Name), io.PacketsRecv},
+			{labeled("NetPacketsSent", io.Name), io.PacketsSent},
+			{labeled("NetErrorsIn", io.Name), io.Errin},
+			{labeled("NetErrorsOut", io.Name), io.Errout},
+			{labeled("NetDropsIn", io.Name), io.Dropin},
+			{labeled("NetDropsOut", io.Name), io.Dropout},
+		} {
+			if delta, ok := c.last.delta(v.id, v.value); ok {
+				data = append(data, counter(v.id, delta))
+			}
+		}
+	}
+	c.last.prune()
+	return data, nil
+}
+
+// tcpStates состояния TCP соединений, которые отправляются всегда, даже с нулевым числом
+var tcpStates = []string{
+	"ESTABLISHED", "SYN_SENT", "SYN_RECV", "FIN_WAIT1", "FIN_WAIT2", "TIME_WAIT",
+	"CLOSE", "CLOSE_WAIT", "LAST_ACK", "LISTEN", "CLOSING",
+}
+
+// TCPCollector собирает число TCP соединений по состояниям
+type TCPCollector struct {
+	connections func(ctx context.Context, kind string) ([]net.ConnectionStat, error)
+}
+
+// NewTCPCollector создает TCPCollector
+func NewTCPCollector() *TCPCollector {
+	return &TCPCollector{connections: net.ConnectionsWithoutUidsWithContext}
+}
+
+// Name возвращает имя сборщика
+func (c *TCPCollector) Name() string {
+	return "tcp"
+}
+
+// Collect возвращает общее число TCP соединений и их число в каждом состоянии
+func (c *TCPCollector) Collect(ctx context.Context) ([]Metric, error) {
+	connections, err := c.connections(ctx, "tcp")
+	if err != nil {
+		return nil, err
+	}
+
+	states := make(map[string]int, len(tcpStates))
+	for _, conn := range connections {
+		states[conn.Status]++
+	}
+
+	data := []Metric{gauge("TCPConnections", float64(len(connections)))}
+	for _, state := range tcpStates {
+		data = append(data, gauge(labeled("TCPConnections", state), float64(states[state])))
+	}
+	return data, nil
+}
@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shirou/gopsutil/v3/net"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetCollector(t *testing.T) {
+	c, err := NewNetCollector(nil)
+	require.NoError(t, err)
+
+	stats := []net.IOCountersStat{
+		{Name: "eth0", BytesRecv: 1000, BytesSent: 500, PacketsRecv: 10, PacketsSent: 5},
+		{Name: "lo", BytesRecv: 100, BytesSent: 100},
+	}
+	c.ioCounters = func(ctx context.Context, pernic bool) ([]net.IOCountersStat, error) {
+		return stats, nil
+	}
+
+	data, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, data, "first poll only remembers counters")
+
+	stats[0] = net.IOCountersStat{Name: "eth0", BytesRecv: 1800, BytesSent: 500, PacketsRecv: 18, PacketsSent: 5, Errin: 1, Dropout: 2}
+	data, err = c.Collect(context.Background())
+	require.NoError(t, err)
+
+	ids := byID(data)
+	require.Len(t, ids, 8)
+	require.Equal(t, int64(800), *ids["NetBytesRecv.eth0"].Delta)
+	require.Equal(t, int64(0), *ids["NetBytesSent.eth0"].Delta)
+	require.Equal(t, int64(8), *ids["NetPacketsRecv.eth0"].Delta)
+	require.Equal(t, int64(1), *ids["NetErrorsIn.eth0"].Delta)
+	require.Equal(t, int64(2), *ids["NetDropsOut.eth0"].Delta)
+	require.NotContains(t, ids, "NetBytesRecv.lo")
+}
+
+func TestNetCollectorInterfaces(t *testing.T) {
+	c, err := NewNetCollector([]byte(`{"interfaces": {"include": ["eth*"], "exclude": []}}`))
+	require.NoError(t, err)
+	require.True(t, c.options.Interfaces.Match("eth1"))
+	require.False(t, c.options.Interfaces.Match("docker0"))
+}
+
+func TestTCPCollector(t *testing.T) {
+	c := NewTCPCollector()
+	c.connections = func(ctx context.Context, kind string) ([]net.ConnectionStat, error) {
+		require.Equal(t, "tcp", kind)
+		return []net.ConnectionStat{
+			{Status: "ESTABLISHED"}, {Status: "ESTABLISHED"}, {Status: "LISTEN"}, {Status: "TIME_WAIT"},
+		}, nil
+	}
+
+	data, err := c.Collect(context.Background())
+	require.NoError(t, err)
+
+	ids := byID(data)
+	require.Len(t, ids, len(tcpStates)+1)
+	require.Equal(t, float64(4), *ids["TCPConnections"].Value)
+	require.Equal(t, float64(2), *ids["TCPConnections.ESTABLISHED"].Value)
+	require.Equal(t, float64(0), *ids["TCPConnections.CLOSE_WAIT"].Value)
+}
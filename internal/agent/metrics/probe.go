@@ -0,0 +1,205 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	Register("probe", false, func(options json.RawMessage) (Collector, error) {
+		return NewProbeCollector(options)
+	})
+}
+
+// Виды проверок
+const (
+	ProbeHTTP = "http"
+	ProbeTCP  = "tcp"
+	ProbeDNS  = "dns"
+)
+
+const (
+	// defaultProbeTimeout время проверки по умолчанию
+	defaultProbeTimeout = 5 * time.Second
+	// maxProbeBody объем тела ответа, в котором ищется expect_body
+	maxProbeBody = 1 << 20
+)
+
+// ProbeTarget проверяемая цель
+type ProbeTarget struct {
+	Name         string `json:"name"`          // имя цели, метка метрик
+	Type         string `json:"type"`          // http, tcp или dns
+	Address      string `json:"address"`       // URL для http, host:port для tcp, имя хоста для dns
+	Timeout      int    `json:"timeout"`       // в секундах, по умолчанию 5
+	ExpectStatus []int  `json:"expect_status"` // допустимые коды ответа http, по умолчанию 2xx
+	ExpectBody   string `json:"expect_body"`   // регулярное выражение для тела ответа http
+
+	body *regexp.Regexp
+}
+
+// ProbeOptions настройки сборщика probe
+type ProbeOptions struct {
+	Targets []ProbeTarget `json:"targets"`
+}
+
+// ProbeCollector проверяет доступность целей по HTTP, TCP и DNS. Для каждой цели отправляются
+// ProbeSuccess (1 или 0), ProbeDurationSeconds и счетчик неудач ProbeFailures
+type ProbeCollector struct {
+	options  ProbeOptions
+	client   *http.Client
+	dialer   *net.Dialer
+	resolver *net.Resolver
+}
+
+// NewProbeCollector создает ProbeCollector. Каждая цель должна иметь уникальное имя, вид и адрес
+func NewProbeCollector(options json.RawMessage) (*ProbeCollector, error) {
+	c := &ProbeCollector{
+		client:   &http.Client{},
+		dialer:   &net.Dialer{},
+		resolver: net.DefaultResolver,
+	}
+	if err := decodeOptions(options, &c.options); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for i := range c.options.Targets {
+		t := &c.options.Targets[i]
+		if t.Name == "" {
+			return nil, errors.New("probe target without name")
+		}
+		if seen[t.Name] {
+			return nil, fmt.Errorf("probe target %s defined twice", t.Name)
+		}
+		seen[t.Name] = true
+		if t.Address == "" {
+			return nil, fmt.Errorf("probe target %s: address is required", t.Name)
+		}
+		switch t.Type {
+		case ProbeHTTP, ProbeTCP, ProbeDNS:
+		default:
+			return nil, fmt.Errorf("probe target %s: unknown type %q", t.Name, t.Type)
+		}
+		if t.ExpectBody != "" {
+			re, err := regexp.Compile(t.ExpectBody)
+			if err != nil {
+				return nil, fmt.Errorf("probe target %s: %w", t.Name, err)
+			}
+			t.body = re
+		}
+	}
+	return c, nil
+}
+
+// Name возвращает имя сборщика
+func (c *ProbeCollector) Name() string {
+	return "probe"
+}
+
+// Collect параллельно проверяет все цели
+func (c *ProbeCollector) Collect(ctx context.Context) ([]Metric, error) {
+	results := make([][]Metric, len(c.options.Targets))
+	var wg sync.WaitGroup
+	for i, t := range c.options.Targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			err := c.probe(ctx, t)
+			duration := time.Since(start).Seconds()
+
+			var success float64 = 1
+			var failed int64
+			if err != nil {
+				log.Warnf("Probe %s: %s", t.Name, err)
+				success, failed = 0, 1
+			}
+			results[i] = []Metric{
+				gauge(labeled("ProbeSuccess", t.Name), success),
+				gauge(labeled("ProbeDurationSeconds", t.Name), duration),
+				counter(labeled("ProbeFailures", t.Name), failed),
+			}
+		}()
+	}
+	wg.Wait()
+
+	var data []Metric
+	for _, result := range results {
+		data = append(data, result...)
+	}
+	return data, nil
+}
+
+// probe выполняет одну проверку цели с таймаутом
+func (c *ProbeCollector) probe(ctx context.Context, t ProbeTarget) error {
+	timeout := defaultProbeTimeout
+	if t.Timeout > 0 {
+		timeout = time.Duration(t.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	switch t.Type {
+	case ProbeHTTP:
+		return c.probeHTTP(ctx, t)
+	case ProbeTCP:
+		conn, err := c.dialer.DialContext(ctx, "tcp", t.Address)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	default:
+		addrs, err := c.resolver.LookupHost(ctx, t.Address)
+		if err != nil {
+			return err
+		}
+		if len(addrs) == 0 {
+			return fmt.Errorf("no addresses for %s", t.Address)
+		}
+		return nil
+	}
+}
+
+// probeHTTP выполняет GET запрос и проверяет код и тело ответа
+func (c *ProbeCollector) probeHTTP(ctx context.Context, t ProbeTarget) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.Address, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if len(t.ExpectStatus) > 0 {
+		if !slices.Contains(t.ExpectStatus, resp.StatusCode) {
+			return fmt.Errorf("unexpected status %d", resp.StatusCode)
+		}
+	} else if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	if t.body == nil {
+		return nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxProbeBody))
+	if err != nil {
+		return err
+	}
+	if !t.body.Match(body) {
+		return fmt.Errorf("body does not match %q", t.ExpectBody)
+	}
+	return nil
+}
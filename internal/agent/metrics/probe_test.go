@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProbeCollector(t *testing.T) {
+	tests := []struct {
+		name    string
+		options string
+		wantErr bool
+	}{
+		{name: "valid", options: `{"targets": [{"name": "site", "type": "http", "address": "http://localhost", "expect_body": "ok"}]}`},
+		{name: "without name", options: `{"targets": [{"type": "tcp", "address": "localhost:80"}]}`, wantErr: true},
+		{name: "duplicate name", options: `{"targets": [{"name": "a", "type": "dns", "address": "a"}, {"name": "a", "type": "dns", "address": "b"}]}`, wantErr: true},
+		{name: "without address", options: `{"targets": [{"name": "a", "type": "tcp"}]}`, wantErr: true},
+		{name: "unknown type", options: `{"targets": [{"name": "a", "type": "icmp", "address": "a"}]}`, wantErr: true},
+		{name: "invalid body", options: `{"targets": [{"name": "a", "type": "http", "address": "http://a", "expect_body": "("}]}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewProbeCollector([]byte(tt.options))
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestProbeCollector(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer server.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	open := listener.Addr().String()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closedAddr := closed.Addr().String()
+	closed.Close()
+	defer listener.Close()
+
+	c, err := NewProbeCollector([]byte(`{"targets": [
+		{"name": "health", "type": "http", "address": "` + server.URL + `/health", "expect_body": "\"status\": \"ok\""},
+		{"name": "missing", "type": "http", "address": "` + server.URL + `/missing"},
+		{"name": "not_found_expected", "type": "http", "address": "` + server.URL + `/missing", "expect_status": [404]},
+		{"name": "wrong_body", "type": "http", "address": "` + server.URL + `/health", "expect_body": "down"},
+		{"name": "tcp_open", "type": "tcp", "address": "` + open + `"},
+		{"name": "tcp_closed", "type": "tcp", "address": "` + closedAddr + `"},
+		{"name": "dns", "type": "dns", "address": "localhost"},
+		{"name": "dns_unknown", "type": "dns", "address": "unknown.invalid"}
+	]}`))
+	require.NoError(t, err)
+	// Резолвер без DNS серверов: localhost разрешается по /etc/hosts, остальные имена — нет
+	c.resolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return nil, errors.New("dns is not available in tests")
+		},
+	}
+
+	data, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	ids := byID(data)
+	require.Len(t, ids, 8*3)
+
+	for name, success := range map[string]bool{
+		"health": true, "missing": false, "not_found_expected": true, "wrong_body": false,
+		"tcp_open": true, "tcp_closed": false, "dns": true, "dns_unknown": false,
+	} {
+		var wantSuccess float64
+		var wantFailures int64 = 1
+		if success {
+			wantSuccess, wantFailures = 1, 0
+		}
+		require.Equal(t, wantSuccess, *ids["ProbeSuccess."+name].Value, name)
+		require.Equal(t, wantFailures, *ids["ProbeFailures."+name].Delta, name)
+		require.GreaterOrEqual(t, *ids["ProbeDurationSeconds."+name].Value, float64(0), name)
+	}
+}
@@ -0,0 +1,256 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+func init() {
+	Register("process", false, func(options json.RawMessage) (Collector, error) {
+		return NewProcessCollector(options)
+	})
+}
+
+// ProcessGroup правило отбора процессов группы. Заданные условия должны выполняться одновременно
+type ProcessGroup struct {
+	Group   string `json:"group"`   // имя группы, становится меткой метрик
+	Name    string `json:"name"`    // шаблон path.Match имени процесса
+	Cmdline string `json:"cmdline"` // регулярное выражение для командной строки
+	Pidfile string `json:"pidfile"` // файл с PID процесса, перечитывается при каждом опросе
+
+	cmdline *regexp.Regexp
+}
+
+// ProcessOptions настройки сборщика process
+type ProcessOptions struct {
+	Groups []ProcessGroup `json:"groups"`
+}
+
+// processInfo процесс из списка процессов системы
+type processInfo struct {
+	PID     int32
+	Name    string
+	Cmdline string
+}
+
+// processStat потребление ресурсов процессом
+type processStat struct {
+	CreateTime time.Time
+	CPUSeconds float64 // процессорное время user и system
+	RSS        uint64
+	FDs        int32
+	Threads    int32
+}
+
+// processCPU процессорное время процесса при прошлом опросе
+type processCPU struct {
+	seconds float64
+	at      time.Time
+}
+
+// ProcessCollector собирает загрузку CPU, память, открытые файлы, потоки и время работы
+// процессов, отобранных по группам. Значения процессов группы суммируются
+type ProcessCollector struct {
+	options     ProcessOptions
+	withCmdline bool
+	list        func(ctx context.Context, withCmdline bool) ([]processInfo, error)
+	stat        func(ctx context.Context, pid int32) (processStat, error)
+	readFile    func(name string) ([]byte, error)
+	now         func() time.Time
+	last        map[string]processCPU
+}
+
+// NewProcessCollector создает ProcessCollector. Каждая группа должна иметь уникальное имя
+// и хотя бы одно условие отбора
+func NewProcessCollector(options json.RawMessage) (*ProcessCollector, error) {
+	c := &ProcessCollector{
+		list:     listProcesses,
+		stat:     statProcess,
+		readFile: os.ReadFile,
+		now:      time.Now,
+		last:     make(map[string]processCPU),
+	}
+	if err := decodeOptions(options, &c.options); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for i := range c.options.Groups {
+		g := &c.options.Groups[i]
+		if g.Group == "" {
+			return nil, errors.New("process group without name")
+		}
+		if seen[g.Group] {
+			return nil, fmt.Errorf("process group %s defined twice", g.Group)
+		}
+		seen[g.Group] = true
+		if g.Name == "" && g.Cmdline == "" && g.Pidfile == "" {
+			return nil, fmt.Errorf("process group %s: name, cmdline or pidfile is required", g.Group)
+		}
+		if g.Cmdline != "" {
+			re, err := regexp.Compile(g.Cmdline)
+			if err != nil {
+				return nil, fmt.Errorf("process group %s: %w", g.Group, err)
+			}
+			g.cmdline = re
+			c.withCmdline = true
+		}
+	}
+	return c, nil
+}
+
+// Name возвращает имя сборщика
+func (c *ProcessCollector) Name() string {
+	return "process"
+}
+
+// Collect возвращает суммарные показатели процессов каждой группы. Загрузка CPU считается
+// между опросами, поэтому в первом опросе процесса она нулевая
+func (c *ProcessCollector) Collect(ctx context.Context) ([]Metric, error) {
+	processes, err := c.list(ctx, c.withCmdline)
+	if err != nil {
+		return nil, err
+	}
+
+	now := c.now()
+	stats := make(map[int32]*processStat)
+	last := make(map[string]processCPU)
+	var data []Metric
+	for _, g := range c.options.Groups {
+		pid, hasPid := c.pidfile(g)
+
+		var count, fds, threads int64
+		var cpu, rss, uptime float64
+		for _, p := range processes {
+			if !g.match(p, pid, hasPid) {
+				continue
+			}
+			st, ok := stats[p.PID]
+			if !ok {
+				s, err := c.stat(ctx, p.PID)
+				if err != nil {
+					// Процесс мог завершиться после получения списка
+					continue
+				}
+				st = &s
+				stats[p.PID] = st
+			}
+
+			key := strconv.Itoa(int(p.PID)) + "@" + strconv.FormatInt(st.CreateTime.UnixMilli(), 10)
+			if prev, ok := c.last[key]; ok && now.After(prev.at) && st.CPUSeconds >= prev.seconds {
+				cpu += (st.CPUSeconds - prev.seconds) / now.Sub(prev.at).Seconds() * 100
+			}
+			last[key] = processCPU{seconds: st.CPUSeconds, at: now}
+
+			count++
+			fds += int64(st.FDs)
+			threads += int64(st.Threads)
+			rss += float64(st.RSS)
+			uptime = max(uptime, now.Sub(st.CreateTime).Seconds())
+		}
+
+		data = append(data,
+			gauge(labeled("ProcessCount", g.Group), float64(count)),
+			gauge(labeled("ProcessCPUPercent", g.Group), cpu),
+			gauge(labeled("ProcessRSSBytes", g.Group), rss),
+			gauge(labeled("ProcessOpenFDs", g.Group), float64(fds)),
+			gauge(labeled("ProcessThreads", g.Group), float64(threads)),
+			gauge(labeled("ProcessUptimeSeconds", g.Group), uptime),
+		)
+	}
+	// Завершившиеся процессы забываются
+	c.last = last
+	return data, nil
+}
+
+// pidfile читает PID из pidfile группы. Отсутствующий или поврежденный файл не совпадает ни с одним процессом
+func (c *ProcessCollector) pidfile(g ProcessGroup) (int32, bool) {
+	if g.Pidfile == "" {
+		return 0, false
+	}
+	content, err := c.readFile(g.Pidfile)
+	if err != nil {
+		return -1, true
+	}
+	pid, err := strconv.ParseInt(strings.TrimSpace(string(content)), 10, 32)
+	if err != nil {
+		return -1, true
+	}
+	return int32(pid), true
+}
+
+// match проверяет, входит ли процесс в группу
+func (g ProcessGroup) match(p processInfo, pid int32, hasPid bool) bool {
+	if hasPid && p.PID != pid {
+		return false
+	}
+	if g.Name != "" && !(Filter{Include: []string{g.Name}}).Match(p.Name) {
+		return false
+	}
+	return g.cmdline == nil || g.cmdline.MatchString(p.Cmdline)
+}
+
+// listProcesses возвращает процессы системы с именами и, при необходимости, командными строками
+func listProcesses(ctx context.Context, withCmdline bool) ([]processInfo, error) {
+	processes, err := process.ProcessesWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]processInfo, 0, len(processes))
+	for _, p := range processes {
+		name, err := p.NameWithContext(ctx)
+		if err != nil {
+			continue
+		}
+		info := processInfo{PID: p.Pid, Name: name}
+		if withCmdline {
+			info.Cmdline, _ = p.CmdlineWithContext(ctx)
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// statProcess возвращает потребление ресурсов процессом. Число открытых файлов
+// чужих процессов без прав недоступно и считается нулевым
+func statProcess(ctx context.Context, pid int32) (processStat, error) {
+	p, err := process.NewProcessWithContext(ctx, pid)
+	if err != nil {
+		return processStat{}, err
+	}
+	created, err := p.CreateTimeWithContext(ctx)
+	if err != nil {
+		return processStat{}, err
+	}
+	times, err := p.TimesWithContext(ctx)
+	if err != nil {
+		return processStat{}, err
+	}
+	memory, err := p.MemoryInfoWithContext(ctx)
+	if err != nil {
+		return processStat{}, err
+	}
+	threads, err := p.NumThreadsWithContext(ctx)
+	if err != nil {
+		return processStat{}, err
+	}
+	fds, _ := p.NumFDsWithContext(ctx)
+
+	return processStat{
+		CreateTime: time.UnixMilli(created),
+		CPUSeconds: times.User + times.System,
+		RSS:        memory.RSS,
+		FDs:        fds,
+		Threads:    threads,
+	}, nil
+}
@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProcessCollector(t *testing.T) {
+	tests := []struct {
+		name    string
+		options string
+		wantErr bool
+	}{
+		{name: "by name", options: `{"groups": [{"group": "nginx", "name": "nginx*"}]}`},
+		{name: "by cmdline and pidfile", options: `{"groups": [{"group": "app", "cmdline": "java .*app.jar", "pidfile": "/run/app.pid"}]}`},
+		{name: "without group", options: `{"groups": [{"name": "nginx"}]}`, wantErr: true},
+		{name: "duplicate group", options: `{"groups": [{"group": "a", "name": "a"}, {"group": "a", "name": "b"}]}`, wantErr: true},
+		{name: "without rules", options: `{"groups": [{"group": "a"}]}`, wantErr: true},
+		{name: "invalid cmdline", options: `{"groups": [{"group": "a", "cmdline": "("}]}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewProcessCollector([]byte(tt.options))
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestProcessCollector(t *testing.T) {
+	c, err := NewProcessCollector([]byte(`{"groups": [
+		{"group": "nginx", "name": "nginx"},
+		{"group": "app", "cmdline": "app\\.jar"},
+		{"group": "daemon", "pidfile": "/run/daemon.pid"},
+		{"group": "missing", "pidfile": "/run/missing.pid"}
+	]}`))
+	require.NoError(t, err)
+	require.True(t, c.withCmdline)
+
+	started := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	now := started.Add(time.Minute)
+	cpu := map[int32]float64{10: 1, 11: 2, 20: 5, 30: 0}
+
+	c.now = func() time.Time { return now }
+	c.list = func(ctx context.Context, withCmdline bool) ([]processInfo, error) {
+		return []processInfo{
+			{PID: 10, Name: "nginx", Cmdline: "nginx: master"},
+			{PID: 11, Name: "nginx", Cmdline: "nginx: worker"},
+			{PID: 20, Name: "java", Cmdline: "java -jar app.jar"},
+			{PID: 30, Name: "daemon"},
+			{PID: 40, Name: "gone"},
+		}, nil
+	}
+	c.stat = func(ctx context.Context, pid int32) (processStat, error) {
+		if pid == 40 {
+			return processStat{}, errors.New("process exited")
+		}
+		return processStat{CreateTime: started.Add(time.Duration(pid) * time.Second), CPUSeconds: cpu[pid], RSS: 1024, FDs: 3, Threads: 2}, nil
+	}
+	c.readFile = func(name string) ([]byte, error) {
+		if name == "/run/daemon.pid" {
+			return []byte("30\n"), nil
+		}
+		return nil, errors.New("no such file")
+	}
+
+	data, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	ids := byID(data)
+	require.Len(t, ids, 4*6)
+	require.Equal(t, float64(2), *ids["ProcessCount.nginx"].Value)
+	require.Equal(t, float64(2048), *ids["ProcessRSSBytes.nginx"].Value)
+	require.Equal(t, float64(6), *ids["ProcessOpenFDs.nginx"].Value)
+	require.Equal(t, float64(4), *ids["ProcessThreads.nginx"].Value)
+	require.Equal(t, float64(50), *ids["ProcessUptimeSeconds.nginx"].Value)
+	require.Equal(t, float64(0), *ids["ProcessCPUPercent.nginx"].Value, "first poll has no cpu baseline")
+	require.Equal(t, float64(1), *ids["ProcessCount.app"].Value)
+	require.Equal(t, float64(1), *ids["ProcessCount.daemon"].Value)
+	require.Equal(t, float64(0), *ids["ProcessCount.missing"].Value)
+
+	now = now.Add(10 * time.Second)
+	cpu[10], cpu[11], cpu[20] = 2, 4, 15
+	data, err = c.Collect(context.Background())
+	require.NoError(t, err)
+	ids = byID(data)
+	require.InDelta(t, 30, *ids["ProcessCPUPercent.nginx"].Value, 1e-9)
+	require.InDelta(t, 100, *ids["ProcessCPUPercent.app"].Value, 1e-9)
+	require.Equal(t, float64(0), *ids["ProcessCPUPercent.daemon"].Value)
+}
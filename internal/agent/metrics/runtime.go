@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"runtime"
+)
+
+func init() {
+	Register("runtime", true, func(options json.RawMessage) (Collector, error) {
+		return &RuntimeCollector{}, nil
+	})
+}
+
+// RuntimeCollector собирает статистику памяти среды выполнения Go, случайное значение
+// и счетчик опросов
+type RuntimeCollector struct{}
+
+// Name возвращает имя сборщика
+func (c *RuntimeCollector) Name() string {
+	return "runtime"
+}
+
+// Collect получение базовых метрик
+func (c *RuntimeCollector) Collect(ctx context.Context) ([]Metric, error) {
+	var runtimeMetrics runtime.MemStats
+	runtime.ReadMemStats(&runtimeMetrics)
+
+	return []Metric{
+		gauge("Alloc", float64(runtimeMetrics.Alloc)),
+		gauge("BuckHashSys", float64(runtimeMetrics.BuckHashSys)),
+		gauge("Frees", float64(runtimeMetrics.Frees)),
+		gauge("GCCPUFraction", runtimeMetrics.GCCPUFraction),
+		gauge("GCSys", float64(runtimeMetrics.GCSys)),
+		gauge("HeapAlloc", float64(runtimeMetrics.HeapAlloc)),
+		gauge("HeapIdle", float64(runtimeMetrics.HeapIdle)),
+		gauge("HeapInuse", float64(runtimeMetrics.HeapInuse)),
+		gauge("HeapObjects", float64(runtimeMetrics.HeapObjects)),
+		gauge("HeapReleased", float64(runtimeMetrics.HeapReleased)),
+		gauge("HeapSys", float64(runtimeMetrics.HeapSys)),
+		gauge("LastGC", float64(runtimeMetrics.LastGC)),
+		gauge("Lookups", float64(runtimeMetrics.Lookups)),
+		gauge("MCacheInuse", float64(runtimeMetrics.MCacheInuse)),
+		gauge("MCacheSys", float64(runtimeMetrics.MCacheSys)),
+		gauge("MSpanInuse", float64(runtimeMetrics.MSpanInuse)),
+		gauge("MSpanSys", float64(runtimeMetrics.MSpanSys)),
+		gauge("Mallocs", float64(runtimeMetrics.Mallocs)),
+		gauge("NextGC", float64(runtimeMetrics.NextGC)),
+		gauge("NumForcedGC", float64(runtimeMetrics.NumForcedGC)),
+		gauge("NumGC", float64(runtimeMetrics.NumGC)),
+		gauge("OtherSys", float64(runtimeMetrics.OtherSys)),
+		gauge("PauseTotalNs", float64(runtimeMetrics.PauseTotalNs)),
+		gauge("StackInuse", float64(runtimeMetrics.StackInuse)),
+		gauge("StackSys", float64(runtimeMetrics.StackSys)),
+		gauge("Sys", float64(runtimeMetrics.Sys)),
+		gauge("TotalAlloc", float64(runtimeMetrics.TotalAlloc)),
+		gauge("RandomValue", rand.Float64()),
+		// Каждая пачка — один опрос, сервер суммирует приращения счетчика
+		counter("PollCount", 1),
+	}, nil
+}
@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/mem"
+)
+
+func init() {
+	Register("system", true, func(options json.RawMessage) (Collector, error) {
+		return NewSystemCollector(), nil
+	})
+}
+
+// SystemCollector собирает объем памяти и загрузку каждого ядра процессора
+type SystemCollector struct {
+	virtualMemory func(ctx context.Context) (*mem.VirtualMemoryStat, error)
+	times         func(ctx context.Context, percpu bool) ([]cpu.TimesStat, error)
+	last          []cpu.TimesStat
+}
+
+// NewSystemCollector создает SystemCollector
+func NewSystemCollector() *SystemCollector {
+	return &SystemCollector{
+		virtualMemory: mem.VirtualMemoryWithContext,
+		times:         cpu.TimesWithContext,
+	}
+}
+
+// Name возвращает имя сборщика
+func (c *SystemCollector) Name() string {
+	return "system"
+}
+
+// Collect получение расширенных метрик. Загрузка ядра N отправляется как CPUutilizationN
+// (с 1) и считается по процессорному времени с прошлого опроса, поэтому в первом опросе ее нет
+func (c *SystemCollector) Collect(ctx context.Context) ([]Metric, error) {
+	memory, err := c.virtualMemory(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	times, err := c.times(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	data := []Metric{
+		gauge("TotalMemory", float64(memory.Total)),
+		gauge("FreeMemory", float64(memory.Free)),
+	}
+	if len(c.last) == len(times) {
+		for i := range times {
+			if busy, ok := cpuBusy(c.last[i], times[i]); ok {
+				data = append(data, gauge("CPUutilization"+strconv.Itoa(i+1), busy))
+			}
+		}
+	}
+	c.last = times
+	return data, nil
+}
@@ -0,0 +1,185 @@
+// Модуль режима pull: агент хранит последние собранные метрики и отдает их по HTTP
+package pull
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/agent/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+// Пути, по которым агент отдает метрики
+const (
+	PathPrometheus = "/metrics"
+	PathJSON       = "/metrics.json"
+)
+
+// shutdownTimeout время на завершение запросов при остановке агента
+const shutdownTimeout = 5 * time.Second
+
+// Cache последние значения gauge и накопленные с запуска агента значения counter
+type Cache struct {
+	mu       sync.RWMutex
+	gauges   map[string]float64
+	counters map[string]int64
+}
+
+// NewCache создает пустой Cache
+func NewCache() *Cache {
+	return &Cache{
+		gauges:   make(map[string]float64),
+		counters: make(map[string]int64),
+	}
+}
+
+// Update запоминает значения gauge и прибавляет приращения counter из пачки
+func (c *Cache) Update(batch []metrics.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, m := range batch {
+		switch {
+		case m.MType == "gauge" && m.Value != nil:
+			c.gauges[m.ID] = *m.Value
+		case m.MType == "counter" && m.Delta != nil:
+			c.counters[m.ID] += *m.Delta
+		}
+	}
+}
+
+// Snapshot возвращает все метрики, упорядоченные по типу и имени. Delta счетчиков
+// содержит сумму приращений с запуска агента
+func (c *Cache) Snapshot() []metrics.Metric {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	snapshot := make([]metrics.Metric, 0, len(c.counters)+len(c.gauges))
+	for id, delta := range c.counters {
+		snapshot = append(snapshot, metrics.Metric{ID: id, MType: "counter", Delta: &delta})
+	}
+	for id, value := range c.gauges {
+		snapshot = append(snapshot, metrics.Metric{ID: id, MType: "gauge", Value: &value})
+	}
+	sort.Slice(snapshot, func(i, j int) bool {
+		if snapshot[i].MType != snapshot[j].MType {
+			return snapshot[i].MType < snapshot[j].MType
+		}
+		return snapshot[i].ID < snapshot[j].ID
+	})
+	return snapshot
+}
+
+// Forward обновляет cache пачками из in и передает их дальше в out для отправки на сервер.
+// nil out отключает отправку
+func Forward(ctx context.Context, wg *sync.WaitGroup, in <-chan *[]metrics.Metric, cache *Cache, out chan<- *[]metrics.Metric) {
+	defer wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case data := <-in:
+			cache.Update(*data)
+			if out == nil {
+				continue
+			}
+			select {
+			case out <- data:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// Handler отдает метрики из cache в текстовом формате Prometheus и в JSON.
+// Если token не пуст, запрос должен содержать заголовок Authorization: Bearer <token>
+func Handler(cache *Cache, token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+PathPrometheus, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := WritePrometheus(w, cache.Snapshot()); err != nil {
+			log.Error(err)
+		}
+	})
+	mux.HandleFunc("GET "+PathJSON, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(cache.Snapshot()); err != nil {
+			log.Error(err)
+		}
+	})
+
+	if token == "" {
+		return mux
+	}
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+var invalidPrometheusChars = regexp.MustCompile(`[^A-Za-z0-9_:]`)
+
+// PrometheusName приводит имя метрики к допустимому в Prometheus: недопустимые символы
+// заменяются на _, перед цифрой в начале добавляется _
+func PrometheusName(id string) string {
+	name := invalidPrometheusChars.ReplaceAllString(id, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
+
+// WritePrometheus записывает метрики в текстовом формате Prometheus
+func WritePrometheus(w io.Writer, snapshot []metrics.Metric) error {
+	for _, m := range snapshot {
+		name := PrometheusName(m.ID)
+		var value string
+		switch {
+		case m.MType == "counter" && m.Delta != nil:
+			value = strconv.FormatInt(*m.Delta, 10)
+		case m.MType == "gauge" && m.Value != nil:
+			value = strconv.FormatFloat(*m.Value, 'g', -1, 64)
+		default:
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "# TYPE %s %s\n%s %s\n", name, m.MType, name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Serve запускает HTTP сервер режима pull и останавливает его при отмене ctx
+func Serve(ctx context.Context, wg *sync.WaitGroup, addr string, handler http.Handler) {
+	defer wg.Done()
+	server := &http.Server{Addr: addr, Handler: handler, ReadHeaderTimeout: shutdownTimeout}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Error(err)
+		}
+	}()
+
+	log.Infof("Serving metrics at %s", addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+}
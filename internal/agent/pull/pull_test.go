@@ -0,0 +1,115 @@
+package pull
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/agent/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func gauge(id string, value float64) metrics.Metric {
+	return metrics.Metric{ID: id, MType: "gauge", Value: &value}
+}
+
+func counter(id string, delta int64) metrics.Metric {
+	return metrics.Metric{ID: id, MType: "counter", Delta: &delta}
+}
+
+func TestCache(t *testing.T) {
+	cache := NewCache()
+	cache.Update([]metrics.Metric{gauge("Alloc", 1), counter("PollCount", 1)})
+	cache.Update([]metrics.Metric{gauge("Alloc", 2), counter("PollCount", 1), gauge("NetBytesRecv.eth0", 3)})
+
+	snapshot := cache.Snapshot()
+	require.Len(t, snapshot, 3)
+	require.Equal(t, "PollCount", snapshot[0].ID)
+	require.Equal(t, int64(2), *snapshot[0].Delta)
+	require.Equal(t, "Alloc", snapshot[1].ID)
+	require.Equal(t, float64(2), *snapshot[1].Value)
+	require.Equal(t, "NetBytesRecv.eth0", snapshot[2].ID)
+}
+
+func TestPrometheusName(t *testing.T) {
+	tests := []struct {
+		id   string
+		want string
+	}{
+		{id: "Alloc", want: "Alloc"},
+		{id: "NetBytesRecv.eth0", want: "NetBytesRecv_eth0"},
+		{id: "app:requests-total", want: "app:requests_total"},
+		{id: "1min", want: "_1min"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			require.Equal(t, tt.want, PrometheusName(tt.id))
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	cache := NewCache()
+	cache.Update([]metrics.Metric{gauge("Load1", 0.5), counter("PollCount", 3)})
+
+	tests := []struct {
+		name     string
+		token    string
+		header   string
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{name: "prometheus", path: PathPrometheus, wantCode: http.StatusOK,
+			wantBody: "# TYPE PollCount counter\nPollCount 3\n# TYPE Load1 gauge\nLoad1 0.5\n"},
+		{name: "json", path: PathJSON, wantCode: http.StatusOK,
+			wantBody: `[{"id":"PollCount","type":"counter","delta":3},{"id":"Load1","type":"gauge","value":0.5}]` + "\n"},
+		{name: "unknown path", path: "/", wantCode: http.StatusNotFound},
+		{name: "without token", token: "secret", path: PathJSON, wantCode: http.StatusUnauthorized},
+		{name: "wrong token", token: "secret", header: "Bearer other", path: PathJSON, wantCode: http.StatusUnauthorized},
+		{name: "with token", token: "secret", header: "Bearer secret", path: PathPrometheus, wantCode: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.header != "" {
+				request.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			Handler(cache, tt.token).ServeHTTP(w, request)
+
+			require.Equal(t, tt.wantCode, w.Code)
+			if tt.wantBody != "" {
+				require.Equal(t, tt.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestForward(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	in := make(chan *[]metrics.Metric)
+	out := make(chan *[]metrics.Metric, 1)
+	cache := NewCache()
+
+	wg.Add(1)
+	go Forward(ctx, wg, in, cache, out)
+
+	batch := []metrics.Metric{counter("PollCount", 1)}
+	in <- &batch
+	require.Equal(t, &batch, <-out)
+	require.Len(t, cache.Snapshot(), 1)
+
+	cancel()
+	wg.Wait()
+}
+
+func TestWritePrometheusSkipsEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WritePrometheus(&buf, []metrics.Metric{{ID: "Empty", MType: "gauge"}}))
+	require.Empty(t, buf.String())
+}
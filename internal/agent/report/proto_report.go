@@ -3,16 +3,22 @@ package report
 
 import (
 	"context"
+	"crypto/hmac"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/romanmendelproject/go-yandex-metrics/internal/agent/config"
 	"github.com/romanmendelproject/go-yandex-metrics/internal/agent/metrics"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/crypto"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/tenant"
 	pb "github.com/romanmendelproject/go-yandex-metrics/proto"
 	"github.com/romanmendelproject/go-yandex-metrics/utils"
 	log "github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
 )
 
 // ReportBatchMetric отправка нескольких метрик в одном пакете в формате JSON
@@ -24,7 +30,7 @@ func ReportBatchMetricProto(ctx context.Context, cfg *config.ClientFlags, wg *sy
 			log.Info("Closing report program")
 			return
 		case data := <-metricsChannel:
-			if err := sendMetricProto(ctx, cfg.FlagReqAddr, *data); err != nil {
+			if err := sendMetricProto(ctx, cfg, *data); err != nil {
 				log.Error(err)
 			}
 
@@ -32,21 +38,40 @@ func ReportBatchMetricProto(ctx context.Context, cfg *config.ClientFlags, wg *sy
 	}
 }
 
-func updateMS(ctx context.Context, c pb.MetricsClient, in *pb.UpdateBatchRequest) error {
-	_, err := c.UpdateBatch(ctx, in)
+func updateMS(ctx context.Context, c pb.MetricsClient, in *pb.UpdateBatchRequest, opts ...grpc.CallOption) (*pb.UpdateBatchResponse, error) {
+	resp, err := c.UpdateBatch(ctx, in, opts...)
 	if err != nil {
-		log.Fatalf("could not greet: %v", err)
-		return err
+		log.Errorf("gRPC agent updateMS: %v", err)
+		return nil, err
 	}
 	log.Info("gRPC agent ", "updateMS")
-	return nil
+	return resp, nil
+
+}
+
+// verifyProtoResponse проверяет подпись ответа сервера из trailer
+func verifyProtoResponse(resp proto.Message, trailer metadata.MD, key, timestamp, nonce string) error {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(resp)
+	if err != nil {
+		return err
+	}
+
+	var hash string
+	if values := trailer.Get(crypto.MetadataHash); len(values) > 0 {
+		hash = values[0]
+	}
 
+	expected := crypto.GetSignedHash(data, key, timestamp, nonce)
+	if !hmac.Equal([]byte(hash), []byte(expected)) {
+		return errInvalidSignature
+	}
+	return nil
 }
 
-func sendMetricProto(ctx context.Context, addr string, metrics []metrics.Metric) error {
+func sendMetricProto(ctx context.Context, cfg *config.ClientFlags, metrics []metrics.Metric) error {
 
 	// Set up a connection to the server.
-	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.NewClient(cfg.FlagReqAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
 
 	if err != nil {
 		log.Error("gRPC agent sendRequestMetricGRPC: did not connect: grpc.Dial, ", "about ERR"+err.Error())
@@ -58,6 +83,16 @@ func sendMetricProto(ctx context.Context, addr string, metrics []metrics.Metric)
 	ctx, cancel := context.WithTimeout(ctx, time.Second)
 	defer cancel()
 
+	if cfg.APIKey != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, tenant.MetadataAPIKey, cfg.APIKey)
+	}
+	if cfg.Tenant != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, tenant.MetadataTenant, cfg.Tenant)
+	}
+	if cfg.Token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+cfg.Token)
+	}
+
 	ms := []*pb.Metric{}
 
 	for _, m := range metrics {
@@ -69,7 +104,26 @@ func sendMetricProto(ctx context.Context, addr string, metrics []metrics.Metric)
 		})
 	}
 	mss := &pb.UpdateBatchRequest{Metric: ms}
-	updateMS(ctx, c, mss)
+	key, signed := cfg.Keys().CurrentHMAC()
+	if !signed {
+		_, err := updateMS(ctx, c, mss)
+		return err
+	}
 
-	return nil
+	nonce, err := crypto.NewNonce()
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	ctx = metadata.AppendToOutgoingContext(ctx, crypto.MetadataTimestamp, timestamp, crypto.MetadataNonce, nonce)
+	if key.ID != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, crypto.MetadataKeyID, key.ID)
+	}
+
+	var trailer metadata.MD
+	resp, err := updateMS(ctx, c, mss, grpc.Trailer(&trailer))
+	if err != nil {
+		return err
+	}
+	return verifyProtoResponse(resp, trailer, key.Key, timestamp, nonce)
 }
@@ -5,15 +5,20 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto/hmac"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/romanmendelproject/go-yandex-metrics/internal/agent/config"
 	"github.com/romanmendelproject/go-yandex-metrics/internal/agent/metrics"
 	"github.com/romanmendelproject/go-yandex-metrics/internal/crypto"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/tenant"
 	"github.com/romanmendelproject/go-yandex-metrics/utils"
 
 	log "github.com/sirupsen/logrus"
@@ -21,6 +26,8 @@ import (
 
 var retries = []int{1, 3, 5}
 
+var errInvalidSignature = errors.New("invalid server response signature")
+
 // ReportSingleMetric отправка одинарной метрики на сервер
 func ReportSingleMetric(ctx context.Context, cfg *config.ClientFlags, wg *sync.WaitGroup, metricsChannel <-chan *[]metrics.Metric) {
 	defer wg.Done()
@@ -75,13 +82,15 @@ func sendMetric(cfg *config.ClientFlags, body []byte, url string) error {
 	gz.Write(body)
 	gz.Close()
 
-	if cfg.CryptoKey != "" {
-
-		encryptedBody, err := crypto.Encrypt(cfg.CryptoKey, requestBody.String())
+	keys := cfg.Keys()
+	var cryptoKeyID string
+	if keys.HasRSA() {
+		keyID, encryptedBody, err := keys.Encrypt(requestBody.String())
 		if err != nil {
 			log.Error(err)
 			return err
 		}
+		cryptoKeyID = keyID
 		requestBody = new(bytes.Buffer)
 		_, err = requestBody.WriteString(encryptedBody)
 		if err != nil {
@@ -101,13 +110,33 @@ func sendMetric(cfg *config.ClientFlags, body []byte, url string) error {
 	req.Header.Set("Content-Encoding", "gzip")
 	req.Header.Set("Accept-Encoding", "gzip")
 	req.Header.Set("X-Real-IP", utils.GetIP())
-
-	if cfg.Key != "" {
-		hash := crypto.GetHash(body, cfg.Key)
-		req.Header.Set("HashSHA256", hash)
+	if cryptoKeyID != "" {
+		req.Header.Set(crypto.HeaderCryptoKeyID, cryptoKeyID)
+	}
+	if cfg.APIKey != "" {
+		req.Header.Set(tenant.HeaderAPIKey, cfg.APIKey)
+	}
+	if cfg.Tenant != "" {
+		req.Header.Set(tenant.HeaderTenant, cfg.Tenant)
+	}
+	if cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.Token)
 	}
 
 	for _, timeSleep := range retries {
+		// Каждая попытка подписывается заново: сервер отклоняет повторно использованный nonce
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return err
+			}
+		}
+		key, signed := keys.CurrentHMAC()
+		if signed {
+			if err := signRequest(req, body, key); err != nil {
+				return err
+			}
+		}
+
 		resp, err := client.Do(req)
 		if err != nil {
 			log.Errorf("Failed to send collectors to server: %s. Retrying after %ds...", err, timeSleep)
@@ -117,10 +146,53 @@ func sendMetric(cfg *config.ClientFlags, body []byte, url string) error {
 		defer resp.Body.Close()
 		if resp.StatusCode != 200 {
 			return fmt.Errorf("not expected status code: %d", resp.StatusCode)
-		} else {
-			return nil
 		}
+		if signed {
+			return verifyResponse(resp, key.Key, req.Header.Get(crypto.HeaderTimestamp), req.Header.Get(crypto.HeaderNonce))
+		}
+		return nil
 	}
 
 	return err
 }
+
+// signRequest подписывает тело запроса вместе с текущим временем и новым nonce
+func signRequest(req *http.Request, body []byte, key crypto.HMACKey) error {
+	nonce, err := crypto.NewNonce()
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req.Header.Set(crypto.HeaderTimestamp, timestamp)
+	req.Header.Set(crypto.HeaderNonce, nonce)
+	req.Header.Set(crypto.HeaderHash, crypto.GetSignedHash(body, key.Key, timestamp, nonce))
+	if key.ID != "" {
+		req.Header.Set(crypto.HeaderKeyID, key.ID)
+	}
+	return nil
+}
+
+// verifyResponse проверяет подпись ответа сервера на запрос с временем timestamp и nonce
+func verifyResponse(resp *http.Response, key, timestamp, nonce string) error {
+	var reader io.Reader = resp.Body
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		reader = zr
+	}
+
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	expected := crypto.GetSignedHash(body, key, timestamp, nonce)
+	if !hmac.Equal([]byte(resp.Header.Get(crypto.HeaderHash)), []byte(expected)) {
+		return errInvalidSignature
+	}
+	return nil
+}
@@ -8,52 +8,70 @@ import (
 	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/pem"
-	"os"
+	"errors"
 )
 
-// GetHash получение GetHash по ключу
-func GetHash(metrics []byte, key string) string {
+var errNoPEM = errors.New("no PEM data")
+
+// Заголовки подписи запроса
+const (
+	HeaderHash      = "HashSHA256"
+	HeaderTimestamp = "X-Timestamp"
+	HeaderNonce     = "X-Nonce"
+)
+
+// Ключи метаданных gRPC с подписью
+const (
+	MetadataHash      = "hashsha256"
+	MetadataTimestamp = "x-timestamp"
+	MetadataNonce     = "x-nonce"
+)
+
+// GetSignedHash подписывает тело запроса вместе с его временем и одноразовым значением,
+// чтобы перехваченный запрос нельзя было отправить повторно
+func GetSignedHash(body []byte, key, timestamp, nonce string) string {
 	h := hmac.New(sha256.New, []byte(key))
-	h.Write(metrics)
+	h.Write([]byte(timestamp))
+	h.Write([]byte{'\n'})
+	h.Write([]byte(nonce))
+	h.Write([]byte{'\n'})
+	h.Write(body)
 
 	sum := h.Sum(nil)
 	return base64.StdEncoding.EncodeToString(sum)
 }
 
-func Encrypt(publicKeyPath, plainText string) (string, error) {
-	bytes, err := os.ReadFile(publicKeyPath)
-	if err != nil {
-		return "", err
-	}
-
-	publicKey, err := convertBytesToPublicKey(bytes)
-	if err != nil {
-		return "", err
-	}
-
-	cipher, err := rsa.EncryptPKCS1v15(rand.Reader, publicKey, []byte(plainText))
-	if err != nil {
+// NewNonce генерирует одноразовое значение для подписи запроса
+func NewNonce() (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
 		return "", err
 	}
-
-	return cipherToPemString(cipher), nil
+	return hex.EncodeToString(nonce), nil
 }
 
+// convertBytesToPublicKey читает открытый RSA ключ из сертификата в формате PEM
 func convertBytesToPublicKey(keyBytes []byte) (*rsa.PublicKey, error) {
-	var err error
-
 	block, _ := pem.Decode(keyBytes)
-	blockBytes := block.Bytes
+	if block == nil {
+		return nil, errNoPEM
+	}
 
-	cert, err := x509.ParseCertificate(blockBytes)
+	cert, err := x509.ParseCertificate(block.Bytes)
 	if err != nil {
 		return nil, err
 	}
 
-	return cert.PublicKey.(*rsa.PublicKey), nil
+	publicKey, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("not an RSA certificate")
+	}
+	return publicKey, nil
 }
 
+// cipherToPemString упаковывает зашифрованное сообщение в PEM
 func cipherToPemString(cipher []byte) string {
 	return string(
 		pem.EncodeToMemory(
@@ -65,42 +83,22 @@ func cipherToPemString(cipher []byte) string {
 	)
 }
 
-func Decrypt(privateKeyPath, encryptedMessage string) (string, error) {
-	bytes, err := os.ReadFile(privateKeyPath)
-	if err != nil {
-		return "", err
-	}
-
-	privateKey, err := convertBytesToPrivateKey(bytes)
-	if err != nil {
-		return "", err
-	}
-
-	plainMessage, err := rsa.DecryptPKCS1v15(
-		rand.Reader,
-		privateKey,
-		pemStringToCipher(encryptedMessage),
-	)
-
-	return string(plainMessage), err
-}
-
+// convertBytesToPrivateKey читает закрытый RSA ключ PKCS#1 в формате PEM
 func convertBytesToPrivateKey(keyBytes []byte) (*rsa.PrivateKey, error) {
-	var err error
-
 	block, _ := pem.Decode(keyBytes)
-	blockBytes := block.Bytes
-
-	privateKey, err := x509.ParsePKCS1PrivateKey(blockBytes)
-	if err != nil {
-		return nil, err
+	if block == nil {
+		return nil, errNoPEM
 	}
 
-	return privateKey, nil
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
 }
 
+// pemStringToCipher извлекает зашифрованное сообщение из PEM, nil — если PEM не найден
 func pemStringToCipher(encryptedMessage string) []byte {
 	b, _ := pem.Decode([]byte(encryptedMessage))
+	if b == nil {
+		return nil
+	}
 
 	return b.Bytes
 }
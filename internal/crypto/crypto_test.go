@@ -2,40 +2,15 @@ package crypto
 
 import (
 	"bytes"
-	"crypto/hmac"
 	"crypto/rand"
 	"crypto/rsa"
-	"crypto/sha256"
 	"crypto/x509"
-	"encoding/base64"
 	"encoding/pem"
-	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/require"
 )
 
-func TestCryptography(t *testing.T) {
-	var metrics []byte
-	key := "test"
-
-	hash := GetHash(metrics, key)
-
-	h := hmac.New(sha256.New, []byte(key))
-
-	require.Equal(t, hash, base64.StdEncoding.EncodeToString(h.Sum(nil)))
-}
-
-func Example() {
-	var metrics []byte
-	key := "test"
-
-	fmt.Println(GetHash(metrics, key))
-	// Output:
-	// rXEUjHnyGrnuxR6lx90rZoeS98DTU0rmayL3HGFSP7M=
-}
-
 func TestCipherToPemString(t *testing.T) {
 	cipher := []byte("test message")
 	expectedPem := "-----BEGIN MESSAGE-----\ndGVzdCBtZXNzYWdl\n-----END MESSAGE-----\n"
@@ -184,3 +159,13 @@ MVt5FCqFPBQpZQGayDj21vXzk7IO+kZ/w2UrFL42dQHKQ3+vJF0bgBE8G57n
 	assert.NotNil(t, privateKey)
 	assert.IsType(t, &rsa.PrivateKey{}, privateKey)
 }
+
+func TestConvertBytesWithoutPEM(t *testing.T) {
+	_, err := convertBytesToPublicKey([]byte("not a key"))
+	assert.ErrorIs(t, err, errNoPEM)
+
+	_, err = convertBytesToPrivateKey([]byte("not a key"))
+	assert.ErrorIs(t, err, errNoPEM)
+
+	assert.Nil(t, pemStringToCipher("not a message"))
+}
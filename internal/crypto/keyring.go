@@ -0,0 +1,249 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Заголовки и ключи метаданных с идентификатором использованного ключа
+const (
+	HeaderKeyID       = "X-Key-ID"
+	HeaderCryptoKeyID = "X-Crypto-Key-ID"
+	MetadataKeyID     = "x-key-id"
+)
+
+// ErrNoKey возвращается, если ни один из активных ключей не подошел
+var ErrNoKey = errors.New("no matching key")
+
+// HMACKey ключ подписи с идентификатором
+type HMACKey struct {
+	ID  string `json:"id"`
+	Key string `json:"key"`
+}
+
+// RSAKey ключ шифрования с идентификатором. Path указывает на закрытый ключ
+// (сервер) или сертификат с открытым ключом (агент)
+type RSAKey struct {
+	ID   string `json:"id"`
+	Path string `json:"path"`
+
+	private *rsa.PrivateKey
+	public  *rsa.PublicKey
+}
+
+// KeySet набор активных ключей. Первый ключ каждого вида считается самым новым
+type KeySet struct {
+	HMAC []HMACKey `json:"hmac"`
+	RSA  []RSAKey  `json:"rsa"`
+}
+
+// KeyRing хранит активные ключи и позволяет перечитать их без перезапуска.
+// Nil KeyRing не содержит ключей
+type KeyRing struct {
+	mu   sync.RWMutex
+	set  KeySet
+	load func() (KeySet, error)
+}
+
+// NewKeyRing создает набор из одного ключа подписи и одного RSA ключа без
+// идентификаторов. Пустые значения означают отсутствие ключа
+func NewKeyRing(hmacKey, rsaPath string) (*KeyRing, error) {
+	return newKeyRing(func() (KeySet, error) {
+		var set KeySet
+		if hmacKey != "" {
+			set.HMAC = []HMACKey{{Key: hmacKey}}
+		}
+		if rsaPath != "" {
+			set.RSA = []RSAKey{{Path: rsaPath}}
+		}
+		return set, loadRSAKeys(set.RSA)
+	})
+}
+
+// LoadKeyRing загружает набор ключей из JSON файла path
+func LoadKeyRing(path string) (*KeyRing, error) {
+	return newKeyRing(func() (KeySet, error) {
+		var set KeySet
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return set, err
+		}
+		if err := json.Unmarshal(data, &set); err != nil {
+			return set, fmt.Errorf("parse keys %s: %w", path, err)
+		}
+		return set, loadRSAKeys(set.RSA)
+	})
+}
+
+func newKeyRing(load func() (KeySet, error)) (*KeyRing, error) {
+	r := &KeyRing{load: load}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload перечитывает ключи. При ошибке остается прежний набор
+func (r *KeyRing) Reload() error {
+	set, err := r.load()
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.set = set
+	r.mu.Unlock()
+	return nil
+}
+
+// HasHMAC сообщает, есть ли ключи подписи
+func (r *KeyRing) HasHMAC() bool {
+	if r == nil {
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.set.HMAC) > 0
+}
+
+// HasRSA сообщает, есть ли ключи шифрования
+func (r *KeyRing) HasRSA() bool {
+	if r == nil {
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.set.RSA) > 0
+}
+
+// CurrentHMAC возвращает самый новый ключ подписи
+func (r *KeyRing) CurrentHMAC() (HMACKey, bool) {
+	if r == nil {
+		return HMACKey{}, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.set.HMAC) == 0 {
+		return HMACKey{}, false
+	}
+	return r.set.HMAC[0], true
+}
+
+// SigningKey возвращает ключ подписи с идентификатором id, а если он неизвестен — самый новый
+func (r *KeyRing) SigningKey(id string) (HMACKey, bool) {
+	if r == nil {
+		return HMACKey{}, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, key := range r.set.HMAC {
+		if id != "" && key.ID == id {
+			return key, true
+		}
+	}
+	if len(r.set.HMAC) == 0 {
+		return HMACKey{}, false
+	}
+	return r.set.HMAC[0], true
+}
+
+// HMACKeys возвращает ключи подписи для проверки. Если id известен, возвращается
+// только этот ключ, иначе все ключи от новых к старым
+func (r *KeyRing) HMACKeys(id string) []HMACKey {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, key := range r.set.HMAC {
+		if id != "" && key.ID == id {
+			return []HMACKey{key}
+		}
+	}
+	return append([]HMACKey(nil), r.set.HMAC...)
+}
+
+// Encrypt шифрует сообщение самым новым открытым ключом и возвращает его идентификатор
+func (r *KeyRing) Encrypt(plainText string) (string, string, error) {
+	if r == nil {
+		return "", "", ErrNoKey
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.set.RSA) == 0 || r.set.RSA[0].public == nil {
+		return "", "", ErrNoKey
+	}
+	key := r.set.RSA[0]
+
+	cipher, err := rsa.EncryptPKCS1v15(rand.Reader, key.public, []byte(plainText))
+	if err != nil {
+		return "", "", err
+	}
+	return key.ID, cipherToPemString(cipher), nil
+}
+
+// Decrypt расшифровывает сообщение ключом id, а если он неизвестен — перебирает
+// закрытые ключи от новых к старым
+func (r *KeyRing) Decrypt(id, encryptedMessage string) (string, error) {
+	if r == nil {
+		return "", ErrNoKey
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	cipher := pemStringToCipher(encryptedMessage)
+	if cipher == nil {
+		return "", errors.New("invalid encrypted message")
+	}
+
+	candidates := r.set.RSA
+	for _, key := range r.set.RSA {
+		if id != "" && key.ID == id {
+			candidates = []RSAKey{key}
+			break
+		}
+	}
+
+	for _, key := range candidates {
+		if key.private == nil {
+			continue
+		}
+		plain, err := rsa.DecryptPKCS1v15(rand.Reader, key.private, cipher)
+		if err == nil {
+			return string(plain), nil
+		}
+	}
+	return "", ErrNoKey
+}
+
+// loadRSAKeys читает RSA ключи. Файл может содержать закрытый ключ PKCS#1
+// или сертификат с открытым ключом
+func loadRSAKeys(keys []RSAKey) error {
+	for i := range keys {
+		data, err := os.ReadFile(keys[i].Path)
+		if err != nil {
+			return err
+		}
+
+		if private, err := convertBytesToPrivateKey(data); err == nil {
+			keys[i].private = private
+			keys[i].public = &private.PublicKey
+			continue
+		}
+
+		public, err := convertBytesToPublicKey(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", keys[i].Path, err)
+		}
+		keys[i].public = public
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeRSAKey создает закрытый ключ и сертификат с открытым ключом в каталоге dir
+func writeRSAKey(t *testing.T, dir, id string) (string, string) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: id},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &privateKey.PublicKey, privateKey)
+	require.NoError(t, err)
+
+	privatePath := filepath.Join(dir, id+"-private.pem")
+	publicPath := filepath.Join(dir, id+"-public.pem")
+	require.NoError(t, os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{
+		Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+	}), 0o600))
+	require.NoError(t, os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{
+		Type: "CERTIFICATE", Bytes: cert,
+	}), 0o600))
+
+	return privatePath, publicPath
+}
+
+func writeKeySet(t *testing.T, path, data string) {
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+}
+
+func TestKeyRingHMAC(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	writeKeySet(t, path, `{"hmac":[{"id":"new","key":"k2"},{"id":"old","key":"k1"}]}`)
+
+	keys, err := LoadKeyRing(path)
+	require.NoError(t, err)
+
+	current, ok := keys.CurrentHMAC()
+	require.True(t, ok)
+	require.Equal(t, "new", current.ID)
+
+	require.Equal(t, []HMACKey{{ID: "old", Key: "k1"}}, keys.HMACKeys("old"))
+	require.Len(t, keys.HMACKeys("unknown"), 2)
+
+	signing, ok := keys.SigningKey("old")
+	require.True(t, ok)
+	require.Equal(t, "k1", signing.Key)
+
+	// Ошибка перечитывания не должна ломать действующий набор
+	writeKeySet(t, path, `not json`)
+	require.Error(t, keys.Reload())
+	require.Len(t, keys.HMACKeys(""), 2)
+
+	writeKeySet(t, path, `{"hmac":[{"id":"newer","key":"k3"}]}`)
+	require.NoError(t, keys.Reload())
+	current, _ = keys.CurrentHMAC()
+	require.Equal(t, "newer", current.ID)
+
+	var empty *KeyRing
+	require.False(t, empty.HasHMAC())
+	require.False(t, empty.HasRSA())
+}
+
+func TestKeyRingRSARotation(t *testing.T) {
+	dir := t.TempDir()
+	oldPrivate, oldPublic := writeRSAKey(t, dir, "old")
+	newPrivate, newPublic := writeRSAKey(t, dir, "new")
+
+	serverPath := filepath.Join(dir, "server.json")
+	writeKeySet(t, serverPath, fmt.Sprintf(`{"rsa":[{"id":"new","path":%q},{"id":"old","path":%q}]}`, newPrivate, oldPrivate))
+	server, err := LoadKeyRing(serverPath)
+	require.NoError(t, err)
+
+	// Агент еще не обновил ключи и шифрует старым ключом без идентификатора
+	legacyAgent, err := NewKeyRing("", oldPublic)
+	require.NoError(t, err)
+	id, message, err := legacyAgent.Encrypt("metrics")
+	require.NoError(t, err)
+	require.Empty(t, id)
+
+	plain, err := server.Decrypt(id, message)
+	require.NoError(t, err)
+	require.Equal(t, "metrics", plain)
+
+	agentPath := filepath.Join(dir, "agent.json")
+	writeKeySet(t, agentPath, fmt.Sprintf(`{"rsa":[{"id":"new","path":%q}]}`, newPublic))
+	agent, err := LoadKeyRing(agentPath)
+	require.NoError(t, err)
+	id, message, err = agent.Encrypt("metrics")
+	require.NoError(t, err)
+	require.Equal(t, "new", id)
+
+	plain, err = server.Decrypt(id, message)
+	require.NoError(t, err)
+	require.Equal(t, "metrics", plain)
+
+	_, err = server.Decrypt("old", message)
+	require.ErrorIs(t, err, ErrNoKey)
+
+	_, _, err = server.Encrypt("metrics")
+	require.NoError(t, err, "private key also provides public key")
+}
@@ -0,0 +1,153 @@
+// Модуль журнала аудита операций записи метрик
+package audit
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/auth"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/tenant"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/trusted"
+	log "github.com/sirupsen/logrus"
+)
+
+// Транспорт, по которому пришел запрос на запись, или внутренний источник записи
+const (
+	TransportHTTP   = "http"
+	TransportGRPC   = "grpc"
+	TransportScrape = "scrape" // метрики, собранные с агентов в режиме pull
+	TransportSelf   = "self"   // собственные метрики сервера
+)
+
+// MetricDropped имя собственной метрики сервера с числом событий, потерянных при переполнении буфера
+const MetricDropped = "AuditDroppedEvents"
+
+// maxBatch максимальное число событий, передаваемых приемнику за раз
+const maxBatch = 256
+
+// Event описывает одну операцию записи метрик
+type Event struct {
+	Time      time.Time `json:"ts"`
+	ClientIP  string    `json:"client_ip,omitempty"`
+	Identity  string    `json:"identity,omitempty"` // token:<id>, если клиент аутентифицирован, или адрес опрошенного агента
+	Tenant    string    `json:"tenant"`
+	Transport string    `json:"transport"`
+	Metrics   []string  `json:"metrics"`
+	Count     int       `json:"count"`
+}
+
+// NewEvent создает событие записи метрик ids, извлекая клиента, токен и тенант из контекста
+func NewEvent(ctx context.Context, transport string, ids []string) Event {
+	ev := Event{
+		Time:      time.Now().UTC(),
+		Tenant:    tenant.FromContext(ctx),
+		Transport: transport,
+		Metrics:   ids,
+		Count:     len(ids),
+	}
+	if client, ok := trusted.ClientFromContext(ctx); ok {
+		ev.ClientIP = client.String()
+	}
+	if token, ok := auth.FromContext(ctx); ok {
+		ev.Identity = "token:" + token.ID
+	}
+	return ev
+}
+
+// Sink принимает события аудита. Write вызывается из одной горутины
+type Sink interface {
+	Write(events []Event) error
+	Close() error
+}
+
+// Auditor асинхронно передает события приемнику, не задерживая запись метрик.
+// При переполнении буфера события отбрасываются. nil Auditor ничего не записывает
+type Auditor struct {
+	sink    Sink
+	events  chan Event
+	mu      sync.RWMutex
+	closed  bool
+	done    chan struct{}
+	dropped atomic.Int64
+}
+
+// New создает Auditor с буфером на size событий и запускает запись в sink
+func New(sink Sink, size int) *Auditor {
+	a := &Auditor{
+		sink:   sink,
+		events: make(chan Event, size),
+		done:   make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+// Record ставит событие в очередь на запись
+func (a *Auditor) Record(ev Event) {
+	if a == nil {
+		return
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		return
+	}
+	select {
+	case a.events <- ev:
+	default:
+		a.dropped.Add(1)
+	}
+}
+
+// Dropped возвращает число отброшенных событий с предыдущего вызова
+func (a *Auditor) Dropped() int64 {
+	if a == nil {
+		return 0
+	}
+	return a.dropped.Swap(0)
+}
+
+// Close записывает оставшиеся в буфере события и закрывает приемник
+func (a *Auditor) Close() error {
+	if a == nil {
+		return nil
+	}
+
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.events)
+	}
+	a.mu.Unlock()
+
+	<-a.done
+	return a.sink.Close()
+}
+
+func (a *Auditor) run() {
+	defer close(a.done)
+
+	batch := make([]Event, 0, maxBatch)
+	for ev := range a.events {
+		batch = append(batch[:0], ev)
+	drain:
+		for len(batch) < maxBatch {
+			select {
+			case ev, ok := <-a.events:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, ev)
+			default:
+				break drain
+			}
+		}
+
+		if err := a.sink.Write(batch); err != nil {
+			log.Errorf("audit: %s", err)
+		}
+	}
+}
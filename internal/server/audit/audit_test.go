@@ -0,0 +1,95 @@
+package audit
+
+import (
+	"context"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/auth"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/tenant"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/trusted"
+	"github.com/stretchr/testify/require"
+)
+
+type memorySink struct {
+	mu     sync.Mutex
+	events []Event
+	block  chan struct{}
+	closed bool
+}
+
+func (s *memorySink) Write(events []Event) error {
+	if s.block != nil {
+		<-s.block
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, events...)
+	return nil
+}
+
+func (s *memorySink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestNewEvent(t *testing.T) {
+	ctx := tenant.WithTenant(context.Background(), "payments")
+	ctx = auth.WithToken(ctx, auth.Token{ID: "abc"})
+	ctx = trusted.WithClient(ctx, netip.MustParseAddr("2001:db8::1"))
+
+	ev := NewEvent(ctx, TransportGRPC, []string{"cpu", "memory"})
+	require.Equal(t, "payments", ev.Tenant)
+	require.Equal(t, "token:abc", ev.Identity)
+	require.Equal(t, "2001:db8::1", ev.ClientIP)
+	require.Equal(t, TransportGRPC, ev.Transport)
+	require.Equal(t, 2, ev.Count)
+	require.False(t, ev.Time.IsZero())
+
+	ev = NewEvent(context.Background(), TransportHTTP, []string{"cpu"})
+	require.Equal(t, tenant.Default, ev.Tenant)
+	require.Empty(t, ev.Identity)
+	require.Empty(t, ev.ClientIP)
+}
+
+func TestAuditor(t *testing.T) {
+	sink := &memorySink{}
+	auditor := New(sink, 100)
+	for i := 0; i < 10; i++ {
+		auditor.Record(Event{Metrics: []string{"cpu"}, Count: 1})
+	}
+	require.NoError(t, auditor.Close())
+
+	require.Len(t, sink.events, 10, "close flushes buffered events")
+	require.True(t, sink.closed)
+
+	auditor.Record(Event{})
+	require.Len(t, sink.events, 10, "events after close are ignored")
+}
+
+func TestAuditorDrops(t *testing.T) {
+	sink := &memorySink{block: make(chan struct{})}
+	auditor := New(sink, 2)
+
+	// Первое событие забирает горутина записи, два помещаются в буфер, остальные отбрасываются
+	auditor.Record(Event{})
+	require.Eventually(t, func() bool { return len(auditor.events) == 0 }, time.Second, time.Millisecond)
+	for i := 0; i < 5; i++ {
+		auditor.Record(Event{})
+	}
+	require.Equal(t, int64(3), auditor.Dropped())
+	require.Equal(t, int64(0), auditor.Dropped())
+
+	close(sink.block)
+	require.NoError(t, auditor.Close())
+	require.Len(t, sink.events, 3)
+}
+
+func TestNilAuditor(t *testing.T) {
+	var auditor *Auditor
+	auditor.Record(Event{})
+	require.Equal(t, int64(0), auditor.Dropped())
+	require.NoError(t, auditor.Close())
+}
@@ -0,0 +1,128 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// WriterSink пишет события в формате JSON lines в io.Writer, например в stdout
+type WriterSink struct {
+	w io.Writer
+}
+
+// NewWriterSink создает WriterSink
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+// Write записывает события
+func (s *WriterSink) Write(events []Event) error {
+	enc := json.NewEncoder(s.w)
+	for _, ev := range events {
+		if err := enc.Encode(ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close ничего не делает: writer принадлежит вызывающему коду
+func (s *WriterSink) Close() error {
+	return nil
+}
+
+// FileSink пишет события в формате JSON lines в файл. Когда файл превышает maxSize
+// байт, он переименовывается в path.1, прежние копии сдвигаются, а копии старше
+// maxBackups удаляются
+type FileSink struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// NewFileSink открывает файл журнала на дозапись. Нулевой maxSize отключает ротацию
+func NewFileSink(path string, maxSize int64, maxBackups int) (*FileSink, error) {
+	s := &FileSink{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	s.file = file
+	s.size = info.Size()
+	return nil
+}
+
+// Write записывает события и выполняет ротацию при превышении размера
+func (s *FileSink) Write(events []Event) error {
+	w := bufio.NewWriter(s.file)
+	for _, ev := range events {
+		line, err := json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+		line = append(line, '\n')
+
+		if s.maxSize > 0 && s.size > 0 && s.size+int64(len(line)) > s.maxSize {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			if err := s.rotate(); err != nil {
+				return err
+			}
+			w.Reset(s.file)
+		}
+
+		n, err := w.Write(line)
+		s.size += int64(n)
+		if err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+
+	if s.maxBackups > 0 {
+		os.Remove(backupName(s.path, s.maxBackups))
+		for i := s.maxBackups - 1; i >= 1; i-- {
+			os.Rename(backupName(s.path, i), backupName(s.path, i+1))
+		}
+		if err := os.Rename(s.path, backupName(s.path, 1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(s.path); err != nil {
+		return err
+	}
+
+	return s.open()
+}
+
+func backupName(path string, i int) string {
+	return fmt.Sprintf("%s.%d", path, i)
+}
+
+// Close закрывает файл журнала
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
@@ -0,0 +1,63 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func readEvents(t *testing.T, path string) []Event {
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var ev Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &ev))
+		events = append(events, ev)
+	}
+	require.NoError(t, scanner.Err())
+	return events
+}
+
+func TestFileSinkRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	line, err := json.Marshal(Event{Tenant: "default", Transport: TransportHTTP, Metrics: []string{"m0"}, Count: 1})
+	require.NoError(t, err)
+
+	// В каждый файл помещается две записи
+	sink, err := NewFileSink(path, int64(2*(len(line)+1)), 2)
+	require.NoError(t, err)
+
+	for i := 0; i < 7; i++ {
+		ev := Event{Tenant: "default", Transport: TransportHTTP, Metrics: []string{"m" + string(rune('0'+i))}, Count: 1}
+		require.NoError(t, sink.Write([]Event{ev}))
+	}
+	require.NoError(t, sink.Close())
+
+	require.Equal(t, []string{"m6"}, readEvents(t, path)[0].Metrics)
+	require.Len(t, readEvents(t, path+".1"), 2)
+	require.Equal(t, "m2", readEvents(t, path+".2")[0].Metrics[0])
+	require.NoFileExists(t, path+".3")
+
+	// После перезапуска запись продолжается в существующий файл
+	sink, err = NewFileSink(path, 0, 0)
+	require.NoError(t, err)
+	require.NoError(t, sink.Write([]Event{{Metrics: []string{"m7"}}}))
+	require.NoError(t, sink.Close())
+	require.Len(t, readEvents(t, path), 2)
+}
+
+func TestWriterSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewWriterSink(&buf)
+	require.NoError(t, sink.Write([]Event{{Tenant: "a"}, {Tenant: "b"}}))
+	require.Equal(t, 2, bytes.Count(buf.Bytes(), []byte("\n")))
+}
@@ -0,0 +1,163 @@
+// Модуль аутентификации клиентов по токенам доступа
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Scope определяет право, выданное токену
+type Scope string
+
+// Права токенов. ScopeAdmin включает все остальные права
+const (
+	ScopeRead  Scope = "read"
+	ScopeWrite Scope = "write"
+	ScopeAdmin Scope = "admin"
+)
+
+// Заголовок HTTP и ключ метаданных gRPC с токеном доступа
+const (
+	HeaderAuthorization   = "Authorization"
+	MetadataAuthorization = "authorization"
+)
+
+// Ошибки аутентификации
+var (
+	ErrUnauthenticated = errors.New("missing or invalid token")
+	ErrForbidden       = errors.New("token scope is insufficient")
+	ErrTokenNotFound   = errors.New("token not found")
+	ErrInvalidScope    = errors.New("invalid token scope")
+)
+
+// Token описывает выданный токен. Сам токен не хранится, только его хеш
+type Token struct {
+	ID        string    `json:"id"`
+	Hash      string    `json:"hash,omitempty"`
+	Scopes    []Scope   `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// HasScope проверяет, выдано ли токену право scope
+func (t Token) HasScope(scope Scope) bool {
+	for _, s := range t.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// Store хранит хеши выданных токенов
+type Store interface {
+	FindToken(ctx context.Context, hash string) (Token, error)
+	ListTokens(ctx context.Context) ([]Token, error)
+	AddToken(ctx context.Context, token Token) error
+	DeleteToken(ctx context.Context, id string) error
+}
+
+// HashToken возвращает хеш токена для хранения и поиска
+func HashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewToken генерирует токен с правами scopes. Возвращает сам токен, который
+// показывается клиенту один раз, и его описание для Store
+func NewToken(scopes []Scope) (string, Token, error) {
+	if len(scopes) == 0 {
+		return "", Token{}, fmt.Errorf("%w: no scopes", ErrInvalidScope)
+	}
+
+	id := make([]byte, 8)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return "", Token{}, err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", Token{}, err
+	}
+
+	token := Token{
+		ID:        hex.EncodeToString(id),
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC(),
+	}
+	raw := token.ID + "." + base64.RawURLEncoding.EncodeToString(secret)
+	token.Hash = HashToken(raw)
+
+	return raw, token, nil
+}
+
+// ParseScopes разбирает список прав
+func ParseScopes(values []string) ([]Scope, error) {
+	scopes := make([]Scope, 0, len(values))
+	for _, v := range values {
+		switch scope := Scope(strings.TrimSpace(v)); scope {
+		case ScopeRead, ScopeWrite, ScopeAdmin:
+			scopes = append(scopes, scope)
+		default:
+			return nil, fmt.Errorf("%w: %q", ErrInvalidScope, v)
+		}
+	}
+	return scopes, nil
+}
+
+// BearerToken извлекает токен из значения заголовка Authorization
+func BearerToken(header string) string {
+	const prefix = "bearer "
+	if len(header) > len(prefix) && strings.EqualFold(header[:len(prefix)], prefix) {
+		return strings.TrimSpace(header[len(prefix):])
+	}
+	return ""
+}
+
+type ctxKey struct{}
+
+// WithToken сохраняет токен клиента в контексте запроса
+func WithToken(ctx context.Context, token Token) context.Context {
+	return context.WithValue(ctx, ctxKey{}, token)
+}
+
+// FromContext возвращает токен клиента из контекста запроса
+func FromContext(ctx context.Context) (Token, bool) {
+	token, ok := ctx.Value(ctxKey{}).(Token)
+	return token, ok
+}
+
+// Authenticator проверяет токены клиентов
+type Authenticator struct {
+	store Store
+}
+
+// NewAuthenticator создает Authenticator поверх хранилища токенов
+func NewAuthenticator(store Store) *Authenticator {
+	return &Authenticator{store: store}
+}
+
+// Authenticate находит токен raw и проверяет, что ему выдано право scope
+func (a *Authenticator) Authenticate(ctx context.Context, raw string, scope Scope) (Token, error) {
+	if raw == "" {
+		return Token{}, ErrUnauthenticated
+	}
+
+	token, err := a.store.FindToken(ctx, HashToken(raw))
+	if errors.Is(err, ErrTokenNotFound) {
+		return Token{}, ErrUnauthenticated
+	}
+	if err != nil {
+		return Token{}, err
+	}
+
+	if !token.HasScope(scope) {
+		return Token{}, fmt.Errorf("%w: %s required", ErrForbidden, scope)
+	}
+	return token, nil
+}
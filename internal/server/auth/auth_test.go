@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseScopes(t *testing.T) {
+	scopes, err := ParseScopes([]string{"read", " write"})
+	require.NoError(t, err)
+	require.Equal(t, []Scope{ScopeRead, ScopeWrite}, scopes)
+
+	_, err = ParseScopes([]string{"root"})
+	require.ErrorIs(t, err, ErrInvalidScope)
+}
+
+func TestBearerToken(t *testing.T) {
+	require.Equal(t, "abc", BearerToken("Bearer abc"))
+	require.Equal(t, "abc", BearerToken("bearer abc"))
+	require.Empty(t, BearerToken("Basic abc"))
+	require.Empty(t, BearerToken(""))
+}
+
+func TestAuthenticate(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileStore(filepath.Join(t.TempDir(), "tokens.json"))
+	require.NoError(t, err)
+
+	writer, writeToken, err := NewToken([]Scope{ScopeWrite})
+	require.NoError(t, err)
+	require.NoError(t, store.AddToken(ctx, writeToken))
+	require.NotContains(t, writeToken.Hash, writer, "token must be stored hashed")
+
+	admin, adminToken, err := NewToken([]Scope{ScopeAdmin})
+	require.NoError(t, err)
+	require.NoError(t, store.AddToken(ctx, adminToken))
+
+	tests := []struct {
+		name    string
+		raw     string
+		scope   Scope
+		wantErr error
+	}{
+		{"write scope", writer, ScopeWrite, nil},
+		{"missing scope", writer, ScopeRead, ErrForbidden},
+		{"admin has all scopes", admin, ScopeRead, nil},
+		{"unknown token", "deadbeef.secret", ScopeRead, ErrUnauthenticated},
+		{"empty token", "", ScopeRead, ErrUnauthenticated},
+	}
+
+	authenticator := NewAuthenticator(store)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := authenticator.Authenticate(ctx, tt.raw, tt.scope)
+			require.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestFileStorePersistence(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "tokens.json")
+
+	store, err := NewFileStore(path)
+	require.NoError(t, err)
+
+	raw, token, err := NewToken([]Scope{ScopeRead})
+	require.NoError(t, err)
+	require.NoError(t, store.AddToken(ctx, token))
+
+	reloaded, err := NewFileStore(path)
+	require.NoError(t, err)
+	found, err := reloaded.FindToken(ctx, HashToken(raw))
+	require.NoError(t, err)
+	require.Equal(t, token.ID, found.ID)
+
+	require.NoError(t, reloaded.DeleteToken(ctx, token.ID))
+	require.ErrorIs(t, reloaded.DeleteToken(ctx, token.ID), ErrTokenNotFound)
+
+	reloaded, err = NewFileStore(path)
+	require.NoError(t, err)
+	_, err = reloaded.FindToken(ctx, HashToken(raw))
+	require.ErrorIs(t, err, ErrTokenNotFound)
+}
@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FileStore хранит хеши токенов в JSON файле
+type FileStore struct {
+	mu     sync.RWMutex
+	path   string
+	tokens map[string]Token
+}
+
+// NewFileStore загружает токены из файла path. Отсутствующий файл считается пустым
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{
+		path:   path,
+		tokens: make(map[string]Token),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var tokens []Token
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, err
+	}
+	for _, token := range tokens {
+		s.tokens[token.Hash] = token
+	}
+
+	return s, nil
+}
+
+// FindToken ищет токен по хешу
+func (s *FileStore) FindToken(ctx context.Context, hash string) (Token, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	token, ok := s.tokens[hash]
+	if !ok {
+		return Token{}, ErrTokenNotFound
+	}
+	return token, nil
+}
+
+// ListTokens возвращает все токены
+func (s *FileStore) ListTokens(ctx context.Context) ([]Token, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tokens := make([]Token, 0, len(s.tokens))
+	for _, token := range s.tokens {
+		tokens = append(tokens, token)
+	}
+	return tokens, nil
+}
+
+// AddToken добавляет токен и сохраняет файл
+func (s *FileStore) AddToken(ctx context.Context, token Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[token.Hash] = token
+	if err := s.save(); err != nil {
+		delete(s.tokens, token.Hash)
+		return err
+	}
+	return nil
+}
+
+// DeleteToken удаляет токен по идентификатору и сохраняет файл
+func (s *FileStore) DeleteToken(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, token := range s.tokens {
+		if token.ID == id {
+			delete(s.tokens, hash)
+			if err := s.save(); err != nil {
+				s.tokens[hash] = token
+				return err
+			}
+			return nil
+		}
+	}
+	return ErrTokenNotFound
+}
+
+// save записывает токены во временный файл и атомарно заменяет им основной
+func (s *FileStore) save() error {
+	tokens := make([]Token, 0, len(s.tokens))
+	for _, token := range s.tokens {
+		tokens = append(tokens, token)
+	}
+
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
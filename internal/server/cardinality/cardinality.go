@@ -0,0 +1,202 @@
+// Модуль ограничения числа рядов метрик
+package cardinality
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/storage"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/tenant"
+)
+
+// MetricRejected имя собственной метрики сервера с числом отклоненных новых рядов
+const MetricRejected = "RejectedSeries"
+
+// ErrSeriesLimit возвращается при попытке создать ряд сверх лимита
+var ErrSeriesLimit = errors.New("series limit exceeded")
+
+// Limits ограничивает общее число рядов и число рядов с именами, начинающимися
+// с префикса. Нулевой MaxSeries снимает общее ограничение
+type Limits struct {
+	MaxSeries int
+	Prefixes  map[string]int
+}
+
+// Lister реализуется хранилищами, которые могут перечислить существующие ряды
+type Lister interface {
+	ListSeries(ctx context.Context) ([]storage.Series, error)
+}
+
+// Guard отслеживает известные ряды и не дает создавать новые сверх лимитов.
+// Обновления существующих рядов пропускаются всегда. nil Guard пропускает все
+type Guard struct {
+	limits   Limits
+	mu       sync.Mutex
+	known    map[storage.Series]struct{}
+	pending  map[storage.Series]int // зарезервированные новые ряды и число их резервов
+	prefixes map[string]int
+	rejected atomic.Int64
+}
+
+// Reservation место под новый ряд, занятое до записи метрики в хранилище.
+// После записи место подтверждается Commit, при ошибке записи освобождается Release.
+// nil Reservation (существующий ряд или отключенный лимит) ничего не делает
+type Reservation struct {
+	guard  *Guard
+	series storage.Series
+	done   bool
+}
+
+// Reservations места под ряды одной пачки метрик
+type Reservations []*Reservation
+
+// New создает Guard. Если лимиты не заданы, возвращает nil
+func New(limits Limits) *Guard {
+	if limits.MaxSeries <= 0 && len(limits.Prefixes) == 0 {
+		return nil
+	}
+	return &Guard{
+		limits:   limits,
+		known:    make(map[storage.Series]struct{}),
+		pending:  make(map[storage.Series]int),
+		prefixes: make(map[string]int),
+	}
+}
+
+// Load запоминает ряды, уже существующие в хранилище
+func (g *Guard) Load(ctx context.Context, lister Lister) error {
+	if g == nil {
+		return nil
+	}
+	series, err := lister.ListSeries(ctx)
+	if err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, s := range series {
+		g.add(s)
+	}
+	return nil
+}
+
+// Reserve проверяет, можно ли записать метрику mType с именем name в тенант из контекста.
+// Новый ряд в пределах лимитов резервируется до подтверждения или отмены записи
+func (g *Guard) Reserve(ctx context.Context, mType, name string) (*Reservation, error) {
+	if g == nil {
+		return nil, nil
+	}
+	s := storage.Series{Tenant: tenant.FromContext(ctx), Type: mType, Name: name}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.known[s]; ok {
+		return nil, nil
+	}
+	if g.pending[s] > 0 {
+		g.pending[s]++
+		return &Reservation{guard: g, series: s}, nil
+	}
+	if g.limits.MaxSeries > 0 && len(g.known)+len(g.pending) >= g.limits.MaxSeries {
+		g.rejected.Add(1)
+		return nil, fmt.Errorf("%w: %s", ErrSeriesLimit, name)
+	}
+	for prefix, limit := range g.limits.Prefixes {
+		if strings.HasPrefix(name, prefix) && g.prefixes[prefix] >= limit {
+			g.rejected.Add(1)
+			return nil, fmt.Errorf("%w: %s (prefix %s)", ErrSeriesLimit, name, prefix)
+		}
+	}
+	g.pending[s] = 1
+	g.countPrefixes(s.Name, 1)
+	return &Reservation{guard: g, series: s}, nil
+}
+
+// Commit подтверждает ряд после успешной записи метрики
+func (r *Reservation) Commit() {
+	if r == nil {
+		return
+	}
+	g := r.guard
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if r.done {
+		return
+	}
+	r.done = true
+	g.add(r.series)
+}
+
+// Release освобождает место, если метрика не была записана
+func (r *Reservation) Release() {
+	if r == nil {
+		return
+	}
+	g := r.guard
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if r.done {
+		return
+	}
+	r.done = true
+	// ряд мог быть уже подтвержден другим запросом
+	if g.pending[r.series] == 0 {
+		return
+	}
+	g.pending[r.series]--
+	if g.pending[r.series] == 0 {
+		delete(g.pending, r.series)
+		g.countPrefixes(r.series.Name, -1)
+	}
+}
+
+// Commit подтверждает ряды пачки после ее успешной записи
+func (rs Reservations) Commit() {
+	for _, r := range rs {
+		r.Commit()
+	}
+}
+
+// Release освобождает места рядов незаписанной пачки
+func (rs Reservations) Release() {
+	for _, r := range rs {
+		r.Release()
+	}
+}
+
+// add запоминает существующий ряд; зарезервированный ряд становится известным
+func (g *Guard) add(s storage.Series) {
+	if _, ok := g.known[s]; ok {
+		return
+	}
+	g.known[s] = struct{}{}
+	if _, ok := g.pending[s]; ok {
+		delete(g.pending, s)
+		return
+	}
+	g.countPrefixes(s.Name, 1)
+}
+
+func (g *Guard) countPrefixes(name string, delta int) {
+	for prefix := range g.limits.Prefixes {
+		if strings.HasPrefix(name, prefix) {
+			g.prefixes[prefix] += delta
+		}
+	}
+}
+
+// Rejected возвращает число отклоненных новых рядов с предыдущего вызова
+func (g *Guard) Rejected() int64 {
+	if g == nil {
+		return 0
+	}
+	return g.rejected.Swap(0)
+}
@@ -0,0 +1,110 @@
+package cardinality
+
+import (
+	"context"
+	"testing"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/storage"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/tenant"
+	"github.com/stretchr/testify/require"
+)
+
+// admit резервирует ряд и сразу подтверждает его, как после успешной записи
+func admit(g *Guard, ctx context.Context, mType, name string) error {
+	reservation, err := g.Reserve(ctx, mType, name)
+	if err != nil {
+		return err
+	}
+	reservation.Commit()
+	return nil
+}
+
+func TestReserve(t *testing.T) {
+	ctx := context.Background()
+	guard := New(Limits{MaxSeries: 3, Prefixes: map[string]int{"app.": 1}})
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		mType   string
+		metric  string
+		wantErr bool
+	}{
+		{"new series", ctx, "gauge", "cpu", false},
+		{"existing series", ctx, "gauge", "cpu", false},
+		{"prefix series", ctx, "gauge", "app.requests", false},
+		{"prefix limit", ctx, "gauge", "app.errors", true},
+		{"same name other type", ctx, "counter", "cpu", false},
+		{"total limit", ctx, "gauge", "memory", true},
+		{"total limit for other tenant", tenant.WithTenant(ctx, "payments"), "gauge", "cpu", true},
+		{"update of existing series past limit", ctx, "gauge", "app.requests", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := admit(guard, tt.ctx, tt.mType, tt.metric)
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrSeriesLimit)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+
+	require.Equal(t, int64(3), guard.Rejected())
+	require.Equal(t, int64(0), guard.Rejected())
+}
+
+func TestRelease(t *testing.T) {
+	ctx := context.Background()
+	guard := New(Limits{MaxSeries: 1, Prefixes: map[string]int{"app.": 1}})
+
+	// незавершенная запись занимает место под ряд
+	pending, err := guard.Reserve(ctx, "gauge", "app.requests")
+	require.NoError(t, err)
+	_, err = guard.Reserve(ctx, "gauge", "cpu")
+	require.ErrorIs(t, err, ErrSeriesLimit)
+
+	// тот же ряд в параллельном запросе использует общее место
+	shared, err := guard.Reserve(ctx, "gauge", "app.requests")
+	require.NoError(t, err)
+
+	// неудачная запись освобождает место
+	pending.Release()
+	pending.Release()
+	shared.Release()
+	reservation, err := guard.Reserve(ctx, "gauge", "app.errors")
+	require.NoError(t, err)
+
+	reservation.Commit()
+	reservation.Release()
+	require.NoError(t, admit(guard, ctx, "gauge", "app.errors"))
+	require.ErrorIs(t, admit(guard, ctx, "gauge", "app.requests"), ErrSeriesLimit)
+}
+
+func TestLoad(t *testing.T) {
+	ctx := context.Background()
+	memStorage := storage.NewMemStorage("")
+	require.NoError(t, memStorage.SetGauge(ctx, "app.requests", 1))
+	require.NoError(t, memStorage.SetCounter(tenant.WithTenant(ctx, "payments"), "tx", 1))
+
+	guard := New(Limits{MaxSeries: 3, Prefixes: map[string]int{"app.": 1}})
+	require.NoError(t, guard.Load(ctx, memStorage))
+
+	require.NoError(t, admit(guard, ctx, "gauge", "app.requests"))
+	require.ErrorIs(t, admit(guard, ctx, "gauge", "app.errors"), ErrSeriesLimit)
+	require.NoError(t, admit(guard, ctx, "gauge", "cpu"))
+	require.ErrorIs(t, admit(guard, ctx, "gauge", "memory"), ErrSeriesLimit)
+}
+
+func TestNilGuard(t *testing.T) {
+	guard := New(Limits{})
+	require.Nil(t, guard)
+	reservation, err := guard.Reserve(context.Background(), "gauge", "cpu")
+	require.NoError(t, err)
+	require.Nil(t, reservation)
+	reservation.Commit()
+	reservation.Release()
+	require.NoError(t, guard.Load(context.Background(), storage.NewMemStorage("")))
+	require.Equal(t, int64(0), guard.Rejected())
+}
@@ -78,3 +78,28 @@ func TestReadConfig(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
 }
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		flags   ClientFlags
+		wantErr bool
+	}{
+		{name: "scraping disabled", flags: ClientFlags{ScrapeInterval: 0}},
+		{name: "valid scraping", flags: ClientFlags{ScrapeTargets: []string{"http://agent:9100/metrics.json"}, ScrapeInterval: 30, ScrapeTimeout: 5}},
+		{name: "zero interval", flags: ClientFlags{ScrapeTargets: []string{"http://agent:9100/metrics.json"}, ScrapeInterval: 0}, wantErr: true},
+		{name: "negative interval", flags: ClientFlags{ScrapeTargets: []string{"http://agent:9100/metrics.json"}, ScrapeInterval: -1}, wantErr: true},
+		{name: "negative timeout", flags: ClientFlags{ScrapeTargets: []string{"http://agent:9100/metrics.json"}, ScrapeInterval: 30, ScrapeTimeout: -1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.flags.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package dbstorage
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/storage"
+)
+
+var errBreakerOpen = errors.New("circuit breaker is open")
+
+// breaker размыкает цепь после серии ошибок недоступности БД, чтобы не
+// нагружать БД запросами, пока она не восстановится
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openedAt  time.Time
+	probing   bool // после cooldown выполняется пробный запрос
+	now       func() time.Time
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// Allow возвращает ошибку, если цепь разомкнута. После cooldown пропускается
+// один пробный запрос (half-open), остальные отклоняются до его результата
+func (b *breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.tripped() {
+		return nil
+	}
+	if b.probing || b.now().Sub(b.openedAt) < b.cooldown {
+		return fmt.Errorf("%w: %w", storage.ErrUnavailable, errBreakerOpen)
+	}
+	b.probing = true
+	return nil
+}
+
+// State возвращает ошибку, если цепь разомкнута
+func (b *breaker) State() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.tripped() {
+		return fmt.Errorf("%w: %w", storage.ErrUnavailable, errBreakerOpen)
+	}
+	return nil
+}
+
+// Report учитывает результат запроса к БД
+func (b *breaker) Report(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if !errors.Is(wrapError(err), storage.ErrUnavailable) {
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.threshold > 0 && b.failures >= b.threshold {
+		b.openedAt = b.now()
+	}
+}
+
+// tripped сообщает, что цепь разомкнута серией ошибок
+func (b *breaker) tripped() bool {
+	return b.threshold > 0 && b.failures >= b.threshold
+}
@@ -0,0 +1,47 @@
+package dbstorage
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/dbstorage/migrations"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/handlers"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/storage/storagetest"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPostgresStorageFakeConformance проверяет запросы хранилища на fakeDB без реальной БД
+func TestPostgresStorageFakeConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) handlers.Storage {
+		return newStorage(newFakeDB(), Options{Retries: 3, RetryDelay: time.Millisecond})
+	})
+}
+
+// TestPostgresStorageConformance запускается только при заданной переменной TEST_DBDSN
+func TestPostgresStorageConformance(t *testing.T) {
+	dsn := os.Getenv("TEST_DBDSN")
+	if dsn == "" {
+		t.Skip("TEST_DBDSN is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	require.NoError(t, migrations.Up(ctx, db))
+
+	pg, err := newPostgresStorage(ctx, dsn, Options{Retries: 3, RetryDelay: 10 * time.Millisecond})
+	require.NoError(t, err)
+	defer pg.Close()
+
+	storagetest.Run(t, func(t *testing.T) handlers.Storage {
+		_, err := pg.db.Exec(ctx, "TRUNCATE metrics")
+		require.NoError(t, err)
+		return pg
+	})
+}
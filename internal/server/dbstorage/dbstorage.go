@@ -10,39 +10,98 @@ import (
 	"sync"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/romanmendelproject/go-yandex-metrics/internal/server/metrics"
 	"github.com/romanmendelproject/go-yandex-metrics/internal/server/storage"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/tenant"
 	log "github.com/sirupsen/logrus"
 )
 
+// pool методы пула соединений pgxpool.Pool, используемые хранилищем
+type pool interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
+	Ping(ctx context.Context) error
+	Close()
+}
+
 // PostgresStorage определяет объект для работы с БД
 type PostgresStorage struct {
-	db *pgxpool.Pool
+	db      pool
+	opts    Options
+	breaker *breaker
 }
 
+const (
+	upsertGaugeQuery = `INSERT INTO metrics (tenant, name, type, gauge) VALUES ($1, $2, 'gauge', $3)
+		ON CONFLICT (tenant, type, name) DO UPDATE SET gauge = EXCLUDED.gauge`
+	upsertCounterQuery = `INSERT INTO metrics (tenant, name, type, counter) VALUES ($1, $2, 'counter', $3)
+		ON CONFLICT (tenant, type, name) DO UPDATE SET counter = metrics.counter + EXCLUDED.counter`
+	selectCounterQuery = `SELECT counter FROM metrics WHERE tenant = $1 AND name = $2 AND type = 'counter'`
+	selectGaugeQuery   = `SELECT gauge FROM metrics WHERE tenant = $1 AND name = $2 AND type = 'gauge'`
+	selectAllQuery     = `SELECT type, name, gauge, counter FROM metrics WHERE tenant = $1`
+	listSeriesQuery    = `SELECT tenant, type, name FROM metrics`
+)
+
 var (
 	pgInstance *PostgresStorage
+	pgErr      error
 	pgOnce     sync.Once
 )
 
 // NewPostgresStorage создает объект для работы с БД
-func NewPostgresStorage(ctx context.Context, connString string) *PostgresStorage {
+func NewPostgresStorage(ctx context.Context, connString string, opts Options) (*PostgresStorage, error) {
 	pgOnce.Do(func() {
-		db, err := pgxpool.New(ctx, connString)
-		if err != nil {
-			log.Fatal("unable to create connection pool: %w", err)
-		}
-
-		pgInstance = &PostgresStorage{db}
+		pgInstance, pgErr = newPostgresStorage(ctx, connString, opts)
 	})
 
-	return pgInstance
+	return pgInstance, pgErr
+}
+
+func newPostgresStorage(ctx context.Context, connString string, opts Options) (*PostgresStorage, error) {
+	poolConfig, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse connection string: %w", err)
+	}
+	if opts.MaxConns > 0 {
+		poolConfig.MaxConns = opts.MaxConns
+	}
+	if opts.ConnectTimeout > 0 {
+		poolConfig.ConnConfig.ConnectTimeout = opts.ConnectTimeout
+	}
+
+	db, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create connection pool: %w", err)
+	}
+
+	return newStorage(db, opts), nil
+}
+
+// newStorage создает хранилище поверх пула соединений
+func newStorage(db pool, opts Options) *PostgresStorage {
+	return &PostgresStorage{
+		db:      db,
+		opts:    opts,
+		breaker: newBreaker(opts.BreakerThreshold, opts.BreakerCooldown),
+	}
 }
 
 // Ping проверяет доступность БД
 func (pg *PostgresStorage) Ping(ctx context.Context) error {
-	return pg.db.Ping(ctx)
+	err := pg.do(ctx, pg.db.Ping)
+	if err != nil && !errors.Is(err, storage.ErrUnavailable) {
+		return fmt.Errorf("%w: %w", storage.ErrUnavailable, err)
+	}
+	return err
+}
+
+// Ready сообщает о готовности БД по состоянию circuit breaker без обращения к БД
+func (pg *PostgresStorage) Ready() error {
+	return pg.breaker.State()
 }
 
 // Close закрывает соединение с БД
@@ -52,35 +111,11 @@ func (pg *PostgresStorage) Close() {
 
 // SetGauge записывает данные формата Gauge в БД
 func (pg *PostgresStorage) SetGauge(ctx context.Context, name string, value float64) error {
-	var oldVal float64
-
-	tx, err := pg.db.BeginTx(ctx, pgx.TxOptions{})
-	defer func() {
-		if err != nil {
-			tx.Rollback(ctx)
-		} else {
-			tx.Commit(ctx)
-		}
-	}()
-
-	// Check if metric exists
-	if err := tx.QueryRow(ctx, `SELECT gauge FROM metrics WHERE name=$1 AND type = 'gauge'`, name).Scan(&oldVal); err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			// Insert new metric if not exists
-			log.Error(name)
-			if _, err := tx.Exec(ctx, `INSERT INTO metrics (name, type, gauge) VALUES ($1, 'gauge', $2)`, name, value); err != nil {
-				log.Error(err)
-				return err
-			}
-			return nil
-		}
-
-		log.Error(err)
+	err := pg.do(ctx, func(ctx context.Context) error {
+		_, err := pg.db.Exec(ctx, upsertGaugeQuery, tenant.FromContext(ctx), name, value)
 		return err
-	}
-
-	// Update metric if exists
-	if _, err := tx.Exec(ctx, `UPDATE metrics SET gauge = $1 WHERE type = 'gauge' AND name = $2`, value, name); err != nil {
+	})
+	if err != nil {
 		log.Error(err)
 		return err
 	}
@@ -89,51 +124,30 @@ func (pg *PostgresStorage) SetGauge(ctx context.Context, name string, value floa
 
 // SetCounter записывает данные формата Counter в БД
 func (pg *PostgresStorage) SetCounter(ctx context.Context, name string, value int64) error {
-	var oldVal int64
-
-	tx, err := pg.db.BeginTx(ctx, pgx.TxOptions{})
-	defer func() {
-		if err != nil {
-			tx.Rollback(ctx)
-		} else {
-			tx.Commit(ctx)
-		}
-	}()
-
-	if err := tx.QueryRow(ctx, "SELECT counter FROM metrics WHERE name = $1 AND type = 'counter'", name).Scan(&oldVal); err != nil {
-		// Insert new metric if not exists
-		if errors.Is(err, pgx.ErrNoRows) {
-			if _, err := tx.Exec(ctx, `INSERT INTO metrics (name, type, counter) VALUES ($1, 'counter', $2)`, name, value); err != nil {
-				log.Error(err)
-				return err
-			}
-
-			return nil
-		}
+	err := pg.doOnce(ctx, func(ctx context.Context) error {
+		_, err := pg.db.Exec(ctx, upsertCounterQuery, tenant.FromContext(ctx), name, value)
 		return err
-	}
-
-	// Update metric if exists
-	value += oldVal
-	if _, err := tx.Exec(ctx, `UPDATE metrics SET counter = $1 WHERE type = 'counter' AND name = $2`, value, name); err != nil {
+	})
+	if err != nil {
 		log.Error(err)
 		return err
 	}
-
 	return nil
-
 }
 
 // GetCounter читает данные формата Counter из БД
 func (pg *PostgresStorage) GetCounter(ctx context.Context, name string) (int64, error) {
 	var counter sql.NullInt64
 
-	if err := pg.db.QueryRow(ctx, "SELECT counter FROM metrics WHERE name = $1 AND type = 'counter'", name).Scan(&counter); err != nil {
+	err := pg.do(ctx, func(ctx context.Context) error {
+		return pg.db.QueryRow(ctx, selectCounterQuery, tenant.FromContext(ctx), name).Scan(&counter)
+	})
+	if err != nil {
 		return 0, err
 	}
 
 	if !counter.Valid {
-		return 0, fmt.Errorf("unexpected type of metric")
+		return 0, fmt.Errorf("%w: %s", storage.ErrTypeMismatch, name)
 	}
 
 	return counter.Int64, nil
@@ -143,120 +157,149 @@ func (pg *PostgresStorage) GetCounter(ctx context.Context, name string) (int64,
 func (pg *PostgresStorage) GetGauge(ctx context.Context, name string) (float64, error) {
 	var gauge sql.NullFloat64
 
-	if err := pg.db.QueryRow(ctx, "SELECT gauge FROM metrics WHERE name = $1 AND type = 'gauge'", name).Scan(&gauge); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			log.Error(err)
-			return 0, nil
-		}
+	err := pg.do(ctx, func(ctx context.Context) error {
+		return pg.db.QueryRow(ctx, selectGaugeQuery, tenant.FromContext(ctx), name).Scan(&gauge)
+	})
+	if err != nil {
 		return 0, err
 	}
 
 	if !gauge.Valid {
-		return 0, fmt.Errorf("unexpected type of metric")
+		return 0, fmt.Errorf("%w: %s", storage.ErrTypeMismatch, name)
 	}
 
 	return gauge.Float64, nil
 }
 
-// GetGauge все данные из БД
+// GetAll читает все данные из БД
 func (pg *PostgresStorage) GetAll(ctx context.Context) ([]storage.Value, error) {
 	var values []storage.Value
 
-	rows, err := pg.db.Query(ctx, `SELECT type, name, gauge, counter FROM metrics`)
+	err := pg.do(ctx, func(ctx context.Context) error {
+		values = nil
+
+		rows, err := pg.db.Query(ctx, selectAllQuery, tenant.FromContext(ctx))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var mType string
+			var name string
+			var gauge sql.NullFloat64
+			var counter sql.NullInt64
+
+			if err := rows.Scan(&mType, &name, &gauge, &counter); err != nil {
+				return err
+			}
+
+			if gauge.Valid {
+				values = append(values, storage.Value{
+					Name:  name,
+					Type:  "gauge",
+					Value: strconv.FormatFloat(gauge.Float64, 'f', 1, 64),
+				})
+			} else if counter.Valid {
+				values = append(values, storage.Value{
+					Name:  name,
+					Type:  "counter",
+					Value: counter.Int64,
+				})
+			} else {
+				return fmt.Errorf("%w: %s", storage.ErrTypeMismatch, name)
+			}
+		}
+
+		return rows.Err()
+	})
 	if err != nil {
 		log.Error(err)
 		return nil, err
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var mType string
-		var name string
-		var gauge sql.NullFloat64
-		var counter sql.NullInt64
+	return values, nil
+}
 
-		if err := rows.Scan(&mType, &name, &gauge, &counter); err != nil {
-			log.Error(err)
-			return nil, err
+// ListSeries читает из БД ряды метрик всех тенантов
+func (pg *PostgresStorage) ListSeries(ctx context.Context) ([]storage.Series, error) {
+	var series []storage.Series
+
+	err := pg.do(ctx, func(ctx context.Context) error {
+		series = nil
+
+		rows, err := pg.db.Query(ctx, listSeriesQuery)
+		if err != nil {
+			return err
 		}
+		defer rows.Close()
 
-		if gauge.Valid {
-			values = append(values, storage.Value{
-				Name:  name,
-				Type:  "gauge",
-				Value: strconv.FormatFloat(gauge.Float64, 'f', 1, 64),
-			})
-		} else if counter.Valid {
-			values = append(values, storage.Value{
-				Name:  name,
-				Type:  "counter",
-				Value: counter.Int64,
-			})
-		} else {
-			log.Error(err)
-			return nil, err
+		for rows.Next() {
+			var s storage.Series
+			if err := rows.Scan(&s.Tenant, &s.Type, &s.Name); err != nil {
+				return err
+			}
+			series = append(series, s)
 		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		log.Error(err)
+		return nil, err
 	}
 
-	return values, nil
+	return series, nil
 }
 
 // SetBatch записывает данные в БД с использование одного запроса
 func (pg *PostgresStorage) SetBatch(ctx context.Context, metrics []metrics.Metric) error {
-	tx, err := pg.db.BeginTx(ctx, pgx.TxOptions{})
-	defer func() {
-		if err != nil {
-			tx.Rollback(ctx)
-		} else {
-			tx.Commit(ctx)
+	for _, metric := range metrics {
+		switch {
+		case metric.MType == "gauge" && metric.Value == nil,
+			metric.MType == "counter" && metric.Delta == nil:
+			return fmt.Errorf("%w: %s", storage.ErrInvalidValue, metric.ID)
+		case metric.MType != "gauge" && metric.MType != "counter":
+			return fmt.Errorf("%w: %s", storage.ErrTypeMismatch, metric.ID)
 		}
-	}()
-
-	batch := &pgx.Batch{}
-
-	query := `INSERT INTO metrics (type, name, counter, gauge) VALUES ($1, $2, $3, $4)`
+	}
 
+	// пачка со счетчиками неидемпотентна и не повторяется после отправки
+	do := pg.do
 	for _, metric := range metrics {
 		if metric.MType == "counter" {
-			var oldCounter int64
-			if err := tx.QueryRow(ctx, `SELECT counter FROM metrics WHERE name=$1 AND type = 'counter'`, metric.ID).Scan(&oldCounter); err != nil {
-				if errors.Is(err, pgx.ErrNoRows) {
-					if _, err := tx.Exec(ctx, query, metric.MType, metric.ID, metric.Delta, metric.Value); err != nil {
-						log.Error(err)
-						return err
-					}
-					continue
-				}
-
-				log.Error(err)
-				return err
-			}
-
-			*metric.Delta += oldCounter
-
-			batch.Queue(`UPDATE metrics SET counter = $1 WHERE type = 'counter' AND name = $2`, metric.Delta, metric.ID)
-
-		} else if metric.MType == "gauge" {
-			var oldGauge float64
-			if err := tx.QueryRow(ctx, `SELECT 1 FROM metrics WHERE name=$1 AND type = 'gauge'`, metric.ID).Scan(&oldGauge); err != nil {
-				if errors.Is(err, pgx.ErrNoRows) {
-					if _, err := tx.Exec(ctx, query, metric.MType, metric.ID, metric.Delta, metric.Value); err != nil {
-						log.Error(err)
-						return err
-					}
-					continue
-				}
+			do = pg.doOnce
+			break
+		}
+	}
 
-				log.Error(err)
-				return err
+	tenantName := tenant.FromContext(ctx)
+	err := do(ctx, func(ctx context.Context) error {
+		batch := &pgx.Batch{}
+		for _, metric := range metrics {
+			if metric.MType == "gauge" {
+				batch.Queue(upsertGaugeQuery, tenantName, metric.ID, *metric.Value)
+			} else {
+				batch.Queue(upsertCounterQuery, tenantName, metric.ID, *metric.Delta)
 			}
+		}
 
-			batch.Queue(`UPDATE metrics SET gauge = $1 WHERE type = 'gauge' AND name = $2`, metric.Value, metric.ID)
+		tx, err := pg.db.BeginTx(ctx, pgx.TxOptions{})
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+
+		if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+			return err
 		}
-	}
 
-	br := tx.SendBatch(ctx, batch)
-	br.Close()
+		return tx.Commit(ctx)
+	})
+	if err != nil {
+		log.Error(err)
+		return err
+	}
 
 	return nil
 }
@@ -0,0 +1,76 @@
+package dbstorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/storage"
+)
+
+// wrapError приводит ошибки pgx к ошибкам хранилища
+func wrapError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: %w", storage.ErrNotFound, err)
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		// Class 08 — Connection Exception, class 57 — Operator Intervention
+		case strings.HasPrefix(pgErr.Code, "08"), strings.HasPrefix(pgErr.Code, "57"):
+			return fmt.Errorf("%w: %w", storage.ErrUnavailable, err)
+		// Class 22 — Data Exception
+		case strings.HasPrefix(pgErr.Code, "22"):
+			return fmt.Errorf("%w: %w", storage.ErrInvalidValue, err)
+		}
+		return err
+	}
+
+	var connErr *pgconn.ConnectError
+	var netErr net.Error
+	if errors.As(err, &connErr) || errors.As(err, &netErr) ||
+		pgconn.Timeout(err) || errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", storage.ErrUnavailable, err)
+	}
+
+	return err
+}
+
+// isRetriable определяет ошибки, после которых запрос можно безопасно повторить
+func isRetriable(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		// Connection Exception, serialization_failure, deadlock_detected, cannot_connect_now
+		case strings.HasPrefix(pgErr.Code, "08"), pgErr.Code == "40001",
+			pgErr.Code == "40P01", pgErr.Code == "57P03":
+			return true
+		}
+		return false
+	}
+
+	var connErr *pgconn.ConnectError
+	return errors.As(err, &connErr) || pgconn.SafeToRetry(err)
+}
+
+// isSafeToRetry определяет ошибки, при которых запрос гарантированно не был применен.
+// После ошибок соединения класса 08 результат запроса неизвестен
+func isSafeToRetry(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// serialization_failure и deadlock_detected откатывают транзакцию, cannot_connect_now — до запроса
+		return pgErr.Code == "40001" || pgErr.Code == "40P01" || pgErr.Code == "57P03"
+	}
+
+	var connErr *pgconn.ConnectError
+	return errors.As(err, &connErr) || pgconn.SafeToRetry(err)
+}
@@ -0,0 +1,37 @@
+package dbstorage
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"no rows", pgx.ErrNoRows, storage.ErrNotFound},
+		{"wrapped no rows", fmt.Errorf("query: %w", pgx.ErrNoRows), storage.ErrNotFound},
+		{"connection failure", &pgconn.PgError{Code: "08006"}, storage.ErrUnavailable},
+		{"admin shutdown", &pgconn.PgError{Code: "57P01"}, storage.ErrUnavailable},
+		{"numeric out of range", &pgconn.PgError{Code: "22003"}, storage.ErrInvalidValue},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorIs(t, wrapError(tt.err), tt.want)
+		})
+	}
+
+	require.NoError(t, wrapError(nil))
+
+	other := errors.New("error")
+	require.Equal(t, other, wrapError(other))
+}
@@ -0,0 +1,233 @@
+package dbstorage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// fakeKey первичный ключ таблицы metrics
+type fakeKey struct {
+	tenant, mType, name string
+}
+
+// fakeRow строка таблицы metrics
+type fakeRow struct {
+	gauge   any
+	counter any
+}
+
+// fakeDB реализация pool в памяти, понимающая запросы хранилища к таблице metrics
+type fakeDB struct {
+	mu   sync.Mutex
+	rows map[fakeKey]fakeRow
+	// afterWrite возвращает ошибку клиенту после применения записи, как при обрыве соединения
+	afterWrite func() error
+}
+
+func newFakeDB() *fakeDB {
+	return &fakeDB{rows: make(map[fakeKey]fakeRow)}
+}
+
+func (db *fakeDB) Exec(_ context.Context, query string, args ...any) (pgconn.CommandTag, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tag, err := db.exec(db.rows, query, args)
+	if err != nil {
+		return tag, err
+	}
+	return tag, db.written()
+}
+
+func (db *fakeDB) written() error {
+	if db.afterWrite == nil {
+		return nil
+	}
+	return db.afterWrite()
+}
+
+func (db *fakeDB) exec(rows map[fakeKey]fakeRow, query string, args []any) (pgconn.CommandTag, error) {
+	switch query {
+	case upsertGaugeQuery:
+		key := fakeKey{args[0].(string), "gauge", args[1].(string)}
+		rows[key] = fakeRow{gauge: args[2].(float64)}
+	case upsertCounterQuery:
+		key := fakeKey{args[0].(string), "counter", args[1].(string)}
+		value := args[2].(int64)
+		if row, ok := rows[key]; ok {
+			value += row.counter.(int64)
+		}
+		rows[key] = fakeRow{counter: value}
+	case "TRUNCATE metrics":
+		for key := range rows {
+			delete(rows, key)
+		}
+		return pgconn.NewCommandTag("TRUNCATE TABLE"), nil
+	default:
+		return pgconn.CommandTag{}, fmt.Errorf("fakedb: unsupported query %q", query)
+	}
+	return pgconn.NewCommandTag("INSERT 0 1"), nil
+}
+
+func (db *fakeDB) Query(_ context.Context, query string, args ...any) (pgx.Rows, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var values [][]any
+	switch query {
+	case selectAllQuery:
+		for key, row := range db.rows {
+			if key.tenant == args[0].(string) {
+				values = append(values, []any{key.mType, key.name, row.gauge, row.counter})
+			}
+		}
+	case listSeriesQuery:
+		for key := range db.rows {
+			values = append(values, []any{key.tenant, key.mType, key.name})
+		}
+	default:
+		return nil, fmt.Errorf("fakedb: unsupported query %q", query)
+	}
+
+	sort.Slice(values, func(i, j int) bool {
+		return fmt.Sprint(values[i]) < fmt.Sprint(values[j])
+	})
+	return &fakeRows{values: values, pos: -1}, nil
+}
+
+func (db *fakeDB) QueryRow(_ context.Context, query string, args ...any) pgx.Row {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var mType string
+	switch query {
+	case selectCounterQuery:
+		mType = "counter"
+	case selectGaugeQuery:
+		mType = "gauge"
+	default:
+		return errRow(fmt.Errorf("fakedb: unsupported query %q", query))
+	}
+
+	row, ok := db.rows[fakeKey{args[0].(string), mType, args[1].(string)}]
+	if !ok {
+		return errRow(pgx.ErrNoRows)
+	}
+	if mType == "counter" {
+		return &fakeRows{values: [][]any{{row.counter}}}
+	}
+	return &fakeRows{values: [][]any{{row.gauge}}}
+}
+
+func (db *fakeDB) BeginTx(context.Context, pgx.TxOptions) (pgx.Tx, error) {
+	return &fakeTx{db: db}, nil
+}
+
+func (db *fakeDB) Ping(context.Context) error {
+	return nil
+}
+
+func (db *fakeDB) Close() {}
+
+// errRow результат QueryRow, завершившийся ошибкой
+func errRow(err error) pgx.Row {
+	return &fakeRows{err: err}
+}
+
+// fakeRows результат запроса к fakeDB
+type fakeRows struct {
+	pgx.Rows
+	values [][]any
+	pos    int
+	err    error
+}
+
+func (r *fakeRows) Next() bool {
+	r.pos++
+	return r.err == nil && r.pos < len(r.values)
+}
+
+func (r *fakeRows) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	if r.pos >= len(r.values) {
+		return pgx.ErrNoRows
+	}
+
+	for i, value := range r.values[r.pos] {
+		if scanner, ok := dest[i].(sql.Scanner); ok {
+			if err := scanner.Scan(value); err != nil {
+				return err
+			}
+			continue
+		}
+		reflect.ValueOf(dest[i]).Elem().Set(reflect.ValueOf(value))
+	}
+	return nil
+}
+
+func (r *fakeRows) Err() error {
+	return r.err
+}
+
+func (r *fakeRows) Close() {}
+
+// fakeTx транзакция fakeDB: запросы копятся и применяются разом при Commit
+type fakeTx struct {
+	pgx.Tx
+	db      *fakeDB
+	queries []*pgx.QueuedQuery
+	done    bool
+}
+
+func (tx *fakeTx) SendBatch(_ context.Context, batch *pgx.Batch) pgx.BatchResults {
+	tx.queries = append(tx.queries, batch.QueuedQueries...)
+	return fakeBatchResults{}
+}
+
+func (tx *fakeTx) Commit(context.Context) error {
+	if tx.done {
+		return pgx.ErrTxClosed
+	}
+	tx.done = true
+
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+
+	staged := make(map[fakeKey]fakeRow, len(tx.db.rows))
+	for key, row := range tx.db.rows {
+		staged[key] = row
+	}
+	for _, q := range tx.queries {
+		if _, err := tx.db.exec(staged, q.SQL, q.Arguments); err != nil {
+			return err
+		}
+	}
+	tx.db.rows = staged
+	return tx.db.written()
+}
+
+func (tx *fakeTx) Rollback(context.Context) error {
+	if tx.done {
+		return pgx.ErrTxClosed
+	}
+	tx.done = true
+	return nil
+}
+
+// fakeBatchResults результат пакета запросов fakeTx
+type fakeBatchResults struct {
+	pgx.BatchResults
+}
+
+func (fakeBatchResults) Close() error {
+	return nil
+}
@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pressly/goose/v3"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	goose.AddMigrationContext(upUniqueMetric, downUniqueMetric)
+}
+
+func upUniqueMetric(ctx context.Context, tx *sql.Tx) error {
+	// This code is executed when the migration is applied.
+	log.Info("Create unique metric index")
+
+	// Remove duplicates left by the previous non-atomic writes
+	query := `
+		DELETE FROM metrics a USING metrics b
+		WHERE a.id < b.id AND a.type = b.type AND a.name = b.name
+	`
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "CREATE UNIQUE INDEX IF NOT EXISTS metrics_type_name_idx ON metrics (type, name)"); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func downUniqueMetric(ctx context.Context, tx *sql.Tx) error {
+	// This code is executed when the migration is rolled back.
+	log.Info("Remove unique metric index")
+
+	if _, err := tx.ExecContext(ctx, "DROP INDEX IF EXISTS metrics_type_name_idx"); err != nil {
+		return err
+	}
+
+	return nil
+}
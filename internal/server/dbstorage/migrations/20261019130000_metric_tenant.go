@@ -0,0 +1,50 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pressly/goose/v3"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	goose.AddMigrationContext(upMetricTenant, downMetricTenant)
+}
+
+func upMetricTenant(ctx context.Context, tx *sql.Tx) error {
+	// This code is executed when the migration is applied.
+	log.Info("Add tenant to metrics")
+
+	queries := []string{
+		"ALTER TABLE metrics ADD COLUMN IF NOT EXISTS tenant VARCHAR(64) NOT NULL DEFAULT 'default'",
+		"DROP INDEX IF EXISTS metrics_type_name_idx",
+		"CREATE UNIQUE INDEX IF NOT EXISTS metrics_tenant_type_name_idx ON metrics (tenant, type, name)",
+	}
+	for _, query := range queries {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func downMetricTenant(ctx context.Context, tx *sql.Tx) error {
+	// This code is executed when the migration is rolled back.
+	log.Info("Remove tenant from metrics")
+
+	queries := []string{
+		"DELETE FROM metrics WHERE tenant <> 'default'",
+		"DROP INDEX IF EXISTS metrics_tenant_type_name_idx",
+		"CREATE UNIQUE INDEX IF NOT EXISTS metrics_type_name_idx ON metrics (type, name)",
+		"ALTER TABLE metrics DROP COLUMN IF EXISTS tenant",
+	}
+	for _, query := range queries {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
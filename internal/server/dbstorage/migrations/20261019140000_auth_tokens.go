@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pressly/goose/v3"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	goose.AddMigrationContext(upAuthTokens, downAuthTokens)
+}
+
+func upAuthTokens(ctx context.Context, tx *sql.Tx) error {
+	// This code is executed when the migration is applied.
+	log.Info("Create auth tokens table")
+
+	query := `
+		CREATE TABLE IF NOT EXISTS auth_tokens (
+		    id VARCHAR(32) PRIMARY KEY,
+		    hash CHAR(64) NOT NULL UNIQUE,
+		    scopes TEXT[] NOT NULL,
+		    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)
+	`
+
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func downAuthTokens(ctx context.Context, tx *sql.Tx) error {
+	// This code is executed when the migration is rolled back.
+	log.Info("Remove auth tokens table")
+
+	if _, err := tx.ExecContext(ctx, "DROP TABLE IF EXISTS auth_tokens"); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package migrations
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunInvalidCommand(t *testing.T) {
+	// Команды проверяются до обращения к БД, поэтому соединение не устанавливается
+	db, err := sql.Open("postgres", "user=username password=userpassword dbname=dbname sslmode=disable")
+	require.NoError(t, err)
+	defer db.Close()
+
+	tests := []struct {
+		name    string
+		command string
+		args    []string
+	}{
+		{"unknown command", "sideways", nil},
+		{"extra argument", "up", []string{"1"}},
+		{"missing version", "to", nil},
+		{"invalid version", "to", []string{"latest"}},
+		{"negative version", "to", []string{"-1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := Run(context.Background(), db, &out, tt.command, tt.args...)
+			require.Error(t, err)
+			require.Empty(t, out.String())
+		})
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	db, err := sql.Open("postgres", "user=username password=userpassword dbname=dbname sslmode=disable")
+	require.NoError(t, err)
+	defer db.Close()
+
+	provider, err := NewProvider(db)
+	require.NoError(t, err)
+
+	sources := provider.ListSources()
+	require.Len(t, sources, 4)
+	require.Equal(t, int64(20240716173651), sources[0].Version)
+}
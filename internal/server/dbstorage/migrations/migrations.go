@@ -0,0 +1,139 @@
+// Модуль управления миграциями БД
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/pressly/goose/v3"
+)
+
+// ErrSchemaBehind схема БД отстает от миграций, зарегистрированных в пакете
+var ErrSchemaBehind = errors.New("database schema is behind, run migrations")
+
+// Commands перечисляет поддерживаемые команды миграции
+const Commands = "up, down, status, redo, to <version>"
+
+// NewProvider создает goose.Provider для миграций, зарегистрированных в пакете,
+// без обращения к файловой системе
+func NewProvider(db *sql.DB) (*goose.Provider, error) {
+	return goose.NewProvider(goose.DialectPostgres, db, nil)
+}
+
+// Up применяет все неприменённые миграции
+func Up(ctx context.Context, db *sql.DB) error {
+	provider, err := NewProvider(db)
+	if err != nil {
+		return err
+	}
+	_, err = provider.Up(ctx)
+	return err
+}
+
+// CheckSchema возвращает ErrSchemaBehind, если есть неприменённые миграции
+func CheckSchema(ctx context.Context, db *sql.DB) error {
+	provider, err := NewProvider(db)
+	if err != nil {
+		return err
+	}
+	pending, err := provider.HasPending(ctx)
+	if err != nil {
+		return err
+	}
+	if pending {
+		current, target, err := provider.GetVersions(ctx)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: version %d, expected %d", ErrSchemaBehind, current, target)
+	}
+	return nil
+}
+
+// Run выполняет команду миграции и выводит результат в out
+func Run(ctx context.Context, db *sql.DB, out io.Writer, command string, args ...string) error {
+	var version int64
+	switch command {
+	case "up", "down", "status", "redo":
+		if len(args) != 0 {
+			return fmt.Errorf("command %q takes no arguments", command)
+		}
+	case "to":
+		if len(args) != 1 {
+			return errors.New("command \"to\" requires a version")
+		}
+		v, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil || v < 0 {
+			return fmt.Errorf("invalid version %q", args[0])
+		}
+		version = v
+	default:
+		return fmt.Errorf("unknown command %q, expected one of: %s", command, Commands)
+	}
+
+	provider, err := NewProvider(db)
+	if err != nil {
+		return err
+	}
+
+	var results []*goose.MigrationResult
+	switch command {
+	case "up":
+		results, err = provider.Up(ctx)
+	case "down":
+		var result *goose.MigrationResult
+		result, err = provider.Down(ctx)
+		results = appendResult(results, result)
+	case "redo":
+		var result *goose.MigrationResult
+		if result, err = provider.Down(ctx); err == nil {
+			results = appendResult(results, result)
+			result, err = provider.UpByOne(ctx)
+		}
+		results = appendResult(results, result)
+	case "to":
+		var current int64
+		if current, err = provider.GetDBVersion(ctx); err != nil {
+			return err
+		}
+		if version >= current {
+			results, err = provider.UpTo(ctx, version)
+		} else {
+			results, err = provider.DownTo(ctx, version)
+		}
+	case "status":
+		return printStatus(ctx, provider, out)
+	}
+
+	for _, result := range results {
+		fmt.Fprintln(out, result)
+	}
+	return err
+}
+
+func appendResult(results []*goose.MigrationResult, result *goose.MigrationResult) []*goose.MigrationResult {
+	if result == nil {
+		return results
+	}
+	return append(results, result)
+}
+
+func printStatus(ctx context.Context, provider *goose.Provider, out io.Writer) error {
+	statuses, err := provider.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range statuses {
+		appliedAt := "-"
+		if s.State == goose.StateApplied {
+			appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(out, "%-8s %-20s %d %s\n", s.State, appliedAt, s.Source.Version, s.Source.Path)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package dbstorage
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Options определяет параметры пула соединений и повторных попыток
+type Options struct {
+	MaxConns         int32         // максимальный размер пула, 0 — значение pgxpool по умолчанию
+	ConnectTimeout   time.Duration // таймаут установки соединения
+	QueryTimeout     time.Duration // таймаут одной попытки запроса
+	Retries          int           // количество повторов при временных ошибках
+	RetryDelay       time.Duration // начальная задержка, удваивается с каждой попыткой
+	BreakerThreshold int           // число ошибок подряд для размыкания цепи, 0 — отключено
+	BreakerCooldown  time.Duration // время до пробного запроса после размыкания
+}
+
+// do выполняет запрос к БД с повторами временных ошибок через circuit breaker.
+// Повтор после ошибки соединения может применить запрос дважды, поэтому do подходит
+// только для идемпотентных запросов; приращения счетчиков выполняются через doOnce
+func (pg *PostgresStorage) do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return pg.retry(ctx, isRetriable, fn)
+}
+
+// doOnce выполняет неидемпотентный запрос, повторяя его только после ошибок,
+// при которых запрос гарантированно не был применен
+func (pg *PostgresStorage) doOnce(ctx context.Context, fn func(ctx context.Context) error) error {
+	return pg.retry(ctx, isSafeToRetry, fn)
+}
+
+func (pg *PostgresStorage) retry(ctx context.Context, retriable func(error) bool, fn func(ctx context.Context) error) error {
+	delay := pg.opts.RetryDelay
+	for attempt := 0; ; attempt++ {
+		if err := pg.breaker.Allow(); err != nil {
+			return err
+		}
+
+		err := pg.attempt(ctx, fn)
+		pg.breaker.Report(err)
+		if err == nil || !retriable(err) || attempt >= pg.opts.Retries {
+			return wrapError(err)
+		}
+
+		log.Warnf("Retriable DB error: %s. Retrying after %s...", err, delay)
+		select {
+		case <-ctx.Done():
+			return wrapError(err)
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func (pg *PostgresStorage) attempt(ctx context.Context, fn func(ctx context.Context) error) error {
+	if pg.opts.QueryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, pg.opts.QueryTimeout)
+		defer cancel()
+	}
+	return fn(ctx)
+}
@@ -0,0 +1,192 @@
+package dbstorage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/metrics"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/storage"
+	"github.com/romanmendelproject/go-yandex-metrics/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDoRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   error
+	}{
+		{"success", []error{nil}, 1, nil},
+		{"retry serialization failure", []error{&pgconn.PgError{Code: "40001"}, nil}, 2, nil},
+		{"retry connection failure", []error{&pgconn.PgError{Code: "08006"}, &pgconn.PgError{Code: "08006"}, nil}, 3, nil},
+		{"retries exhausted", []error{&pgconn.PgError{Code: "08006"}, &pgconn.PgError{Code: "08006"}, &pgconn.PgError{Code: "08006"}}, 3, storage.ErrUnavailable},
+		{"not retriable", []error{pgx.ErrNoRows}, 1, storage.ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pg := &PostgresStorage{
+				opts:    Options{Retries: 2, RetryDelay: time.Millisecond},
+				breaker: newBreaker(0, 0),
+			}
+
+			calls := 0
+			err := pg.do(context.Background(), func(ctx context.Context) error {
+				err := tt.errs[calls]
+				calls++
+				return err
+			})
+
+			require.Equal(t, tt.wantCalls, calls)
+			if tt.wantErr == nil {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+	b := newBreaker(2, time.Minute)
+	b.now = func() time.Time { return now }
+
+	unavailable := &pgconn.PgError{Code: "08006"}
+
+	b.Report(unavailable)
+	require.NoError(t, b.Allow())
+	require.NoError(t, b.State())
+
+	b.Report(unavailable)
+	require.ErrorIs(t, b.Allow(), storage.ErrUnavailable)
+	require.ErrorIs(t, b.State(), storage.ErrUnavailable)
+
+	// после cooldown пропускается пробный запрос
+	now = now.Add(time.Minute)
+	require.NoError(t, b.Allow())
+
+	b.Report(unavailable)
+	require.ErrorIs(t, b.Allow(), storage.ErrUnavailable)
+
+	now = now.Add(time.Minute)
+	b.Report(nil)
+	require.NoError(t, b.Allow())
+	require.NoError(t, b.State())
+
+	// ошибки, не связанные с доступностью БД, не размыкают цепь
+	b.Report(errors.New("error"))
+	b.Report(pgx.ErrNoRows)
+	require.NoError(t, b.State())
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	now := time.Now()
+	b := newBreaker(1, time.Minute)
+	b.now = func() time.Time { return now }
+	b.Report(&pgconn.PgError{Code: "08006"})
+	now = now.Add(time.Minute)
+
+	// после cooldown из параллельных запросов проходит только пробный
+	const callers = 16
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if b.Allow() == nil {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int32(1), allowed.Load())
+	require.ErrorIs(t, b.Allow(), errBreakerOpen)
+
+	// успешный пробный запрос замыкает цепь
+	b.Report(nil)
+	require.NoError(t, b.Allow())
+	require.NoError(t, b.Allow())
+}
+
+func TestDoBreakerOpen(t *testing.T) {
+	pg := &PostgresStorage{breaker: newBreaker(1, time.Minute)}
+	pg.breaker.Report(&pgconn.PgError{Code: "08006"})
+
+	called := false
+	err := pg.do(context.Background(), func(ctx context.Context) error {
+		called = true
+		return nil
+	})
+
+	require.False(t, called)
+	require.ErrorIs(t, err, errBreakerOpen)
+}
+
+func TestDoOnceRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantCalls int
+	}{
+		{"connection failure after send", &pgconn.PgError{Code: "08006"}, 1},
+		{"serialization failure", &pgconn.PgError{Code: "40001"}, 3},
+		{"connect error", &pgconn.ConnectError{}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pg := &PostgresStorage{
+				opts:    Options{Retries: 2, RetryDelay: time.Millisecond},
+				breaker: newBreaker(0, 0),
+			}
+
+			calls := 0
+			err := pg.doOnce(context.Background(), func(ctx context.Context) error {
+				calls++
+				return tt.err
+			})
+
+			require.Error(t, err)
+			require.Equal(t, tt.wantCalls, calls)
+		})
+	}
+}
+
+func TestCounterNotReappliedAfterConnectionFailure(t *testing.T) {
+	ctx := context.Background()
+	db := newFakeDB()
+	pg := newStorage(db, Options{Retries: 3, RetryDelay: time.Millisecond})
+
+	require.NoError(t, pg.SetCounter(ctx, "PollCount", 10))
+
+	// запись применена, но ответ потерян вместе с соединением
+	writes := 0
+	db.afterWrite = func() error {
+		writes++
+		return &pgconn.PgError{Code: "08006"}
+	}
+
+	require.ErrorIs(t, pg.SetCounter(ctx, "PollCount", 1), storage.ErrUnavailable)
+	require.ErrorIs(t, pg.SetBatch(ctx, []metrics.Metric{
+		{ID: "PollCount", MType: "counter", Delta: utils.ToPointer(int64(1))},
+	}), storage.ErrUnavailable)
+	require.Equal(t, 2, writes)
+
+	// запись gauge идемпотентна и повторяется
+	require.ErrorIs(t, pg.SetGauge(ctx, "Alloc", 1), storage.ErrUnavailable)
+	require.Equal(t, 6, writes)
+
+	db.afterWrite = nil
+	counter, err := pg.GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	require.Equal(t, int64(12), counter)
+}
@@ -0,0 +1,97 @@
+package dbstorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/auth"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/storage"
+)
+
+// FindToken ищет токен доступа по хешу
+func (pg *PostgresStorage) FindToken(ctx context.Context, hash string) (auth.Token, error) {
+	var token auth.Token
+	var scopes []string
+
+	err := pg.do(ctx, func(ctx context.Context) error {
+		return pg.db.QueryRow(ctx, "SELECT id, hash, scopes, created_at FROM auth_tokens WHERE hash = $1", hash).
+			Scan(&token.ID, &token.Hash, &scopes, &token.CreatedAt)
+	})
+	if errors.Is(err, storage.ErrNotFound) {
+		return auth.Token{}, auth.ErrTokenNotFound
+	}
+	if err != nil {
+		return auth.Token{}, err
+	}
+
+	token.Scopes, err = auth.ParseScopes(scopes)
+	return token, err
+}
+
+// ListTokens возвращает все токены доступа
+func (pg *PostgresStorage) ListTokens(ctx context.Context) ([]auth.Token, error) {
+	var tokens []auth.Token
+
+	err := pg.do(ctx, func(ctx context.Context) error {
+		tokens = nil
+
+		rows, err := pg.db.Query(ctx, "SELECT id, hash, scopes, created_at FROM auth_tokens ORDER BY created_at")
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var token auth.Token
+			var scopes []string
+			if err := rows.Scan(&token.ID, &token.Hash, &scopes, &token.CreatedAt); err != nil {
+				return err
+			}
+			if token.Scopes, err = auth.ParseScopes(scopes); err != nil {
+				return err
+			}
+			tokens = append(tokens, token)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// AddToken сохраняет токен доступа
+func (pg *PostgresStorage) AddToken(ctx context.Context, token auth.Token) error {
+	scopes := make([]string, 0, len(token.Scopes))
+	for _, scope := range token.Scopes {
+		scopes = append(scopes, string(scope))
+	}
+
+	return pg.do(ctx, func(ctx context.Context) error {
+		_, err := pg.db.Exec(ctx, "INSERT INTO auth_tokens (id, hash, scopes, created_at) VALUES ($1, $2, $3, $4)",
+			token.ID, token.Hash, scopes, token.CreatedAt)
+		return err
+	})
+}
+
+// DeleteToken удаляет токен доступа по идентификатору
+func (pg *PostgresStorage) DeleteToken(ctx context.Context, id string) error {
+	var deleted int64
+
+	err := pg.do(ctx, func(ctx context.Context) error {
+		tag, err := pg.db.Exec(ctx, "DELETE FROM auth_tokens WHERE id = $1", id)
+		deleted = tag.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return fmt.Errorf("%w: %s", auth.ErrTokenNotFound, id)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/audit"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/storage"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/trusted"
+	pb "github.com/romanmendelproject/go-yandex-metrics/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditWrites(t *testing.T) {
+	var buf bytes.Buffer
+	auditor := audit.New(audit.NewWriterSink(&buf), 10)
+	memStorage := storage.NewMemStorage("")
+
+	handler := NewHandlers(memStorage)
+	handler.SetAuditor(auditor)
+	protoHandler := NewProtoHandlers(memStorage)
+	protoHandler.SetAuditor(auditor)
+
+	ctx := trusted.WithClient(context.Background(), netip.MustParseAddr("192.168.1.5"))
+	request := httptest.NewRequest(http.MethodPost, "/update/gauge/cpu/1", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	handler.UpdateGauge(w, request)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// Отклоненная запись не попадает в журнал
+	request = httptest.NewRequest(http.MethodPost, "/update/gauge/cpu/none", nil).WithContext(ctx)
+	w = httptest.NewRecorder()
+	handler.UpdateGauge(w, request)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	request = httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewBufferString(`[{"id":"a","type":"gauge","value":1},{"id":"b","type":"counter","delta":1}]`)).WithContext(ctx)
+	w = httptest.NewRecorder()
+	handler.UpdateBatch(w, request)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	_, err := protoHandler.UpdateBatch(ctx, &pb.UpdateBatchRequest{Metric: []*pb.Metric{{ID: "c", MType: "gauge", Value: 1}}})
+	require.NoError(t, err)
+
+	require.NoError(t, auditor.Close())
+
+	var events []audit.Event
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var ev audit.Event
+		require.NoError(t, dec.Decode(&ev))
+		events = append(events, ev)
+	}
+	require.Len(t, events, 3)
+	require.Equal(t, []string{"cpu"}, events[0].Metrics)
+	require.Equal(t, "192.168.1.5", events[0].ClientIP)
+	require.Equal(t, audit.TransportHTTP, events[0].Transport)
+	require.Equal(t, []string{"a", "b"}, events[1].Metrics)
+	require.Equal(t, 2, events[1].Count)
+	require.Equal(t, audit.TransportGRPC, events[2].Transport)
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/cardinality"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/dbstorage/mocks"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/metrics"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/storage"
+	pb "github.com/romanmendelproject/go-yandex-metrics/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateBatchSeriesLimit(t *testing.T) {
+	ctx := context.Background()
+	memStorage := storage.NewMemStorage("")
+	require.NoError(t, memStorage.SetGauge(ctx, "cpu", 1))
+
+	guard := cardinality.New(cardinality.Limits{MaxSeries: 2})
+	require.NoError(t, guard.Load(ctx, memStorage))
+	handler := NewHandlers(memStorage)
+	handler.SetCardinality(guard)
+
+	var jsonStr = []byte(`[{"id":"cpu","type":"gauge","value":2},{"id":"memory","type":"gauge","value":3},{"id":"disk","type":"gauge","value":4}]`)
+	request := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewBuffer(jsonStr))
+	w := httptest.NewRecorder()
+	handler.UpdateBatch(w, request)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var result metrics.BatchResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	require.Equal(t, []string{"disk"}, result.Rejected)
+
+	value, err := memStorage.GetGauge(ctx, "cpu")
+	require.NoError(t, err)
+	require.Equal(t, float64(2), value)
+	_, err = memStorage.GetGauge(ctx, "disk")
+	require.ErrorIs(t, err, storage.ErrNotFound)
+
+	// Одиночная запись нового ряда сверх лимита отклоняется, существующего — проходит
+	request = httptest.NewRequest(http.MethodPost, "/update/gauge/disk/1", nil)
+	w = httptest.NewRecorder()
+	handler.UpdateGauge(w, request)
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	request = httptest.NewRequest(http.MethodPost, "/update/gauge/memory/1", nil)
+	w = httptest.NewRecorder()
+	handler.UpdateGauge(w, request)
+	require.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestProtoUpdateBatchSeriesLimit(t *testing.T) {
+	handler := NewProtoHandlers(storage.NewMemStorage(""))
+	handler.SetCardinality(cardinality.New(cardinality.Limits{Prefixes: map[string]int{"app.": 1}}))
+
+	resp, err := handler.UpdateBatch(context.Background(), &pb.UpdateBatchRequest{
+		Metric: []*pb.Metric{
+			{ID: "app.requests", MType: "counter", Delta: 1},
+			{ID: "app.errors", MType: "counter", Delta: 1},
+			{ID: "cpu", MType: "gauge", Value: 1},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Metric, 1)
+	require.Equal(t, "app.errors", resp.Metric[0].ID)
+}
+
+func TestSeriesReleasedOnStorageError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	db := mocks.NewMockStorage(ctrl)
+	gomock.InOrder(
+		db.EXPECT().SetGauge(gomock.Any(), "cpu", float64(1)).Return(storage.ErrUnavailable),
+		db.EXPECT().SetBatch(gomock.Any(), gomock.Any()).Return(storage.ErrUnavailable),
+		db.EXPECT().SetGauge(gomock.Any(), "memory", float64(1)).Return(nil),
+	)
+
+	handler := NewHandlers(db)
+	handler.SetCardinality(cardinality.New(cardinality.Limits{MaxSeries: 1}))
+
+	request := httptest.NewRequest(http.MethodPost, "/update/gauge/cpu/1", nil)
+	w := httptest.NewRecorder()
+	handler.UpdateGauge(w, request)
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	var jsonStr = []byte(`[{"id":"disk","type":"gauge","value":1}]`)
+	request = httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewBuffer(jsonStr))
+	w = httptest.NewRecorder()
+	handler.UpdateBatch(w, request)
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	// незаписанные ряды не занимают лимит
+	request = httptest.NewRequest(http.MethodPost, "/update/gauge/memory/1", nil)
+	w = httptest.NewRecorder()
+	handler.UpdateGauge(w, request)
+	require.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestProtoSeriesReleasedOnStorageError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	db := mocks.NewMockStorage(ctrl)
+	gomock.InOrder(
+		db.EXPECT().SetBatch(gomock.Any(), gomock.Any()).Return(storage.ErrUnavailable),
+		db.EXPECT().SetBatch(gomock.Any(), gomock.Any()).Return(nil),
+	)
+
+	handler := NewProtoHandlers(db)
+	handler.SetCardinality(cardinality.New(cardinality.Limits{MaxSeries: 1}))
+
+	_, err := handler.UpdateBatch(context.Background(), &pb.UpdateBatchRequest{
+		Metric: []*pb.Metric{{ID: "cpu", MType: "gauge", Value: 1}},
+	})
+	require.Error(t, err)
+
+	resp, err := handler.UpdateBatch(context.Background(), &pb.UpdateBatchRequest{
+		Metric: []*pb.Metric{{ID: "memory", MType: "gauge", Value: 1}},
+	})
+	require.NoError(t, err)
+	require.Empty(t, resp.Metric)
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/auth"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/cardinality"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/policy"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/storage"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/validation"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorStatuses сопоставляет ошибки хранилищ метрик и токенов с кодами HTTP и gRPC
+var errorStatuses = []struct {
+	err      error
+	httpCode int
+	grpcCode codes.Code
+}{
+	{storage.ErrNotFound, http.StatusNotFound, codes.NotFound},
+	{storage.ErrTypeMismatch, http.StatusBadRequest, codes.InvalidArgument},
+	{storage.ErrInvalidValue, http.StatusBadRequest, codes.InvalidArgument},
+	{storage.ErrUnavailable, http.StatusServiceUnavailable, codes.Unavailable},
+	{auth.ErrTokenNotFound, http.StatusNotFound, codes.NotFound},
+	{auth.ErrInvalidScope, http.StatusBadRequest, codes.InvalidArgument},
+	{auth.ErrUnauthenticated, http.StatusUnauthorized, codes.Unauthenticated},
+	{auth.ErrForbidden, http.StatusForbidden, codes.PermissionDenied},
+	{policy.ErrDenied, http.StatusForbidden, codes.PermissionDenied},
+	{validation.ErrInvalidMetric, http.StatusBadRequest, codes.InvalidArgument},
+	{cardinality.ErrSeriesLimit, http.StatusUnprocessableEntity, codes.ResourceExhausted},
+}
+
+// HTTPStatus возвращает HTTP код ответа для ошибки хранилища
+func HTTPStatus(err error) int {
+	for _, s := range errorStatuses {
+		if errors.Is(err, s.err) {
+			return s.httpCode
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+// GRPCError преобразует ошибку хранилища в ошибку gRPC с соответствующим кодом
+func GRPCError(err error) error {
+	if err == nil {
+		return nil
+	}
+	for _, s := range errorStatuses {
+		if errors.Is(err, s.err) {
+			return status.Error(s.grpcCode, err.Error())
+		}
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+func handleStorageError(res http.ResponseWriter, err error) {
+	handleError(res, err, HTTPStatus(err))
+}
+
+func handleProtoError(method string, err error) error {
+	log.Errorf("gRPC %s: %s", method, err)
+	return GRPCError(err)
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/dbstorage/mocks"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/storage"
+	pb "github.com/romanmendelproject/go-yandex-metrics/proto"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestErrorStatuses(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		httpCode int
+		grpcCode codes.Code
+	}{
+		{"not found", fmt.Errorf("%w: Alloc", storage.ErrNotFound), http.StatusNotFound, codes.NotFound},
+		{"type mismatch", storage.ErrTypeMismatch, http.StatusBadRequest, codes.InvalidArgument},
+		{"invalid value", storage.ErrInvalidValue, http.StatusBadRequest, codes.InvalidArgument},
+		{"unavailable", fmt.Errorf("%w: timeout", storage.ErrUnavailable), http.StatusServiceUnavailable, codes.Unavailable},
+		{"unknown", errors.New("error"), http.StatusInternalServerError, codes.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.httpCode, HTTPStatus(tt.err))
+			require.Equal(t, tt.grpcCode, status.Code(GRPCError(tt.err)))
+		})
+	}
+
+	require.NoError(t, GRPCError(nil))
+}
+
+func TestUpdateBatchUnavailable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	db := mocks.NewMockStorage(ctrl)
+	db.EXPECT().SetBatch(gomock.Any(), gomock.Any()).Return(storage.ErrUnavailable)
+
+	handler := NewHandlers(db)
+
+	var jsonStr = []byte(`[{"id":"test","type":"gauge","value":0.5}]`)
+	request := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewBuffer(jsonStr))
+	w := httptest.NewRecorder()
+	handler.UpdateBatch(w, request)
+
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestProtoValueGaugeNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	db := mocks.NewMockStorage(ctrl)
+	ctx := context.Background()
+	db.EXPECT().GetGauge(ctx, "test").Return(float64(0), storage.ErrNotFound)
+
+	handler := NewProtoHandlers(db)
+
+	_, err := handler.ValueGauge(ctx, &pb.ValueGaugeRequest{ID: "test"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+type readyStorage struct {
+	*storage.MemStorage
+	err error
+}
+
+func (s readyStorage) Ready() error {
+	return s.err
+}
+
+func TestReady(t *testing.T) {
+	tests := []struct {
+		name     string
+		storage  Storage
+		wantCode int
+	}{
+		{"storage without readiness", storage.NewMemStorage(""), http.StatusOK},
+		{"ready", readyStorage{storage.NewMemStorage(""), nil}, http.StatusOK},
+		{"unavailable", readyStorage{storage.NewMemStorage(""), storage.ErrUnavailable}, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHandlers(tt.storage)
+
+			w := httptest.NewRecorder()
+			handler.Ready(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+
+			require.Equal(t, tt.wantCode, w.Code)
+		})
+	}
+}
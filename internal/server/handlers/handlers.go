@@ -11,8 +11,12 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/audit"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/cardinality"
 	"github.com/romanmendelproject/go-yandex-metrics/internal/server/metrics"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/policy"
 	"github.com/romanmendelproject/go-yandex-metrics/internal/server/storage"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/validation"
 	"github.com/romanmendelproject/go-yandex-metrics/utils"
 	log "github.com/sirupsen/logrus"
 )
@@ -28,20 +32,86 @@ type Storage interface {
 	Ping(ctx context.Context) error
 }
 
+// Readiness реализуется хранилищами, которые могут сообщить о своей готовности
+// без обращения к нижележащей БД
+type Readiness interface {
+	Ready() error
+}
+
 func handleError(res http.ResponseWriter, err error, status int) {
 	log.Error(err)
 	http.Error(res, err.Error(), status)
 }
 
 type ServiceHandlers struct {
-	storage Storage
+	storage   Storage
+	policy    *policy.Engine
+	series    *cardinality.Guard
+	validator *validation.Validator
+	audit     *audit.Auditor
 }
 
-// NewHandlers создает объект обработчика запросов
+// NewHandlers создает объект обработчика запросов с правилами проверки метрик по умолчанию
 func NewHandlers(storage Storage) *ServiceHandlers {
 	return &ServiceHandlers{
-		storage: storage,
+		storage:   storage,
+		validator: validation.Default(),
+	}
+}
+
+// SetValidator задает правила проверки имен и значений метрик
+func (h *ServiceHandlers) SetValidator(v *validation.Validator) {
+	h.validator = v
+}
+
+// SetAuditor включает запись операций изменения метрик в журнал аудита
+func (h *ServiceHandlers) SetAuditor(a *audit.Auditor) {
+	h.audit = a
+}
+
+// record добавляет в журнал аудита запись метрик ids
+func (h *ServiceHandlers) record(ctx context.Context, ids ...string) {
+	if h.audit != nil {
+		h.audit.Record(audit.NewEvent(ctx, audit.TransportHTTP, ids))
+	}
+}
+
+// validate отвечает 400 с описанием ошибки, если метрика не прошла проверку
+func (h *ServiceHandlers) validate(res http.ResponseWriter, err error) bool {
+	if err != nil {
+		handleStorageError(res, err)
+		return false
 	}
+	return true
+}
+
+// SetPolicy включает проверку прав доступа к метрикам перед обращением к хранилищу
+func (h *ServiceHandlers) SetPolicy(p *policy.Engine) {
+	h.policy = p
+}
+
+// SetCardinality включает ограничение числа новых рядов метрик
+func (h *ServiceHandlers) SetCardinality(g *cardinality.Guard) {
+	h.series = g
+}
+
+// reserve резервирует ряд для новой метрики и отвечает ошибкой при превышении лимита
+func (h *ServiceHandlers) reserve(res http.ResponseWriter, req *http.Request, mType, name string) (*cardinality.Reservation, bool) {
+	reservation, err := h.series.Reserve(req.Context(), mType, name)
+	if err != nil {
+		handleStorageError(res, err)
+		return nil, false
+	}
+	return reservation, true
+}
+
+// authorize проверяет права доступа к метрике и отвечает 403 при запрете
+func (h *ServiceHandlers) authorize(res http.ResponseWriter, req *http.Request, action policy.Action, mType, name string) bool {
+	if err := h.policy.Authorize(req.Context(), action, mType, name); err != nil {
+		handleStorageError(res, err)
+		return false
+	}
+	return true
 }
 
 // HandleBadRequest обрабатывает запросы типа BadRequest
@@ -56,8 +126,6 @@ func HandleStatusNotFound(res http.ResponseWriter, req *http.Request) {
 
 // UpdateGauge обрабатывает запросы на обновление метрик типа Gauge
 func (h *ServiceHandlers) UpdateGauge(res http.ResponseWriter, req *http.Request) {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
 	if req.Method != http.MethodPost {
 		http.Error(res, "incorrect http method", http.StatusBadRequest)
 		return
@@ -75,7 +143,22 @@ func (h *ServiceHandlers) UpdateGauge(res http.ResponseWriter, req *http.Request
 		return
 	}
 
-	h.storage.SetGauge(ctx, urlParams.MetricName, valueFloat)
+	if !h.validate(res, h.validator.Gauge(urlParams.MetricName, valueFloat)) ||
+		!h.authorize(res, req, policy.ActionWrite, "gauge", urlParams.MetricName) {
+		return
+	}
+	reservation, ok := h.reserve(res, req, "gauge", urlParams.MetricName)
+	if !ok {
+		return
+	}
+
+	if err := h.storage.SetGauge(req.Context(), urlParams.MetricName, valueFloat); err != nil {
+		reservation.Release()
+		handleStorageError(res, err)
+		return
+	}
+	reservation.Commit()
+	h.record(req.Context(), urlParams.MetricName)
 	res.WriteHeader(http.StatusOK)
 }
 
@@ -98,7 +181,22 @@ func (h *ServiceHandlers) UpdateCounter(res http.ResponseWriter, req *http.Reque
 		return
 	}
 
-	h.storage.SetCounter(req.Context(), urlParams.MetricName, valueInt)
+	if !h.validate(res, h.validator.Counter(urlParams.MetricName, valueInt)) ||
+		!h.authorize(res, req, policy.ActionWrite, "counter", urlParams.MetricName) {
+		return
+	}
+	reservation, ok := h.reserve(res, req, "counter", urlParams.MetricName)
+	if !ok {
+		return
+	}
+
+	if err := h.storage.SetCounter(req.Context(), urlParams.MetricName, valueInt); err != nil {
+		reservation.Release()
+		handleStorageError(res, err)
+		return
+	}
+	reservation.Commit()
+	h.record(req.Context(), urlParams.MetricName)
 
 	res.WriteHeader(http.StatusOK)
 }
@@ -110,9 +208,12 @@ func (h *ServiceHandlers) ValueGauge(res http.ResponseWriter, req *http.Request)
 		handleError(res, err, http.StatusNotFound)
 		return
 	}
+	if !h.authorize(res, req, policy.ActionRead, "gauge", urlParams.MetricName) {
+		return
+	}
 	value, err := h.storage.GetGauge(req.Context(), urlParams.MetricName)
 	if err != nil {
-		handleError(res, err, http.StatusNotFound)
+		handleStorageError(res, err)
 		return
 	}
 	io.WriteString(res, fmt.Sprintf("%v", strconv.FormatFloat(value, 'f', -1, 64)))
@@ -125,9 +226,12 @@ func (h *ServiceHandlers) ValueCounter(res http.ResponseWriter, req *http.Reques
 		handleError(res, err, http.StatusNotFound)
 		return
 	}
+	if !h.authorize(res, req, policy.ActionRead, "counter", urlParams.MetricName) {
+		return
+	}
 	value, err := h.storage.GetCounter(req.Context(), urlParams.MetricName)
 	if err != nil {
-		handleError(res, err, http.StatusNotFound)
+		handleStorageError(res, err)
 		return
 	}
 	io.WriteString(res, fmt.Sprintf("%d", value))
@@ -163,9 +267,12 @@ func (h *ServiceHandlers) ValueJSON(res http.ResponseWriter, req *http.Request)
 	}
 	switch metric.MType {
 	case "gauge":
+		if !h.authorize(res, req, policy.ActionRead, metric.MType, metric.ID) {
+			return
+		}
 		value, err := h.storage.GetGauge(req.Context(), metric.ID)
 		if err != nil {
-			handleError(res, err, http.StatusNotFound)
+			handleStorageError(res, err)
 			return
 		}
 
@@ -176,10 +283,12 @@ func (h *ServiceHandlers) ValueJSON(res http.ResponseWriter, req *http.Request)
 		}
 
 	case "counter":
+		if !h.authorize(res, req, policy.ActionRead, metric.MType, metric.ID) {
+			return
+		}
 		value, err := h.storage.GetCounter(req.Context(), metric.ID)
 		if err != nil {
-			log.Error(err)
-			res.WriteHeader(http.StatusNotFound)
+			handleStorageError(res, err)
 			return
 		}
 		metricResponse = metrics.Metric{
@@ -208,9 +317,18 @@ func (h *ServiceHandlers) ValueJSON(res http.ResponseWriter, req *http.Request)
 func (h *ServiceHandlers) AllData(res http.ResponseWriter, req *http.Request) {
 	values, err := h.storage.GetAll(req.Context())
 	if err != nil {
-		handleError(res, err, http.StatusInternalServerError)
+		handleStorageError(res, err)
 		return
 	}
+	if h.policy != nil {
+		allowed := values[:0]
+		for _, value := range values {
+			if h.policy.Authorize(req.Context(), policy.ActionRead, value.Type, value.Name) == nil {
+				allowed = append(allowed, value)
+			}
+		}
+		values = allowed
+	}
 	res.Header().Set("Content-Type", "text/html")
 	res.WriteHeader(http.StatusOK)
 	for i, value := range values {
@@ -222,13 +340,25 @@ func (h *ServiceHandlers) AllData(res http.ResponseWriter, req *http.Request) {
 func (h *ServiceHandlers) Ping(res http.ResponseWriter, req *http.Request) {
 	err := h.storage.Ping(req.Context())
 	if err != nil {
-		handleError(res, err, http.StatusInternalServerError)
+		handleStorageError(res, err)
 		return
 	}
 
 	res.WriteHeader(http.StatusOK)
 }
 
+// Ready обрабатывает запросы на проверку готовности сервера
+func (h *ServiceHandlers) Ready(res http.ResponseWriter, req *http.Request) {
+	if r, ok := h.storage.(Readiness); ok {
+		if err := r.Ready(); err != nil {
+			handleStorageError(res, err)
+			return
+		}
+	}
+
+	res.WriteHeader(http.StatusOK)
+}
+
 // ValueJSON обрабатывает запросы на запись метрик полученных в формате JSON
 func (h *ServiceHandlers) UpdateJSON(res http.ResponseWriter, req *http.Request) {
 	var metric metrics.Metric
@@ -259,18 +389,51 @@ func (h *ServiceHandlers) UpdateJSON(res http.ResponseWriter, req *http.Request)
 		return
 	}
 
+	if !h.validate(res, h.validator.Metric(metric)) {
+		return
+	}
+	if (metric.MType == "gauge" || metric.MType == "counter") &&
+		!h.authorize(res, req, policy.ActionWrite, metric.MType, metric.ID) {
+		return
+	}
+
 	switch metric.MType {
 	case "gauge":
+		if metric.Value == nil {
+			http.Error(res, "incorrect value data", http.StatusBadRequest)
+			return
+		}
+		reservation, ok := h.reserve(res, req, metric.MType, metric.ID)
+		if !ok {
+			return
+		}
 		err := h.storage.SetGauge(req.Context(), metric.ID, *metric.Value)
 		if err != nil {
-			log.Error(err)
+			reservation.Release()
+			handleStorageError(res, err)
 			return
 		}
+		reservation.Commit()
+		h.record(req.Context(), metric.ID)
 	case "counter":
-		h.storage.SetCounter(req.Context(), metric.ID, *metric.Delta)
+		if metric.Delta == nil {
+			http.Error(res, "incorrect value data", http.StatusBadRequest)
+			return
+		}
+		reservation, ok := h.reserve(res, req, metric.MType, metric.ID)
+		if !ok {
+			return
+		}
+		if err := h.storage.SetCounter(req.Context(), metric.ID, *metric.Delta); err != nil {
+			reservation.Release()
+			handleStorageError(res, err)
+			return
+		}
+		reservation.Commit()
+		h.record(req.Context(), metric.ID)
 		counter, err := h.storage.GetCounter(req.Context(), metric.ID)
 		if err != nil {
-			log.Error(err)
+			handleStorageError(res, err)
 			return
 		}
 		metric.Delta = &counter
@@ -289,7 +452,8 @@ func (h *ServiceHandlers) UpdateJSON(res http.ResponseWriter, req *http.Request)
 	res.Write(resp)
 }
 
-// UpdateBatch обрабатывает запросы на запись метрик используя один запрос в БД
+// UpdateBatch обрабатывает запросы на запись метрик используя один запрос в БД.
+// Метрики новых рядов сверх лимита не записываются, их имена возвращаются в ответе
 func (h *ServiceHandlers) UpdateBatch(res http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
 	var request []metrics.Metric
@@ -300,12 +464,76 @@ func (h *ServiceHandlers) UpdateBatch(res http.ResponseWriter, req *http.Request
 	}
 	defer req.Body.Close()
 
-	err := h.storage.SetBatch(ctx, request)
-	if err != nil {
-		res.Write([]byte(err.Error()))
-		handleError(res, err, http.StatusBadRequest)
+	if !h.validate(res, h.validator.Batch(request)) {
 		return
 	}
+	for _, metric := range request {
+		if !h.authorize(res, req, policy.ActionWrite, metric.MType, metric.ID) {
+			return
+		}
+	}
 
+	accepted, rejected, reservations := reserveBatch(ctx, h.series, request)
+	if len(accepted) > 0 {
+		if err := h.storage.SetBatch(ctx, accepted); err != nil {
+			reservations.Release()
+			handleStorageError(res, err)
+			return
+		}
+		reservations.Commit()
+		h.record(ctx, metricIDs(accepted)...)
+	}
+
+	if len(rejected) == 0 {
+		res.WriteHeader(http.StatusOK)
+		return
+	}
+
+	result := metrics.BatchResult{}
+	for _, metric := range rejected {
+		result.Rejected = append(result.Rejected, metric.ID)
+	}
+	resp, err := json.Marshal(result)
+	if err != nil {
+		handleError(res, err, http.StatusInternalServerError)
+		return
+	}
+	res.Header().Set("Content-Type", "application/json")
 	res.WriteHeader(http.StatusOK)
+	res.Write(resp)
+}
+
+// metricIDs возвращает имена метрик пачки
+func metricIDs(batch []metrics.Metric) []string {
+	ids := make([]string, 0, len(batch))
+	for _, metric := range batch {
+		ids = append(ids, metric.ID)
+	}
+	return ids
+}
+
+// reserveBatch делит пачку на метрики, которые можно записать, и метрики
+// новых рядов сверх лимита. Метрики неизвестного типа проверит хранилище.
+// Места под новые ряды нужно подтвердить или освободить после записи пачки
+func reserveBatch(ctx context.Context, series *cardinality.Guard, batch []metrics.Metric) ([]metrics.Metric, []metrics.Metric, cardinality.Reservations) {
+	if series == nil {
+		return batch, nil, nil
+	}
+
+	accepted := make([]metrics.Metric, 0, len(batch))
+	var rejected []metrics.Metric
+	var reservations cardinality.Reservations
+	for _, metric := range batch {
+		if metric.MType == "gauge" || metric.MType == "counter" {
+			reservation, err := series.Reserve(ctx, metric.MType, metric.ID)
+			if err != nil {
+				log.Warn(err)
+				rejected = append(rejected, metric)
+				continue
+			}
+			reservations = append(reservations, reservation)
+		}
+		accepted = append(accepted, metric)
+	}
+	return accepted, rejected, reservations
 }
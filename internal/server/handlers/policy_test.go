@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/dbstorage/mocks"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/policy"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/storage"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/tenant"
+	pb "github.com/romanmendelproject/go-yandex-metrics/proto"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newTestPolicy(t *testing.T) *policy.Engine {
+	engine, err := policy.New([]policy.Rule{
+		{Subject: "tenant:payments", Action: "write", Name: "payments.*"},
+		{Subject: "tenant:payments", Action: "read", Name: "*"},
+	}, false)
+	require.NoError(t, err)
+	return engine
+}
+
+func TestUpdateBatchDenied(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Хранилище не должно вызываться, если политика запрещает хотя бы одну метрику
+	db := mocks.NewMockStorage(ctrl)
+
+	handler := NewHandlers(db)
+	handler.SetPolicy(newTestPolicy(t))
+
+	var jsonStr = []byte(`[{"id":"payments.tx","type":"counter","delta":1},{"id":"infra.cpu","type":"gauge","value":0.5}]`)
+	request := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewBuffer(jsonStr))
+	request = request.WithContext(tenant.WithAuthenticated(request.Context(), "payments"))
+	w := httptest.NewRecorder()
+	handler.UpdateBatch(w, request)
+
+	require.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestAllDataFiltered(t *testing.T) {
+	ctx := tenant.WithAuthenticated(context.Background(), "payments")
+	memStorage := storage.NewMemStorage("")
+	require.NoError(t, memStorage.SetGauge(ctx, "payments.tx", 1))
+
+	engine, err := policy.New([]policy.Rule{
+		{Subject: "tenant:payments", Action: "read", Name: "infra.*"},
+	}, false)
+	require.NoError(t, err)
+
+	handler := NewHandlers(memStorage)
+	handler.SetPolicy(engine)
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	handler.AllData(w, request)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NotContains(t, w.Body.String(), "payments.tx")
+}
+
+func TestProtoUpdateBatchDenied(t *testing.T) {
+	handler := NewProtoHandlers(storage.NewMemStorage(""))
+	handler.SetPolicy(newTestPolicy(t))
+
+	ctx := tenant.WithAuthenticated(context.Background(), "payments")
+	_, err := handler.UpdateBatch(ctx, &pb.UpdateBatchRequest{
+		Metric: []*pb.Metric{{ID: "infra.cpu", MType: "gauge", Value: 1}},
+	})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// некорректная пачка отклоняется до проверки прав, как и по HTTP
+	_, err = handler.UpdateBatch(ctx, &pb.UpdateBatchRequest{
+		Metric: []*pb.Metric{{ID: "infra cpu!", MType: "gauge", Value: 1}},
+	})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = handler.UpdateBatch(ctx, &pb.UpdateBatchRequest{
+		Metric: []*pb.Metric{{ID: "payments.tx", MType: "gauge", Value: 1}},
+	})
+	require.NoError(t, err)
+
+	value, err := handler.ValueGauge(ctx, &pb.ValueGaugeRequest{ID: "payments.tx"})
+	require.NoError(t, err)
+	require.Equal(t, float64(1), value.Value)
+}
@@ -3,7 +3,11 @@ package handlers
 import (
 	"context"
 
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/audit"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/cardinality"
 	"github.com/romanmendelproject/go-yandex-metrics/internal/server/metrics"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/policy"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/validation"
 	pb "github.com/romanmendelproject/go-yandex-metrics/proto"
 	"github.com/romanmendelproject/go-yandex-metrics/utils"
 	log "github.com/sirupsen/logrus"
@@ -11,21 +15,49 @@ import (
 
 // ProtoServiceHandlers data for gRPC server
 type ProtoServiceHandlers struct {
-	storage Storage
+	storage   Storage
+	policy    *policy.Engine
+	series    *cardinality.Guard
+	validator *validation.Validator
+	audit     *audit.Auditor
 }
 
-// NewProtoHandlers создает объект обработчика запросов
+// NewProtoHandlers создает объект обработчика запросов с правилами проверки метрик по умолчанию
 func NewProtoHandlers(storage Storage) *ProtoServiceHandlers {
 	return &ProtoServiceHandlers{
-		storage: storage,
+		storage:   storage,
+		validator: validation.Default(),
 	}
 }
 
+// SetValidator задает правила проверки имен и значений метрик
+func (h *ProtoServiceHandlers) SetValidator(v *validation.Validator) {
+	h.validator = v
+}
+
+// SetPolicy включает проверку прав доступа к метрикам перед обращением к хранилищу
+func (h *ProtoServiceHandlers) SetPolicy(p *policy.Engine) {
+	h.policy = p
+}
+
+// SetCardinality включает ограничение числа новых рядов метрик
+func (h *ProtoServiceHandlers) SetCardinality(g *cardinality.Guard) {
+	h.series = g
+}
+
+// SetAuditor включает запись операций изменения метрик в журнал аудита
+func (h *ProtoServiceHandlers) SetAuditor(a *audit.Auditor) {
+	h.audit = a
+}
+
 // ValueGauge имплементирует ValueGauge
 func (h *ProtoServiceHandlers) ValueGauge(ctx context.Context, in *pb.ValueGaugeRequest) (*pb.ValueGaugeResponse, error) {
-	value, err := h.storage.GetGauge(context.Background(), in.ID)
+	if err := h.policy.Authorize(ctx, policy.ActionRead, "gauge", in.ID); err != nil {
+		return nil, handleProtoError("ValueGauge", err)
+	}
+	value, err := h.storage.GetGauge(ctx, in.ID)
 	if err != nil {
-		return nil, err
+		return nil, handleProtoError("ValueGauge", err)
 	}
 
 	var response pb.ValueGaugeResponse
@@ -41,9 +73,12 @@ func (h *ProtoServiceHandlers) ValueGauge(ctx context.Context, in *pb.ValueGauge
 
 // ValueCounter имплементирует ValueCounter
 func (h *ProtoServiceHandlers) ValueCounter(ctx context.Context, in *pb.ValueCounterRequest) (*pb.ValueCounterResponse, error) {
-	value, err := h.storage.GetCounter(context.Background(), in.ID)
+	if err := h.policy.Authorize(ctx, policy.ActionRead, "counter", in.ID); err != nil {
+		return nil, handleProtoError("ValueCounter", err)
+	}
+	value, err := h.storage.GetCounter(ctx, in.ID)
 	if err != nil {
-		return nil, err
+		return nil, handleProtoError("ValueCounter", err)
 	}
 
 	var response pb.ValueCounterResponse
@@ -57,7 +92,8 @@ func (h *ProtoServiceHandlers) ValueCounter(ctx context.Context, in *pb.ValueCou
 	return &response, nil
 }
 
-// ProtoHandler  UpdateBatch implements UpdateBatch
+// ProtoHandler  UpdateBatch implements UpdateBatch.
+// В ответе возвращаются метрики новых рядов, не записанные из-за лимита
 func (h *ProtoServiceHandlers) UpdateBatch(ctx context.Context, in *pb.UpdateBatchRequest) (*pb.UpdateBatchResponse, error) {
 	var response pb.UpdateBatchResponse
 	ms := []metrics.Metric{}
@@ -71,9 +107,34 @@ func (h *ProtoServiceHandlers) UpdateBatch(ctx context.Context, in *pb.UpdateBat
 		})
 	}
 
-	err := h.storage.SetBatch(ctx, ms)
-	if err != nil {
-		log.Error("gRPC UpdateMetric, s.DBPostgres.SetBatchMetrics:", "about ERR"+err.Error())
+	if err := h.validator.Batch(ms); err != nil {
+		return nil, handleProtoError("UpdateBatch", err)
+	}
+	for _, metric := range ms {
+		if err := h.policy.Authorize(ctx, policy.ActionWrite, metric.MType, metric.ID); err != nil {
+			return nil, handleProtoError("UpdateBatch", err)
+		}
+	}
+
+	accepted, rejected, reservations := reserveBatch(ctx, h.series, ms)
+	if len(accepted) > 0 {
+		if err := h.storage.SetBatch(ctx, accepted); err != nil {
+			reservations.Release()
+			return nil, handleProtoError("UpdateBatch", err)
+		}
+		reservations.Commit()
+		if h.audit != nil {
+			h.audit.Record(audit.NewEvent(ctx, audit.TransportGRPC, metricIDs(accepted)))
+		}
+	}
+
+	for _, metric := range rejected {
+		response.Metric = append(response.Metric, &pb.Metric{
+			ID:    metric.ID,
+			MType: metric.MType,
+			Delta: utils.UnPointer(metric.Delta),
+			Value: utils.UnPointer(metric.Value),
+		})
 	}
 
 	return &response, nil
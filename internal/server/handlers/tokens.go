@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/auth"
+)
+
+// TokenHandlers обрабатывает запросы на управление токенами доступа
+type TokenHandlers struct {
+	store auth.Store
+}
+
+// NewTokenHandlers создает объект обработчика запросов к токенам
+func NewTokenHandlers(store auth.Store) *TokenHandlers {
+	return &TokenHandlers{
+		store: store,
+	}
+}
+
+type createTokenRequest struct {
+	Scopes []string `json:"scopes"`
+}
+
+type createTokenResponse struct {
+	auth.Token
+	Secret string `json:"token"`
+}
+
+// ListTokens возвращает описание выданных токенов без их хешей
+func (h *TokenHandlers) ListTokens(res http.ResponseWriter, req *http.Request) {
+	tokens, err := h.store.ListTokens(req.Context())
+	if err != nil {
+		handleStorageError(res, err)
+		return
+	}
+
+	for i := range tokens {
+		tokens[i].Hash = ""
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(res).Encode(tokens); err != nil {
+		handleError(res, err, http.StatusInternalServerError)
+	}
+}
+
+// CreateToken выпускает новый токен. Сам токен возвращается только в этом ответе
+func (h *TokenHandlers) CreateToken(res http.ResponseWriter, req *http.Request) {
+	var body createTokenRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		handleError(res, err, http.StatusBadRequest)
+		return
+	}
+
+	scopes, err := auth.ParseScopes(body.Scopes)
+	if err != nil {
+		handleStorageError(res, err)
+		return
+	}
+
+	raw, token, err := auth.NewToken(scopes)
+	if err != nil {
+		handleStorageError(res, err)
+		return
+	}
+
+	if err := h.store.AddToken(req.Context(), token); err != nil {
+		handleStorageError(res, err)
+		return
+	}
+
+	token.Hash = ""
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(res).Encode(createTokenResponse{Token: token, Secret: raw}); err != nil {
+		handleError(res, err, http.StatusInternalServerError)
+	}
+}
+
+// DeleteToken отзывает токен по идентификатору
+func (h *TokenHandlers) DeleteToken(res http.ResponseWriter, req *http.Request) {
+	if err := h.store.DeleteToken(req.Context(), chi.URLParam(req, "id")); err != nil {
+		handleStorageError(res, err)
+		return
+	}
+	res.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenHandlers(t *testing.T) {
+	store, err := auth.NewFileStore(filepath.Join(t.TempDir(), "tokens.json"))
+	require.NoError(t, err)
+
+	h := NewTokenHandlers(store)
+	r := chi.NewRouter()
+	r.Get("/admin/tokens/", h.ListTokens)
+	r.Post("/admin/tokens/", h.CreateToken)
+	r.Delete("/admin/tokens/{id}", h.DeleteToken)
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/tokens/", strings.NewReader(`{"scopes":["root"]}`)))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/tokens/", strings.NewReader(`{"scopes":["write"]}`)))
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	var created createTokenResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&created))
+	require.NotEmpty(t, created.Secret)
+	require.Empty(t, created.Hash)
+
+	_, err = auth.NewAuthenticator(store).Authenticate(context.Background(), created.Secret, auth.ScopeWrite)
+	require.NoError(t, err)
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/tokens/", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "hash")
+	assert.Contains(t, rr.Body.String(), created.ID)
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/admin/tokens/"+created.ID, nil))
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/admin/tokens/"+created.ID, nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/dbstorage/mocks"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/storage"
+	pb "github.com/romanmendelproject/go-yandex-metrics/proto"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUpdateValidation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Некорректные метрики не доходят до хранилища
+	handler := NewHandlers(mocks.NewMockStorage(ctrl))
+
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		handler http.HandlerFunc
+		want    string
+	}{
+		{"gauge NaN", "/update/gauge/cpu/NaN", "", handler.UpdateGauge, "not a finite number"},
+		{"gauge Inf", "/update/gauge/cpu/+Inf", "", handler.UpdateGauge, "not a finite number"},
+		{"counter bad name", "/update/counter/a%20b/1", "", handler.UpdateCounter, "name does not match"},
+		{"json bad name", "/update/", `{"id":"a b","type":"gauge","value":1}`, handler.UpdateJSON, "name does not match"},
+		{"batch", "/updates/", `[{"id":"ok","type":"gauge","value":1},{"id":"a/b","type":"counter","delta":1}]`, handler.UpdateBatch, `"a/b"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, tt.path, bytes.NewBufferString(tt.body))
+			request.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			tt.handler(w, request)
+
+			require.Equal(t, http.StatusBadRequest, w.Code)
+			require.Contains(t, w.Body.String(), tt.want)
+		})
+	}
+}
+
+func TestProtoUpdateBatchValidation(t *testing.T) {
+	handler := NewProtoHandlers(storage.NewMemStorage(""))
+	_, err := handler.UpdateBatch(context.Background(), &pb.UpdateBatchRequest{
+		Metric: []*pb.Metric{{ID: "cpu load", MType: "gauge", Value: 1}},
+	})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	require.Contains(t, status.Convert(err).Message(), `"cpu load"`)
+}
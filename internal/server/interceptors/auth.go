@@ -0,0 +1,55 @@
+package interceptors
+
+import (
+	"context"
+	"errors"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/auth"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/storage"
+	pb "github.com/romanmendelproject/go-yandex-metrics/proto"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// methodScopes определяет право, необходимое для вызова метода.
+// Для методов, которых нет в списке, требуется ScopeAdmin
+var methodScopes = map[string]auth.Scope{
+	pb.Metrics_ValueGauge_FullMethodName:   auth.ScopeRead,
+	pb.Metrics_ValueCounter_FullMethodName: auth.ScopeRead,
+	pb.Metrics_UpdateBatch_FullMethodName:  auth.ScopeWrite,
+}
+
+// AuthInterceptor проверяет токен доступа из метаданных запроса
+func AuthInterceptor(authenticator *auth.Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		scope, ok := methodScopes[info.FullMethod]
+		if !ok {
+			scope = auth.ScopeAdmin
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		raw := auth.BearerToken(firstValue(md, auth.MetadataAuthorization))
+		token, err := authenticator.Authenticate(ctx, raw, scope)
+		if err != nil {
+			log.Errorf("gRPC %s: %s", info.FullMethod, err)
+			return nil, status.Error(authCode(err), err.Error())
+		}
+		return handler(auth.WithToken(ctx, token), req)
+	}
+}
+
+func authCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, auth.ErrUnauthenticated):
+		return codes.Unauthenticated
+	case errors.Is(err, auth.ErrForbidden):
+		return codes.PermissionDenied
+	case errors.Is(err, storage.ErrUnavailable):
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
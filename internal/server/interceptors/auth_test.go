@@ -0,0 +1,48 @@
+package interceptors
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/auth"
+	pb "github.com/romanmendelproject/go-yandex-metrics/proto"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthInterceptor(t *testing.T) {
+	store, err := auth.NewFileStore(filepath.Join(t.TempDir(), "tokens.json"))
+	require.NoError(t, err)
+	raw, token, err := auth.NewToken([]auth.Scope{auth.ScopeWrite})
+	require.NoError(t, err)
+	require.NoError(t, store.AddToken(context.Background(), token))
+
+	interceptor := AuthInterceptor(auth.NewAuthenticator(store))
+	handler := func(ctx context.Context, req any) (any, error) {
+		return nil, nil
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		md       metadata.MD
+		wantCode codes.Code
+	}{
+		{"write allowed", pb.Metrics_UpdateBatch_FullMethodName, metadata.Pairs(auth.MetadataAuthorization, "Bearer "+raw), codes.OK},
+		{"read denied", pb.Metrics_ValueGauge_FullMethodName, metadata.Pairs(auth.MetadataAuthorization, "Bearer "+raw), codes.PermissionDenied},
+		{"unknown method requires admin", "/demo.Metrics/Other", metadata.Pairs(auth.MetadataAuthorization, "Bearer "+raw), codes.PermissionDenied},
+		{"missing token", pb.Metrics_UpdateBatch_FullMethodName, metadata.MD{}, codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), tt.md)
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+			require.Equal(t, tt.wantCode, status.Code(err))
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package interceptors
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/trusted"
+	pb "github.com/romanmendelproject/go-yandex-metrics/proto"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Ключи метаданных с адресом клиента, выставляемые прокси
+const (
+	metadataForwardedFor = "x-forwarded-for"
+	metadataRealIP       = "x-real-ip"
+)
+
+var errUnknownPeer = errors.New("unknown peer")
+
+// writeMethods методы, изменяющие метрики
+var writeMethods = map[string]bool{
+	pb.Metrics_UpdateBatch_FullMethodName: true,
+}
+
+// TrustedSubnetInterceptor пропускает вызовы изменяющих методов только от клиентов
+// из доверенных сетей и сохраняет адрес клиента в контексте
+func TrustedSubnetInterceptor(access *trusted.Access) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !writeMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		client, allowed, err := peerClient(ctx, access)
+		switch {
+		case err != nil && access.Enabled():
+			log.Errorf("gRPC %s: %s", info.FullMethod, err)
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		case err != nil:
+			log.Warnf("gRPC %s: %s", info.FullMethod, err)
+		case !allowed:
+			log.Errorf("gRPC %s: client %s is not in trusted subnet", info.FullMethod, client)
+			return nil, status.Error(codes.PermissionDenied, "client is not in trusted subnet")
+		default:
+			ctx = trusted.WithClient(ctx, client)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// peerClient определяет адрес клиента по адресу соединения и метаданным прокси
+func peerClient(ctx context.Context, access *trusted.Access) (netip.Addr, bool, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return netip.Addr{}, false, errUnknownPeer
+	}
+	remote, err := trusted.ParseAddr(p.Addr.String())
+	if err != nil {
+		return netip.Addr{}, false, err
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	return access.Allow(remote, md.Get(metadataForwardedFor), firstValue(md, metadataRealIP))
+}
@@ -0,0 +1,49 @@
+package interceptors
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/trusted"
+	pb "github.com/romanmendelproject/go-yandex-metrics/proto"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestTrustedSubnetInterceptor(t *testing.T) {
+	access, err := trusted.NewAccess("192.168.1.0/24,2001:db8::/32", "10.0.0.1")
+	require.NoError(t, err)
+	interceptor := TrustedSubnetInterceptor(access)
+	handler := func(ctx context.Context, req any) (any, error) {
+		return nil, nil
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		addr     net.Addr
+		md       metadata.MD
+		wantCode codes.Code
+	}{
+		{"trusted client", pb.Metrics_UpdateBatch_FullMethodName, &net.TCPAddr{IP: net.ParseIP("192.168.1.5"), Port: 5000}, nil, codes.OK},
+		{"trusted ipv6 client", pb.Metrics_UpdateBatch_FullMethodName, &net.TCPAddr{IP: net.ParseIP("2001:db8::5"), Port: 5000}, nil, codes.OK},
+		{"untrusted client", pb.Metrics_UpdateBatch_FullMethodName, &net.TCPAddr{IP: net.ParseIP("203.0.113.5"), Port: 5000}, nil, codes.PermissionDenied},
+		{"spoofed header", pb.Metrics_UpdateBatch_FullMethodName, &net.TCPAddr{IP: net.ParseIP("203.0.113.5"), Port: 5000}, metadata.Pairs("x-real-ip", "192.168.1.5"), codes.PermissionDenied},
+		{"via trusted proxy", pb.Metrics_UpdateBatch_FullMethodName, &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5000}, metadata.Pairs("x-forwarded-for", "192.168.1.5"), codes.OK},
+		{"read method is not restricted", pb.Metrics_ValueGauge_FullMethodName, &net.TCPAddr{IP: net.ParseIP("203.0.113.5"), Port: 5000}, nil, codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: tt.addr})
+			ctx = metadata.NewIncomingContext(ctx, tt.md)
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+			require.Equal(t, tt.wantCode, status.Code(err))
+		})
+	}
+}
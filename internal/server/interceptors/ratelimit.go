@@ -0,0 +1,56 @@
+package interceptors
+
+import (
+	"context"
+	"net/netip"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/ratelimit"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/tenant"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/trusted"
+	pb "github.com/romanmendelproject/go-yandex-metrics/proto"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// metadataRetryAfter ключ метаданных ответа со временем ожидания в секундах
+const metadataRetryAfter = "retry-after"
+
+// RateLimitInterceptor ограничивает частоту вызовов изменяющих методов и число
+// переданных метрик. При превышении лимита возвращает ResourceExhausted
+func RateLimitInterceptor(limiter *ratelimit.Limiter, resolver *trusted.Resolver) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if limiter == nil || !writeMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		var client netip.Addr
+		md, _ := metadata.FromIncomingContext(ctx)
+		if p, ok := peer.FromContext(ctx); ok {
+			remote, err := trusted.ParseAddr(p.Addr.String())
+			if err != nil {
+				return nil, status.Error(codes.InvalidArgument, err.Error())
+			}
+			client, err = resolver.Resolve(remote, md.Get(metadataForwardedFor), firstValue(md, metadataRealIP))
+			if err != nil {
+				return nil, status.Error(codes.InvalidArgument, err.Error())
+			}
+		}
+
+		n := 1
+		if batch, ok := req.(*pb.UpdateBatchRequest); ok {
+			n = len(batch.Metric)
+		}
+
+		key := limiter.Key(ctx, client, firstValue(md, tenant.MetadataAPIKey))
+		if wait, err := limiter.Allow(key, n); err != nil {
+			log.Warnf("gRPC %s: client %s: %s", info.FullMethod, key, err)
+			grpc.SetHeader(ctx, metadata.Pairs(metadataRetryAfter, ratelimit.RetryAfter(wait)))
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
+		}
+		return handler(ctx, req)
+	}
+}
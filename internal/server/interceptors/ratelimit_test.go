@@ -0,0 +1,50 @@
+package interceptors
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/ratelimit"
+	pb "github.com/romanmendelproject/go-yandex-metrics/proto"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestRateLimitInterceptor(t *testing.T) {
+	limiter, err := ratelimit.New(ratelimit.KeyByAPIKey, ratelimit.Limits{Requests: 10, Metrics: 2})
+	require.NoError(t, err)
+	interceptor := RateLimitInterceptor(limiter, nil)
+	handler := func(ctx context.Context, req any) (any, error) {
+		return nil, nil
+	}
+	batch := func(n int) *pb.UpdateBatchRequest {
+		return &pb.UpdateBatchRequest{Metric: make([]*pb.Metric, n)}
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		apiKey   string
+		req      any
+		wantCode codes.Code
+	}{
+		{"within limit", pb.Metrics_UpdateBatch_FullMethodName, "first", batch(2), codes.OK},
+		{"metrics limit", pb.Metrics_UpdateBatch_FullMethodName, "first", batch(1), codes.ResourceExhausted},
+		{"other api key", pb.Metrics_UpdateBatch_FullMethodName, "second", batch(1), codes.OK},
+		{"read method is not limited", pb.Metrics_ValueGauge_FullMethodName, "first", &pb.ValueGaugeRequest{}, codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("192.168.1.5"), Port: 5000}})
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("x-api-key", tt.apiKey))
+			_, err := interceptor(ctx, tt.req, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+			require.Equal(t, tt.wantCode, status.Code(err))
+		})
+	}
+}
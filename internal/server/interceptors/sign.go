@@ -0,0 +1,49 @@
+package interceptors
+
+import (
+	"context"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/crypto"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// SignInterceptor подписывает ответ вместе с временем и nonce запроса и
+// передает подпись в trailer. Используется ключ запроса, а если он неизвестен — самый новый
+func SignInterceptor(keys *crypto.KeyRing) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return resp, err
+		}
+
+		msg, ok := resp.(proto.Message)
+		if !ok {
+			return resp, nil
+		}
+
+		data, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+		if err != nil {
+			log.Errorf("gRPC %s: %s", info.FullMethod, err)
+			return resp, nil
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		key, ok := keys.SigningKey(firstValue(md, crypto.MetadataKeyID))
+		if !ok {
+			return resp, nil
+		}
+
+		hash := crypto.GetSignedHash(data, key.Key, firstValue(md, crypto.MetadataTimestamp), firstValue(md, crypto.MetadataNonce))
+		trailer := metadata.Pairs(crypto.MetadataHash, hash)
+		if key.ID != "" {
+			trailer.Set(crypto.MetadataKeyID, key.ID)
+		}
+		if err := grpc.SetTrailer(ctx, trailer); err != nil {
+			log.Errorf("gRPC %s: %s", info.FullMethod, err)
+		}
+		return resp, nil
+	}
+}
@@ -0,0 +1,53 @@
+package interceptors
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/crypto"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/handlers"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/storage"
+	pb "github.com/romanmendelproject/go-yandex-metrics/proto"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestSignInterceptor(t *testing.T) {
+	key := "secret"
+	keys, err := crypto.NewKeyRing(key, "")
+	require.NoError(t, err)
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(SignInterceptor(keys)))
+	pb.RegisterMetricsServer(srv, handlers.NewProtoHandlers(storage.NewMemStorage("")))
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(),
+		crypto.MetadataTimestamp, "1700000000", crypto.MetadataNonce, "nonce")
+
+	var trailer metadata.MD
+	client := pb.NewMetricsClient(conn)
+	_, err = client.UpdateBatch(ctx, &pb.UpdateBatchRequest{
+		Metric: []*pb.Metric{{ID: "Alloc", MType: "gauge", Value: 1.5}},
+	})
+	require.NoError(t, err)
+
+	resp, err := client.ValueGauge(ctx, &pb.ValueGaugeRequest{ID: "Alloc"}, grpc.Trailer(&trailer))
+	require.NoError(t, err)
+
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(resp)
+	require.NoError(t, err)
+	require.Equal(t, []string{crypto.GetSignedHash(data, key, "1700000000", "nonce")}, trailer.Get(crypto.MetadataHash))
+}
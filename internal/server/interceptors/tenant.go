@@ -0,0 +1,49 @@
+// Модуль перехватчиков запросов gRPC сервера
+package interceptors
+
+import (
+	"context"
+	"errors"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/tenant"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// TenantInterceptor определяет тенант по метаданным запроса и сохраняет его в контексте
+func TenantInterceptor(resolver *tenant.Resolver) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		apiKey := firstValue(md, tenant.MetadataAPIKey)
+		name, err := resolver.Resolve(apiKey, firstValue(md, tenant.MetadataTenant))
+		if err != nil {
+			log.Errorf("gRPC %s: %s", info.FullMethod, err)
+			return nil, status.Error(tenantCode(err), err.Error())
+		}
+		if apiKey != "" {
+			return handler(tenant.WithAuthenticated(ctx, name), req)
+		}
+		return handler(tenant.WithTenant(ctx, name), req)
+	}
+}
+
+func tenantCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, tenant.ErrForbidden):
+		return codes.PermissionDenied
+	case errors.Is(err, tenant.ErrInvalidName):
+		return codes.InvalidArgument
+	default:
+		return codes.Unauthenticated
+	}
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
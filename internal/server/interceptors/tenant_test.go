@@ -0,0 +1,47 @@
+package interceptors
+
+import (
+	"context"
+	"testing"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/tenant"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestTenantInterceptor(t *testing.T) {
+	resolver := tenant.NewResolver(map[string]string{"secret": "payments"})
+	info := &grpc.UnaryServerInfo{FullMethod: "/demo.Metrics/UpdateBatch"}
+
+	tests := []struct {
+		name       string
+		resolver   *tenant.Resolver
+		md         metadata.MD
+		wantCode   codes.Code
+		wantTenant string
+	}{
+		{"tenant by key", resolver, metadata.Pairs(tenant.MetadataAPIKey, "secret"), codes.OK, "payments"},
+		{"foreign tenant", resolver, metadata.Pairs(tenant.MetadataAPIKey, "secret", tenant.MetadataTenant, "infra"), codes.PermissionDenied, ""},
+		{"missing key", resolver, metadata.MD{}, codes.Unauthenticated, ""},
+		{"foreign tenant without keys", tenant.NewResolver(nil), metadata.Pairs(tenant.MetadataTenant, "payments"), codes.Unauthenticated, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotTenant string
+			handler := func(ctx context.Context, req any) (any, error) {
+				gotTenant = tenant.FromContext(ctx)
+				return nil, nil
+			}
+
+			ctx := metadata.NewIncomingContext(context.Background(), tt.md)
+			_, err := TenantInterceptor(tt.resolver)(ctx, nil, info, handler)
+
+			require.Equal(t, tt.wantCode, status.Code(err))
+			require.Equal(t, tt.wantTenant, gotTenant)
+		})
+	}
+}
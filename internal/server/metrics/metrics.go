@@ -8,3 +8,8 @@ type Metric struct {
 	Delta *int64   `json:"delta,omitempty"` // значение метрики в случае передачи counter
 	Value *float64 `json:"value,omitempty"` // значение метрики в случае передачи gauge
 }
+
+// BatchResult описывает результат записи пачки метрик
+type BatchResult struct {
+	Rejected []string `json:"rejected,omitempty"` // имена метрик, не записанных из-за лимита рядов
+}
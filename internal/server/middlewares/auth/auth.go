@@ -0,0 +1,44 @@
+// Модуль аутентификации HTTP запросов по токену доступа
+package auth
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/auth"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// AuthMiddleware пропускает только запросы с токеном, которому выдано право scope
+func AuthMiddleware(authenticator *auth.Authenticator, scope auth.Scope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		logFn := func(res http.ResponseWriter, req *http.Request) {
+			raw := auth.BearerToken(req.Header.Get(auth.HeaderAuthorization))
+			token, err := authenticator.Authenticate(req.Context(), raw, scope)
+			if err != nil {
+				log.Error(err)
+				if errors.Is(err, auth.ErrUnauthenticated) {
+					res.Header().Set("WWW-Authenticate", "Bearer")
+				}
+				http.Error(res, err.Error(), statusCode(err))
+				return
+			}
+			next.ServeHTTP(res, req.WithContext(auth.WithToken(req.Context(), token)))
+		}
+		return http.HandlerFunc(logFn)
+	}
+}
+
+func statusCode(err error) int {
+	switch {
+	case errors.Is(err, auth.ErrUnauthenticated):
+		return http.StatusUnauthorized
+	case errors.Is(err, auth.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, storage.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
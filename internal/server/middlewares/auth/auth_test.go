@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	store, err := auth.NewFileStore(filepath.Join(t.TempDir(), "tokens.json"))
+	require.NoError(t, err)
+	raw, token, err := auth.NewToken([]auth.Scope{auth.ScopeRead})
+	require.NoError(t, err)
+	require.NoError(t, store.AddToken(context.Background(), token))
+
+	tests := []struct {
+		name           string
+		header         string
+		scope          auth.Scope
+		expectedStatus int
+	}{
+		{"valid token", "Bearer " + raw, auth.ScopeRead, http.StatusOK},
+		{"insufficient scope", "Bearer " + raw, auth.ScopeWrite, http.StatusForbidden},
+		{"unknown token", "Bearer unknown", auth.ScopeRead, http.StatusUnauthorized},
+		{"missing header", "", auth.ScopeRead, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := AuthMiddleware(auth.NewAuthenticator(store), tt.scope)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, ok := auth.FromContext(r.Context())
+				assert.True(t, ok)
+				assert.Equal(t, token.ID, got.ID)
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(auth.HeaderAuthorization, tt.header)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+		})
+	}
+}
@@ -8,11 +8,12 @@ import (
 	"github.com/romanmendelproject/go-yandex-metrics/internal/crypto"
 )
 
-// CryptoMiddleware декодирует запросы к серверу
-func CryptoMiddleware(key string) func(http.Handler) http.Handler {
+// CryptoMiddleware декодирует запросы к серверу ключом из заголовка
+// X-Crypto-Key-ID или перебором активных закрытых ключей
+func CryptoMiddleware(keys *crypto.KeyRing) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		logFn := func(res http.ResponseWriter, req *http.Request) {
-			if key != "" {
+			if keys.HasRSA() {
 				// decrypt request body
 				data, err := io.ReadAll(req.Body)
 				if err != nil {
@@ -22,7 +23,7 @@ func CryptoMiddleware(key string) func(http.Handler) http.Handler {
 
 				// decrypt only non-empty data
 				if len(data) > 0 {
-					decryptBody, err := crypto.Decrypt(key, string(data))
+					decryptBody, err := keys.Decrypt(req.Header.Get(crypto.HeaderCryptoKeyID), string(data))
 					if err != nil {
 						http.Error(res, err.Error(), http.StatusBadRequest)
 						return
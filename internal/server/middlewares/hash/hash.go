@@ -3,16 +3,20 @@ package hash
 
 import (
 	"bytes"
+	"crypto/hmac"
 	"io"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/romanmendelproject/go-yandex-metrics/internal/crypto"
 
 	log "github.com/sirupsen/logrus"
 )
 
-// HashMiddleware декодирует запросы к серверу
-func HashMiddleware(key string) func(http.Handler) http.Handler {
+// HashMiddleware проверяет подпись запроса, его время и одноразовое значение.
+// Подпись проверяется ключом из заголовка X-Key-ID или перебором активных ключей
+func HashMiddleware(keys *crypto.KeyRing, guard *ReplayGuard) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		logFn := func(res http.ResponseWriter, req *http.Request) {
 			metrics, err := io.ReadAll(req.Body)
@@ -20,7 +24,7 @@ func HashMiddleware(key string) func(http.Handler) http.Handler {
 				log.Error(err)
 			}
 
-			hash := req.Header.Get("HashSHA256")
+			hash := req.Header.Get(crypto.HeaderHash)
 			if hash == "" {
 				log.Error("Missing hash header")
 				res.WriteHeader(http.StatusBadRequest)
@@ -28,12 +32,28 @@ func HashMiddleware(key string) func(http.Handler) http.Handler {
 				return
 			}
 
-			expectedHash := crypto.GetHash(metrics, key)
-
-			if hash != expectedHash {
+			timestamp := req.Header.Get(crypto.HeaderTimestamp)
+			nonce := req.Header.Get(crypto.HeaderNonce)
+			if !verifyHash(keys, req.Header.Get(crypto.HeaderKeyID), hash, metrics, timestamp, nonce) {
 				log.Error("Hash is not valid")
 
 				res.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			unix, err := strconv.ParseInt(timestamp, 10, 64)
+			if err != nil {
+				log.Error("Invalid timestamp header")
+
+				res.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			if err := guard.Check(time.Unix(unix, 0), nonce); err != nil {
+				log.Error(err)
+
+				res.WriteHeader(http.StatusBadRequest)
+				return
 			}
 
 			req.Body = io.NopCloser(bytes.NewBuffer(metrics))
@@ -43,3 +63,13 @@ func HashMiddleware(key string) func(http.Handler) http.Handler {
 		return http.HandlerFunc(logFn)
 	}
 }
+
+func verifyHash(keys *crypto.KeyRing, id, hash string, body []byte, timestamp, nonce string) bool {
+	for _, key := range keys.HMACKeys(id) {
+		expectedHash := crypto.GetSignedHash(body, key.Key, timestamp, nonce)
+		if hmac.Equal([]byte(hash), []byte(expectedHash)) {
+			return true
+		}
+	}
+	return false
+}
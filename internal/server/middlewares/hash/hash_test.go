@@ -2,30 +2,46 @@ package hash
 
 import (
 	"bytes"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/romanmendelproject/go-yandex-metrics/internal/crypto"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+func signedRequest(body []byte, key string, timestamp time.Time, nonce string) *http.Request {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	req := httptest.NewRequest("POST", "/test", bytes.NewBuffer(body))
+	req.Header.Set(crypto.HeaderTimestamp, ts)
+	req.Header.Set(crypto.HeaderNonce, nonce)
+	req.Header.Set(crypto.HeaderHash, crypto.GetSignedHash(body, key, ts, nonce))
+	return req
+}
+
 func TestHashMiddleware(t *testing.T) {
 	key := "secret"
+	keys, err := crypto.NewKeyRing(key, "")
+	require.NoError(t, err)
+	guard := NewReplayGuard(time.Minute, 100)
 
 	t.Run("successful hash verification", func(t *testing.T) {
 
 		metrics := []byte("test metrics")
-		expectedHash := crypto.GetHash(metrics, key)
 
 		testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusOK)
 		})
 
-		middleware := HashMiddleware(key)(testHandler)
+		middleware := HashMiddleware(keys, guard)(testHandler)
 
-		req := httptest.NewRequest("POST", "/test", bytes.NewBuffer(metrics))
-		req.Header.Set("HashSHA256", expectedHash)
+		req := signedRequest(metrics, key, time.Now(), "nonce-1")
 
 		recorder := httptest.NewRecorder()
 
@@ -41,7 +57,7 @@ func TestHashMiddleware(t *testing.T) {
 			w.WriteHeader(http.StatusOK)
 		})
 
-		middleware := HashMiddleware(key)(testHandler)
+		middleware := HashMiddleware(keys, guard)(testHandler)
 
 		req := httptest.NewRequest("POST", "/test", bytes.NewBuffer(metrics))
 
@@ -59,7 +75,7 @@ func TestHashMiddleware(t *testing.T) {
 			w.WriteHeader(http.StatusOK)
 		})
 
-		middleware := HashMiddleware(key)(testHandler)
+		middleware := HashMiddleware(keys, guard)(testHandler)
 
 		req := httptest.NewRequest("POST", "/test", bytes.NewBuffer(metrics))
 
@@ -71,4 +87,108 @@ func TestHashMiddleware(t *testing.T) {
 
 		assert.Equal(t, http.StatusBadRequest, recorder.Code)
 	})
+
+	t.Run("replayed request", func(t *testing.T) {
+		metrics := []byte("test metrics")
+		calls := 0
+
+		testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusOK)
+		})
+
+		middleware := HashMiddleware(keys, guard)(testHandler)
+		now := time.Now()
+
+		recorder := httptest.NewRecorder()
+		middleware.ServeHTTP(recorder, signedRequest(metrics, key, now, "nonce-2"))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		recorder = httptest.NewRecorder()
+		middleware.ServeHTTP(recorder, signedRequest(metrics, key, now, "nonce-2"))
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("stale request", func(t *testing.T) {
+		metrics := []byte("test metrics")
+
+		testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+
+		middleware := HashMiddleware(keys, guard)(testHandler)
+
+		recorder := httptest.NewRecorder()
+		middleware.ServeHTTP(recorder, signedRequest(metrics, key, time.Now().Add(-time.Hour), "nonce-3"))
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+
+	t.Run("tampered timestamp", func(t *testing.T) {
+		metrics := []byte("test metrics")
+
+		testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+
+		middleware := HashMiddleware(keys, guard)(testHandler)
+
+		req := signedRequest(metrics, key, time.Now().Add(-time.Hour), "nonce-4")
+		req.Header.Set(crypto.HeaderTimestamp, strconv.FormatInt(time.Now().Unix(), 10))
+		recorder := httptest.NewRecorder()
+		middleware.ServeHTTP(recorder, req)
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+}
+
+func TestHashMiddlewareKeyRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "keys.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"hmac":[{"id":"new","key":"k2"},{"id":"old","key":"k1"}]}`), 0o600))
+	keys, err := crypto.LoadKeyRing(path)
+	require.NoError(t, err)
+
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	middleware := HashMiddleware(keys, NewReplayGuard(time.Minute, 100))(testHandler)
+	metrics := []byte("test metrics")
+
+	tests := []struct {
+		name           string
+		key            string
+		keyID          string
+		expectedStatus int
+	}{
+		{"newest key by id", "k2", "new", http.StatusOK},
+		{"previous key by id", "k1", "old", http.StatusOK},
+		{"key without id", "k1", "", http.StatusOK},
+		{"id of another key", "k1", "new", http.StatusBadRequest},
+		{"retired key", "k0", "", http.StatusBadRequest},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := signedRequest(metrics, tt.key, time.Now(), fmt.Sprintf("rotation-%d", i))
+			if tt.keyID != "" {
+				req.Header.Set(crypto.HeaderKeyID, tt.keyID)
+			}
+			recorder := httptest.NewRecorder()
+			middleware.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+		})
+	}
+
+	// После ротации старый ключ перестает приниматься
+	require.NoError(t, os.WriteFile(path, []byte(`{"hmac":[{"id":"new","key":"k2"}]}`), 0o600))
+	require.NoError(t, keys.Reload())
+
+	req := signedRequest(metrics, "k1", time.Now(), "rotation-reloaded")
+	req.Header.Set(crypto.HeaderKeyID, "old")
+	recorder := httptest.NewRecorder()
+	middleware.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
 }
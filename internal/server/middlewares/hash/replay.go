@@ -0,0 +1,70 @@
+package hash
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Ошибки проверки повторной отправки запроса
+var (
+	ErrStaleRequest = errors.New("request timestamp is outside of allowed window")
+	ErrReplay       = errors.New("request nonce was already used")
+	ErrNoNonce      = errors.New("missing request nonce")
+)
+
+// ReplayGuard отклоняет запросы со старым временем и повторно использованными nonce.
+// Nonce хранятся, пока время запроса не выйдет из окна; при переполнении кеша
+// вытесняются самые ранние
+type ReplayGuard struct {
+	mu     sync.Mutex
+	window time.Duration
+	size   int
+	seen   map[string]time.Time
+	order  []string
+	now    func() time.Time
+}
+
+// NewReplayGuard создает ReplayGuard с допустимым расхождением часов window
+// и кешем не более чем на size nonce
+func NewReplayGuard(window time.Duration, size int) *ReplayGuard {
+	return &ReplayGuard{
+		window: window,
+		size:   size,
+		seen:   make(map[string]time.Time),
+		now:    time.Now,
+	}
+}
+
+// Check проверяет время запроса и запоминает его nonce
+func (g *ReplayGuard) Check(timestamp time.Time, nonce string) error {
+	if nonce == "" {
+		return ErrNoNonce
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	if timestamp.Before(now.Add(-g.window)) || timestamp.After(now.Add(g.window)) {
+		return ErrStaleRequest
+	}
+
+	for len(g.order) > 0 && !g.seen[g.order[0]].After(now) {
+		delete(g.seen, g.order[0])
+		g.order = g.order[1:]
+	}
+
+	if _, ok := g.seen[nonce]; ok {
+		return ErrReplay
+	}
+
+	if g.size > 0 && len(g.order) >= g.size {
+		delete(g.seen, g.order[0])
+		g.order = g.order[1:]
+	}
+
+	g.seen[nonce] = timestamp.Add(g.window)
+	g.order = append(g.order, nonce)
+	return nil
+}
@@ -0,0 +1,31 @@
+package hash
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayGuard(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	guard := NewReplayGuard(time.Minute, 2)
+	guard.now = func() time.Time { return now }
+
+	require.NoError(t, guard.Check(now, "a"))
+	require.ErrorIs(t, guard.Check(now, "a"), ErrReplay)
+	require.ErrorIs(t, guard.Check(now, ""), ErrNoNonce)
+	require.ErrorIs(t, guard.Check(now.Add(-2*time.Minute), "b"), ErrStaleRequest)
+	require.ErrorIs(t, guard.Check(now.Add(2*time.Minute), "b"), ErrStaleRequest)
+
+	// Кеш ограничен: при переполнении вытесняется самый ранний nonce
+	require.NoError(t, guard.Check(now, "b"))
+	require.NoError(t, guard.Check(now, "c"))
+	require.Len(t, guard.seen, 2)
+	require.NoError(t, guard.Check(now, "a"))
+
+	// После выхода времени запроса из окна nonce забываются
+	now = now.Add(2 * time.Minute)
+	require.NoError(t, guard.Check(now, "d"))
+	require.Len(t, guard.seen, 1)
+}
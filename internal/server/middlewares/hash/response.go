@@ -0,0 +1,63 @@
+package hash
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/crypto"
+	log "github.com/sirupsen/logrus"
+)
+
+// signingResponseWriter накапливает ответ, чтобы подписать его до отправки заголовков
+type signingResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+// Write накапливает тело ответа
+func (w *signingResponseWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+// WriteHeader запоминает код ответа
+func (w *signingResponseWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+}
+
+// SignResponseMiddleware подписывает тело ответа вместе с временем и nonce запроса,
+// чтобы подпись ответа нельзя было использовать для другого запроса. Используется
+// ключ запроса, а если он неизвестен — самый новый. Без ключей подписи ответ
+// отправляется как есть, без накопления в памяти
+func SignResponseMiddleware(keys *crypto.KeyRing) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		logFn := func(res http.ResponseWriter, req *http.Request) {
+			key, ok := keys.SigningKey(req.Header.Get(crypto.HeaderKeyID))
+			if !ok {
+				next.ServeHTTP(res, req)
+				return
+			}
+
+			sw := &signingResponseWriter{ResponseWriter: res}
+			next.ServeHTTP(sw, req)
+
+			if sw.status == 0 {
+				sw.status = http.StatusOK
+			}
+
+			hash := crypto.GetSignedHash(sw.body.Bytes(), key.Key,
+				req.Header.Get(crypto.HeaderTimestamp), req.Header.Get(crypto.HeaderNonce))
+			res.Header().Set(crypto.HeaderHash, hash)
+			if key.ID != "" {
+				res.Header().Set(crypto.HeaderKeyID, key.ID)
+			}
+			res.WriteHeader(sw.status)
+			if _, err := res.Write(sw.body.Bytes()); err != nil {
+				log.Error(err)
+			}
+		}
+		return http.HandlerFunc(logFn)
+	}
+}
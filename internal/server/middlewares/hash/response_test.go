@@ -0,0 +1,71 @@
+package hash
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignResponseMiddleware(t *testing.T) {
+	key := "secret"
+	keys, err := crypto.NewKeyRing(key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		status         int
+		body           string
+		expectedStatus int
+	}{
+		{"json response", http.StatusOK, `{"id":"Alloc","type":"gauge","value":1}`, http.StatusOK},
+		{"empty acknowledgement", 0, "", http.StatusOK},
+		{"error response", http.StatusNotFound, "not found\n", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.status != 0 {
+					w.WriteHeader(tt.status)
+				}
+				w.Write([]byte(tt.body))
+			})
+
+			req := httptest.NewRequest("POST", "/update/", nil)
+			req.Header.Set(crypto.HeaderTimestamp, "1700000000")
+			req.Header.Set(crypto.HeaderNonce, "nonce")
+
+			recorder := httptest.NewRecorder()
+			SignResponseMiddleware(keys)(testHandler).ServeHTTP(recorder, req)
+
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+			assert.Equal(t, tt.body, recorder.Body.String())
+			assert.Equal(t, crypto.GetSignedHash([]byte(tt.body), key, "1700000000", "nonce"),
+				recorder.Header().Get(crypto.HeaderHash))
+			assert.NotEqual(t, crypto.GetSignedHash([]byte(tt.body), key, "1700000000", "other"),
+				recorder.Header().Get(crypto.HeaderHash), "signature must be bound to request nonce")
+		})
+	}
+}
+
+func TestSignResponseMiddlewareWithoutKey(t *testing.T) {
+	keys, err := crypto.NewKeyRing("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Same(t, recorder, w, "response must not be buffered without signing key")
+		w.Write([]byte("ok"))
+	})
+	SignResponseMiddleware(keys)(testHandler).ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+
+	assert.Equal(t, "ok", recorder.Body.String())
+	assert.Empty(t, recorder.Header().Get(crypto.HeaderHash))
+}
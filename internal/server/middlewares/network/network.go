@@ -1,21 +1,33 @@
+// Модуль ограничения доступа клиентами из доверенных сетей
 package network
 
 import (
 	"net/http"
 
-	"github.com/romanmendelproject/go-yandex-metrics/utils"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/trusted"
+	log "github.com/sirupsen/logrus"
 )
 
-func XrealIPMiddleware(trustedsubnet string) func(http.Handler) http.Handler {
+// TrustedSubnetMiddleware пропускает только запросы клиентов из доверенных сетей
+// и сохраняет адрес клиента в контексте. Адрес берется из соединения, а от
+// доверенных прокси — из X-Forwarded-For или X-Real-IP
+func TrustedSubnetMiddleware(access *trusted.Access) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		logFn := func(res http.ResponseWriter, req *http.Request) {
-			xrealip := req.Header.Get("X-Real-IP")
-			if trustedsubnet != "" {
-				result := utils.ISinTrustedNetwork(xrealip, trustedsubnet)
-				if !result {
-					res.WriteHeader(http.StatusForbidden)
-					return
-				}
+			client, ok, err := access.AllowRequest(req)
+			switch {
+			case err != nil && access.Enabled():
+				log.Error(err)
+				res.WriteHeader(http.StatusForbidden)
+				return
+			case err != nil:
+				log.Warn(err)
+			case !ok:
+				log.Errorf("Client %s is not in trusted subnet", client)
+				res.WriteHeader(http.StatusForbidden)
+				return
+			default:
+				req = req.WithContext(trusted.WithClient(req.Context(), client))
 			}
 			next.ServeHTTP(res, req)
 		}
@@ -5,44 +5,101 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/romanmendelproject/go-yandex-metrics/internal/trusted"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-// Test for XrealIPMiddleware
-func TestXrealIPMiddleware(t *testing.T) {
+// Test for TrustedSubnetMiddleware
+func TestTrustedSubnetMiddleware(t *testing.T) {
 	tests := []struct {
 		name           string
+		remoteAddr     string
 		xRealIP        string
+		xForwardedFor  string
 		trustedSubnet  string
+		trustedProxies string
 		expectedStatus int
 	}{
 		{
 			name:           "Allowed IP",
-			xRealIP:        "192.168.1.1",
+			remoteAddr:     "192.168.1.1:5000",
 			trustedSubnet:  "192.168.1.0/24",
 			expectedStatus: http.StatusOK,
 		},
 		{
 			name:           "Forbidden IP",
-			xRealIP:        "192.168.2.1",
+			remoteAddr:     "192.168.2.1:5000",
 			trustedSubnet:  "192.168.1.0/24",
 			expectedStatus: http.StatusForbidden,
 		},
 		{
 			name:           "No Trusted Subnet",
-			xRealIP:        "192.168.2.1",
+			remoteAddr:     "192.168.2.1:5000",
 			trustedSubnet:  "",
 			expectedStatus: http.StatusOK,
 		},
+		{
+			name:           "Second subnet of list",
+			remoteAddr:     "10.0.0.7:5000",
+			trustedSubnet:  "192.168.1.0/24, 10.0.0.0/8",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "IPv6 client",
+			remoteAddr:     "[2001:db8::10]:5000",
+			trustedSubnet:  "2001:db8::/32",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Spoofed X-Real-IP from untrusted client",
+			remoteAddr:     "203.0.113.5:5000",
+			xRealIP:        "192.168.1.1",
+			trustedSubnet:  "192.168.1.0/24",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "X-Real-IP from trusted proxy",
+			remoteAddr:     "10.0.0.2:5000",
+			xRealIP:        "192.168.1.1",
+			trustedSubnet:  "192.168.1.0/24",
+			trustedProxies: "10.0.0.2",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "X-Forwarded-For through proxy chain",
+			remoteAddr:     "10.0.0.2:5000",
+			xForwardedFor:  "203.0.113.5, 192.168.1.1, 10.0.0.3",
+			trustedSubnet:  "192.168.1.0/24",
+			trustedProxies: "10.0.0.0/24",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "X-Forwarded-For with spoofed leftmost hop",
+			remoteAddr:     "10.0.0.2:5000",
+			xForwardedFor:  "192.168.1.1, 203.0.113.5",
+			trustedSubnet:  "192.168.1.0/24",
+			trustedProxies: "10.0.0.0/24",
+			expectedStatus: http.StatusForbidden,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			req := httptest.NewRequest("GET", "/", nil)
-			req.Header.Set("X-Real-IP", tt.xRealIP)
+			access, err := trusted.NewAccess(tt.trustedSubnet, tt.trustedProxies)
+			require.NoError(t, err)
+
+			req := httptest.NewRequest("POST", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.xRealIP != "" {
+				req.Header.Set("X-Real-IP", tt.xRealIP)
+			}
+			if tt.xForwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.xForwardedFor)
+			}
 
 			rr := httptest.NewRecorder()
-			handler := XrealIPMiddleware(tt.trustedSubnet)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler := TrustedSubnetMiddleware(access)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				w.WriteHeader(http.StatusOK) // Respond with OK if the middleware allows the request
 			}))
 
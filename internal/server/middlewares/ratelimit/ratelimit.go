@@ -0,0 +1,81 @@
+// Модуль ограничения частоты запросов клиентов
+package ratelimit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/ratelimit"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/tenant"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/trusted"
+	log "github.com/sirupsen/logrus"
+)
+
+// CountFunc возвращает число метрик в запросе
+type CountFunc func(req *http.Request) (int, error)
+
+// SingleMetric считает, что запрос содержит одну метрику
+func SingleMetric(req *http.Request) (int, error) {
+	return 1, nil
+}
+
+// BatchMetrics считает метрики в JSON массиве тела запроса и восстанавливает тело
+func BatchMetrics(req *http.Request) (int, error) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return 0, err
+	}
+	req.Body = io.NopCloser(bytes.NewBuffer(body))
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(body, &batch); err != nil {
+		// Некорректное тело отклонит обработчик, лимит учитывает его как одну метрику
+		return 1, nil
+	}
+	return len(batch), nil
+}
+
+// RateLimitMiddleware ограничивает частоту запросов и метрик каждого клиента.
+// При превышении лимита отвечает 429 с заголовком Retry-After
+func RateLimitMiddleware(limiter *ratelimit.Limiter, resolver *trusted.Resolver, count CountFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		logFn := func(res http.ResponseWriter, req *http.Request) {
+			if limiter == nil {
+				next.ServeHTTP(res, req)
+				return
+			}
+
+			remote, err := trusted.ParseAddr(req.RemoteAddr)
+			if err != nil {
+				log.Error(err)
+				res.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			client, err := resolver.Resolve(remote, req.Header.Values(trusted.HeaderForwardedFor), req.Header.Get(trusted.HeaderRealIP))
+			if err != nil {
+				log.Error(err)
+				res.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			n, err := count(req)
+			if err != nil {
+				log.Error(err)
+				res.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			key := limiter.Key(req.Context(), client, req.Header.Get(tenant.HeaderAPIKey))
+			if wait, err := limiter.Allow(key, n); err != nil {
+				log.Warnf("Client %s: %s", key, err)
+				res.Header().Set("Retry-After", ratelimit.RetryAfter(wait))
+				http.Error(res, err.Error(), http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(res, req)
+		}
+		return http.HandlerFunc(logFn)
+	}
+}
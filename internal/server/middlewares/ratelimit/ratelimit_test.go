@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/ratelimit"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/trusted"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	limiter, err := ratelimit.New(ratelimit.KeyByIP, ratelimit.Limits{Requests: 1, Metrics: 3})
+	require.NoError(t, err)
+	proxies, err := trusted.ParseList("10.0.0.1")
+	require.NoError(t, err)
+
+	var received []byte
+	handler := RateLimitMiddleware(limiter, trusted.NewResolver(proxies), BatchMetrics)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name           string
+		remoteAddr     string
+		realIP         string
+		body           string
+		expectedStatus int
+	}{
+		{"first request", "192.168.1.1:5000", "", `[{"id":"a"}]`, http.StatusOK},
+		{"requests limit", "192.168.1.1:5000", "", `[{"id":"a"}]`, http.StatusTooManyRequests},
+		{"other client", "192.168.1.2:5000", "", `[{"id":"a"}]`, http.StatusOK},
+		{"client behind proxy", "10.0.0.1:5000", "192.168.1.3", `[{"id":"a"}]`, http.StatusOK},
+		{"same client behind proxy", "10.0.0.1:5000", "192.168.1.3", `[{"id":"a"}]`, http.StatusTooManyRequests},
+		{"batch larger than burst", "192.168.1.4:5000", "", `[{"id":"a"},{"id":"b"},{"id":"c"},{"id":"d"},{"id":"e"}]`, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewBufferString(tt.body))
+			req.RemoteAddr = tt.remoteAddr
+			if tt.realIP != "" {
+				req.Header.Set(trusted.HeaderRealIP, tt.realIP)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus == http.StatusTooManyRequests {
+				assert.Equal(t, "1", rr.Header().Get("Retry-After"))
+			} else {
+				assert.Equal(t, tt.body, string(received), "body must reach the handler")
+			}
+		})
+	}
+
+	// Пачка из пяти метрик исчерпывает лимит метрик, следующий запрос отклоняется
+	limiter, err = ratelimit.New(ratelimit.KeyByIP, ratelimit.Limits{Metrics: 3})
+	require.NoError(t, err)
+	handler = RateLimitMiddleware(limiter, nil, BatchMetrics)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	codes := []int{}
+	for _, body := range []string{`[{},{},{},{},{}]`, `[{}]`} {
+		req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		codes = append(codes, rr.Code)
+	}
+	assert.Equal(t, []int{http.StatusOK, http.StatusTooManyRequests}, codes)
+}
+
+func TestRateLimitMiddlewareDisabled(t *testing.T) {
+	handler := RateLimitMiddleware(nil, nil, SingleMetric)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for i := 0; i < 10; i++ {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/update/", nil))
+		require.Equal(t, http.StatusOK, rr.Code)
+	}
+}
@@ -0,0 +1,43 @@
+// Модуль определения тенанта HTTP запроса
+package tenant
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/tenant"
+	log "github.com/sirupsen/logrus"
+)
+
+// TenantMiddleware определяет тенант запроса и сохраняет его в контексте
+func TenantMiddleware(resolver *tenant.Resolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		logFn := func(res http.ResponseWriter, req *http.Request) {
+			apiKey := req.Header.Get(tenant.HeaderAPIKey)
+			name, err := resolver.Resolve(apiKey, req.Header.Get(tenant.HeaderTenant))
+			if err != nil {
+				log.Error(err)
+				http.Error(res, err.Error(), statusCode(err))
+				return
+			}
+
+			ctx := tenant.WithTenant(req.Context(), name)
+			if apiKey != "" {
+				ctx = tenant.WithAuthenticated(req.Context(), name)
+			}
+			next.ServeHTTP(res, req.WithContext(ctx))
+		}
+		return http.HandlerFunc(logFn)
+	}
+}
+
+func statusCode(err error) int {
+	switch {
+	case errors.Is(err, tenant.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, tenant.ErrInvalidName):
+		return http.StatusBadRequest
+	default:
+		return http.StatusUnauthorized
+	}
+}
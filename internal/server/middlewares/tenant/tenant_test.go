@@ -0,0 +1,57 @@
+package tenant
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/tenant"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenantMiddleware(t *testing.T) {
+	resolver := tenant.NewResolver(map[string]string{"secret": "payments"})
+
+	tests := []struct {
+		name           string
+		resolver       *tenant.Resolver
+		apiKey         string
+		tenant         string
+		expectedStatus int
+		expectedTenant string
+	}{
+		{"tenant by key", resolver, "secret", "", http.StatusOK, "payments"},
+		{"foreign tenant", resolver, "secret", "infra", http.StatusForbidden, ""},
+		{"unknown key", resolver, "guess", "", http.StatusUnauthorized, ""},
+		{"missing key", resolver, "", "payments", http.StatusUnauthorized, ""},
+		{"default tenant without keys", tenant.NewResolver(nil), "", "", http.StatusOK, tenant.Default},
+		{"foreign tenant without keys", tenant.NewResolver(nil), "", "payments", http.StatusUnauthorized, ""},
+		{"invalid tenant", resolver, "secret", "pay/ments", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotTenant string
+			var authenticated bool
+			handler := TenantMiddleware(tt.resolver)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotTenant = tenant.FromContext(r.Context())
+				authenticated = tenant.Authenticated(r.Context())
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.apiKey != "" {
+				req.Header.Set(tenant.HeaderAPIKey, tt.apiKey)
+			}
+			if tt.tenant != "" {
+				req.Header.Set(tenant.HeaderTenant, tt.tenant)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, tt.expectedTenant, gotTenant)
+			assert.Equal(t, tt.apiKey != "" && rr.Code == http.StatusOK, authenticated)
+		})
+	}
+}
@@ -0,0 +1,147 @@
+// Модуль проверки прав доступа к метрикам по правилам на шаблоны имен
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/auth"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/tenant"
+	log "github.com/sirupsen/logrus"
+)
+
+// Action определяет действие над метрикой
+type Action string
+
+// Действия над метриками
+const (
+	ActionRead  Action = "read"
+	ActionWrite Action = "write"
+)
+
+// Any совпадает с любым субъектом, действием или типом метрики
+const Any = "*"
+
+// Префиксы субъектов правил
+const (
+	SubjectToken  = "token:"
+	SubjectTenant = "tenant:"
+)
+
+// ErrDenied возвращается, если ни одно правило не разрешает действие
+var ErrDenied = errors.New("access denied by policy")
+
+// Rule разрешает субъекту Subject действие Action над метриками типа Type,
+// имена которых соответствуют шаблону Name (синтаксис path.Match)
+type Rule struct {
+	Subject string `json:"subject"` // token:<id>, tenant:<name> (тенант по API ключу) или *
+	Action  string `json:"action"`  // read, write или *
+	Name    string `json:"name"`    // например payments.*
+	Type    string `json:"type"`    // gauge, counter, * или пусто
+}
+
+// Engine проверяет действия над метриками. Все, что не разрешено правилами, запрещено
+type Engine struct {
+	rules  []Rule
+	dryRun bool
+}
+
+type rulesFile struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Load загружает правила из JSON файла. В режиме dryRun запреты только логируются
+func Load(filePath string, dryRun bool) (*Engine, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var file rulesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse policy %s: %w", filePath, err)
+	}
+
+	return New(file.Rules, dryRun)
+}
+
+// New создает Engine и проверяет корректность правил
+func New(rules []Rule, dryRun bool) (*Engine, error) {
+	for i, rule := range rules {
+		if rule.Subject == "" || rule.Name == "" {
+			return nil, fmt.Errorf("policy rule %d: subject and name are required", i)
+		}
+		switch Action(rule.Action) {
+		case ActionRead, ActionWrite, Any:
+		default:
+			return nil, fmt.Errorf("policy rule %d: invalid action %q", i, rule.Action)
+		}
+		if _, err := path.Match(rule.Name, ""); err != nil {
+			return nil, fmt.Errorf("policy rule %d: invalid name pattern %q: %w", i, rule.Name, err)
+		}
+	}
+
+	return &Engine{rules: rules, dryRun: dryRun}, nil
+}
+
+// Authorize проверяет, что субъект запроса может выполнить action над метрикой.
+// Nil Engine разрешает все
+func (e *Engine) Authorize(ctx context.Context, action Action, mType, name string) error {
+	if e == nil {
+		return nil
+	}
+
+	subjects := subjectsFromContext(ctx)
+	for _, rule := range e.rules {
+		if rule.matches(subjects, action, mType, name) {
+			return nil
+		}
+	}
+
+	err := fmt.Errorf("%w: %s %s %s for %s", ErrDenied, action, mType, name, strings.Join(subjects, ", "))
+	if e.dryRun {
+		log.Warnf("Policy dry run: %s", err)
+		return nil
+	}
+	return err
+}
+
+func (r Rule) matches(subjects []string, action Action, mType, name string) bool {
+	if r.Action != Any && Action(r.Action) != action {
+		return false
+	}
+	if r.Type != "" && r.Type != Any && r.Type != mType {
+		return false
+	}
+	if ok, _ := path.Match(r.Name, name); !ok {
+		return false
+	}
+
+	if r.Subject == Any {
+		return true
+	}
+	for _, subject := range subjects {
+		if r.Subject == subject {
+			return true
+		}
+	}
+	return false
+}
+
+// subjectsFromContext возвращает все субъекты, от имени которых выполняется запрос.
+// Субъект тенанта добавляется, только если тенант подтвержден API ключом
+func subjectsFromContext(ctx context.Context) []string {
+	var subjects []string
+	if tenant.Authenticated(ctx) {
+		subjects = append(subjects, SubjectTenant+tenant.FromContext(ctx))
+	}
+	if token, ok := auth.FromContext(ctx); ok {
+		subjects = append(subjects, SubjectToken+token.ID)
+	}
+	return subjects
+}
@@ -0,0 +1,81 @@
+package policy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/auth"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/tenant"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorize(t *testing.T) {
+	engine, err := New([]Rule{
+		{Subject: "tenant:payments", Action: "write", Name: "payments.*", Type: "*"},
+		{Subject: "tenant:payments", Action: "read", Name: "infra.*"},
+		{Subject: "token:ops", Action: "*", Name: "*", Type: "gauge"},
+	}, false)
+	require.NoError(t, err)
+
+	payments := tenant.WithAuthenticated(context.Background(), "payments")
+	claimed := tenant.WithTenant(context.Background(), "payments")
+	ops := auth.WithToken(context.Background(), auth.Token{ID: "ops"})
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		action  Action
+		mType   string
+		metric  string
+		wantErr error
+	}{
+		{"write own metrics", payments, ActionWrite, "counter", "payments.tx", nil},
+		{"read foreign metrics", payments, ActionRead, "gauge", "infra.cpu", nil},
+		{"write foreign metrics", payments, ActionWrite, "gauge", "infra.cpu", ErrDenied},
+		{"read own metrics without rule", payments, ActionRead, "gauge", "payments.tx", ErrDenied},
+		{"token rule by type", ops, ActionWrite, "gauge", "Alloc", nil},
+		{"token rule other type", ops, ActionWrite, "counter", "PollCount", ErrDenied},
+		{"tenant without api key", claimed, ActionWrite, "counter", "payments.tx", ErrDenied},
+		{"no matching subject", context.Background(), ActionRead, "gauge", "infra.cpu", ErrDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorIs(t, engine.Authorize(tt.ctx, tt.action, tt.mType, tt.metric), tt.wantErr)
+		})
+	}
+}
+
+func TestAuthorizeDryRun(t *testing.T) {
+	engine, err := New(nil, true)
+	require.NoError(t, err)
+	require.NoError(t, engine.Authorize(context.Background(), ActionWrite, "gauge", "Alloc"))
+
+	var disabled *Engine
+	require.NoError(t, disabled.Authorize(context.Background(), ActionWrite, "gauge", "Alloc"))
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "valid.json")
+	require.NoError(t, os.WriteFile(valid, []byte(`{"rules":[{"subject":"*","action":"read","name":"*"}]}`), 0o600))
+	engine, err := Load(valid, false)
+	require.NoError(t, err)
+	require.NoError(t, engine.Authorize(context.Background(), ActionRead, "gauge", "Alloc"))
+
+	invalid := []string{
+		`{"rules":[{"subject":"*","action":"delete","name":"*"}]}`,
+		`{"rules":[{"subject":"*","action":"read","name":"[a-"}]}`,
+		`{"rules":[{"action":"read","name":"*"}]}`,
+		`not json`,
+	}
+	for i, data := range invalid {
+		path := filepath.Join(dir, "invalid.json")
+		require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+		_, err := Load(path, false)
+		require.Error(t, err, "case %d", i)
+	}
+}
@@ -0,0 +1,186 @@
+// Модуль ограничения частоты запросов и количества принимаемых метрик
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/tenant"
+)
+
+// KeyBy определяет, по какому признаку клиенты получают отдельные лимиты
+type KeyBy string
+
+// Признаки клиента
+const (
+	KeyByIP     KeyBy = "ip"
+	KeyByAPIKey KeyBy = "apikey"
+	KeyByTenant KeyBy = "tenant"
+)
+
+// MetricThrottled имя собственной метрики сервера с числом отклоненных запросов
+const MetricThrottled = "ThrottledRequests"
+
+// Ошибки ограничения частоты
+var (
+	ErrRateLimited = errors.New("rate limit exceeded")
+	ErrInvalidKey  = errors.New("invalid rate limit key")
+)
+
+// maxClients число клиентов, после которого из памяти удаляются полностью восстановленные лимиты
+const maxClients = 10000
+
+// Limits частоты запросов и метрик в секунду. Нулевая частота снимает ограничение,
+// нулевой burst заменяется на частоту, округленную вверх
+type Limits struct {
+	Requests      float64
+	RequestsBurst int
+	Metrics       float64
+	MetricsBurst  int
+}
+
+// bucket реализует алгоритм token bucket
+type bucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(rate float64, burst int, now time.Time) *bucket {
+	b := float64(burst)
+	if b <= 0 {
+		b = math.Max(1, math.Ceil(rate))
+	}
+	return &bucket{rate: rate, burst: b, tokens: b, last: now}
+}
+
+func (b *bucket) refill(now time.Time) {
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+// wait возвращает время до момента, когда можно будет взять n токенов.
+// Пачка больше burst пропускается при полном ведре и уводит его в минус
+func (b *bucket) wait(n float64) time.Duration {
+	if b.rate <= 0 || b.tokens >= n || b.tokens >= b.burst {
+		return 0
+	}
+	need := math.Min(n, b.burst) - b.tokens
+	return time.Duration(need / b.rate * float64(time.Second))
+}
+
+func (b *bucket) full() bool {
+	return b.rate <= 0 || b.tokens >= b.burst
+}
+
+type client struct {
+	requests *bucket
+	metrics  *bucket
+}
+
+// Limiter ограничивает каждого клиента отдельно. nil Limiter пропускает все запросы
+type Limiter struct {
+	keyBy     KeyBy
+	limits    Limits
+	mu        sync.Mutex
+	clients   map[string]*client
+	throttled atomic.Int64
+	now       func() time.Time
+}
+
+// New создает Limiter. Если обе частоты нулевые, возвращает nil
+func New(keyBy KeyBy, limits Limits) (*Limiter, error) {
+	switch keyBy {
+	case KeyByIP, KeyByAPIKey, KeyByTenant:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidKey, keyBy)
+	}
+	if limits.Requests <= 0 && limits.Metrics <= 0 {
+		return nil, nil
+	}
+	return &Limiter{
+		keyBy:   keyBy,
+		limits:  limits,
+		clients: make(map[string]*client),
+		now:     time.Now,
+	}, nil
+}
+
+// Key возвращает ключ клиента по его адресу, API ключу и тенанту из контекста.
+// Клиенты без API ключа ограничиваются по адресу
+func (l *Limiter) Key(ctx context.Context, addr netip.Addr, apiKey string) string {
+	switch {
+	case l.keyBy == KeyByTenant:
+		return "tenant:" + tenant.FromContext(ctx)
+	case l.keyBy == KeyByAPIKey && apiKey != "":
+		return "apikey:" + apiKey
+	default:
+		return "ip:" + addr.String()
+	}
+}
+
+// Allow учитывает запрос клиента key с metrics метриками. Если лимит исчерпан,
+// возвращает ErrRateLimited и время, через которое стоит повторить запрос
+func (l *Limiter) Allow(key string, metrics int) (time.Duration, error) {
+	if l == nil {
+		return 0, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	c, ok := l.clients[key]
+	if !ok {
+		if len(l.clients) >= maxClients {
+			l.evict(now)
+		}
+		c = &client{
+			requests: newBucket(l.limits.Requests, l.limits.RequestsBurst, now),
+			metrics:  newBucket(l.limits.Metrics, l.limits.MetricsBurst, now),
+		}
+		l.clients[key] = c
+	}
+	c.requests.refill(now)
+	c.metrics.refill(now)
+
+	wait := max(c.requests.wait(1), c.metrics.wait(float64(metrics)))
+	if wait > 0 {
+		l.throttled.Add(1)
+		return wait, ErrRateLimited
+	}
+	c.requests.tokens--
+	c.metrics.tokens -= float64(metrics)
+	return 0, nil
+}
+
+// evict удаляет клиентов с полностью восстановленными лимитами: они не отличаются от новых
+func (l *Limiter) evict(now time.Time) {
+	for key, c := range l.clients {
+		c.requests.refill(now)
+		c.metrics.refill(now)
+		if c.requests.full() && c.metrics.full() {
+			delete(l.clients, key)
+		}
+	}
+}
+
+// Throttled возвращает число отклоненных запросов с предыдущего вызова
+func (l *Limiter) Throttled() int64 {
+	if l == nil {
+		return 0
+	}
+	return l.throttled.Swap(0)
+}
+
+// RetryAfter округляет время ожидания вверх до целых секунд для заголовка Retry-After
+func RetryAfter(wait time.Duration) string {
+	return fmt.Sprintf("%d", int64(math.Ceil(wait.Seconds())))
+}
@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/tenant"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLimiter(t *testing.T, keyBy KeyBy, limits Limits) (*Limiter, *time.Time) {
+	limiter, err := New(keyBy, limits)
+	require.NoError(t, err)
+	now := time.Unix(1700000000, 0)
+	limiter.now = func() time.Time { return now }
+	return limiter, &now
+}
+
+func TestAllowRequests(t *testing.T) {
+	limiter, now := newTestLimiter(t, KeyByIP, Limits{Requests: 2})
+
+	for i := 0; i < 2; i++ {
+		_, err := limiter.Allow("ip:10.0.0.1", 1)
+		require.NoError(t, err)
+	}
+	wait, err := limiter.Allow("ip:10.0.0.1", 1)
+	require.ErrorIs(t, err, ErrRateLimited)
+	require.Equal(t, 500*time.Millisecond, wait)
+
+	_, err = limiter.Allow("ip:10.0.0.2", 1)
+	require.NoError(t, err, "other clients have their own limit")
+
+	*now = now.Add(wait)
+	_, err = limiter.Allow("ip:10.0.0.1", 1)
+	require.NoError(t, err)
+
+	require.Equal(t, int64(1), limiter.Throttled())
+	require.Equal(t, int64(0), limiter.Throttled())
+}
+
+func TestAllowMetrics(t *testing.T) {
+	limiter, now := newTestLimiter(t, KeyByIP, Limits{Metrics: 10, MetricsBurst: 20})
+
+	_, err := limiter.Allow("ip:10.0.0.1", 15)
+	require.NoError(t, err)
+	wait, err := limiter.Allow("ip:10.0.0.1", 10)
+	require.ErrorIs(t, err, ErrRateLimited)
+	require.Equal(t, 500*time.Millisecond, wait)
+
+	// Пачка больше burst проходит при полном ведре
+	*now = now.Add(2 * time.Second)
+	_, err = limiter.Allow("ip:10.0.0.1", 50)
+	require.NoError(t, err)
+	wait, err = limiter.Allow("ip:10.0.0.1", 1)
+	require.ErrorIs(t, err, ErrRateLimited)
+	require.Equal(t, 3100*time.Millisecond, wait)
+}
+
+func TestNew(t *testing.T) {
+	limiter, err := New(KeyByIP, Limits{})
+	require.NoError(t, err)
+	require.Nil(t, limiter)
+
+	_, err = limiter.Allow("ip:10.0.0.1", 100)
+	require.NoError(t, err, "nil limiter allows everything")
+
+	_, err = New("user", Limits{Requests: 1})
+	require.ErrorIs(t, err, ErrInvalidKey)
+}
+
+func TestKey(t *testing.T) {
+	addr := netip.MustParseAddr("10.0.0.1")
+	ctx := tenant.WithTenant(context.Background(), "payments")
+
+	tests := []struct {
+		keyBy  KeyBy
+		apiKey string
+		want   string
+	}{
+		{KeyByIP, "secret", "ip:10.0.0.1"},
+		{KeyByAPIKey, "secret", "apikey:secret"},
+		{KeyByAPIKey, "", "ip:10.0.0.1"},
+		{KeyByTenant, "secret", "tenant:payments"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.keyBy), func(t *testing.T) {
+			limiter, err := New(tt.keyBy, Limits{Requests: 1})
+			require.NoError(t, err)
+			require.Equal(t, tt.want, limiter.Key(ctx, addr, tt.apiKey))
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	require.Equal(t, "1", RetryAfter(100*time.Millisecond))
+	require.Equal(t, "3", RetryAfter(2100*time.Millisecond))
+}
@@ -4,23 +4,36 @@ package router
 import (
 	"net/http"
 	"net/http/pprof"
+	"time"
 
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/chi/v5"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/crypto"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/auth"
 	"github.com/romanmendelproject/go-yandex-metrics/internal/server/config"
 	"github.com/romanmendelproject/go-yandex-metrics/internal/server/handlers"
+	mwauth "github.com/romanmendelproject/go-yandex-metrics/internal/server/middlewares/auth"
 	"github.com/romanmendelproject/go-yandex-metrics/internal/server/middlewares/compress"
-	"github.com/romanmendelproject/go-yandex-metrics/internal/server/middlewares/crypto"
+	mwcrypto "github.com/romanmendelproject/go-yandex-metrics/internal/server/middlewares/crypto"
 	"github.com/romanmendelproject/go-yandex-metrics/internal/server/middlewares/hash"
 	"github.com/romanmendelproject/go-yandex-metrics/internal/server/middlewares/logger"
 	"github.com/romanmendelproject/go-yandex-metrics/internal/server/middlewares/network"
+	mwratelimit "github.com/romanmendelproject/go-yandex-metrics/internal/server/middlewares/ratelimit"
+	mwtenant "github.com/romanmendelproject/go-yandex-metrics/internal/server/middlewares/tenant"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/ratelimit"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/tenant"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/trusted"
 )
 
-// NewRouter определяет эндпоинты для сервера
-func NewRouter(cfg *config.ClientFlags, handler *handlers.ServiceHandlers) *chi.Mux {
+// NewRouter определяет эндпоинты для сервера. Если tokens не nil, запросы
+// к метрикам и управлению токенами требуют токен доступа с нужным правом.
+// keys содержит активные ключи подписи и шифрования, access ограничивает запись
+// метрик клиентами из доверенных сетей, limiter — частоту записи
+func NewRouter(cfg *config.ClientFlags, handler *handlers.ServiceHandlers, tokens auth.Store, keys *crypto.KeyRing, access *trusted.Access, limiter *ratelimit.Limiter) *chi.Mux {
 	r := chi.NewRouter()
-	if cfg.CryptoKey != "" {
-		r.Use(crypto.CryptoMiddleware(cfg.CryptoKey))
+	replayGuard := hash.NewReplayGuard(time.Duration(cfg.ReplayWindow)*time.Second, cfg.ReplayCacheSize)
+	if keys.HasRSA() {
+		r.Use(mwcrypto.CryptoMiddleware(keys))
 	}
 	r.Use(logger.RequestLogger)
 	r.Use(compress.GzipMiddleware)
@@ -36,36 +49,67 @@ func NewRouter(cfg *config.ClientFlags, handler *handlers.ServiceHandlers) *chi.
 	r.Get("/debug/pprof/symbol", http.HandlerFunc(pprof.Symbol))
 	r.Get("/debug/pprof/trace", http.HandlerFunc(pprof.Trace))
 
-	r.Get("/", handler.AllData)
-	r.Post("/", handlers.HandleBadRequest)
+	r.Group(func(r chi.Router) {
+		r.Use(mwtenant.TenantMiddleware(tenant.NewResolver(cfg.Tenants)))
+		r.Use(hash.SignResponseMiddleware(keys))
 
-	r.Route("/value", func(r chi.Router) {
-		r.Get("/gauge/{mname}", handler.ValueGauge)
-		r.Get("/counter/{mname}", handler.ValueCounter)
-		r.Post("/", handler.ValueJSON)
-		r.Get("/*", handlers.HandleBadRequest)
-	})
+		r.Group(func(r chi.Router) {
+			requireScope(r, tokens, auth.ScopeRead)
+			r.Get("/", handler.AllData)
+		})
+		r.Post("/", handlers.HandleBadRequest)
 
-	r.Route("/update", func(r chi.Router) {
-		r.Post("/gauge/{mname}/{mvalue}", handler.UpdateGauge)
-		r.Post("/counter/{mname}/{mvalue}", handler.UpdateCounter)
-		r.Post("/counter/*", handlers.HandleStatusNotFound)
-		r.Post("/gauge/*", handlers.HandleStatusNotFound)
-		r.Post("/", handler.UpdateJSON)
-		r.Post("/*", handlers.HandleBadRequest)
-	})
-	r.Route("/updates", func(r chi.Router) {
-		if cfg.TrustedSubnet != "" {
-			r.Use(network.XrealIPMiddleware(cfg.TrustedSubnet))
-		}
-		r.Use(middleware.AllowContentType("application/json"))
-		if cfg.Key != "" {
-			r.Use(hash.HashMiddleware(cfg.Key))
-		}
-		r.Post("/", handler.UpdateBatch)
+		r.Route("/value", func(r chi.Router) {
+			requireScope(r, tokens, auth.ScopeRead)
+			r.Get("/gauge/{mname}", handler.ValueGauge)
+			r.Get("/counter/{mname}", handler.ValueCounter)
+			r.Post("/", handler.ValueJSON)
+			r.Get("/*", handlers.HandleBadRequest)
+		})
+
+		r.Route("/update", func(r chi.Router) {
+			r.Use(network.TrustedSubnetMiddleware(access))
+			requireScope(r, tokens, auth.ScopeWrite)
+			r.Use(mwratelimit.RateLimitMiddleware(limiter, access.Resolver(), mwratelimit.SingleMetric))
+			r.Post("/gauge/{mname}/{mvalue}", handler.UpdateGauge)
+			r.Post("/counter/{mname}/{mvalue}", handler.UpdateCounter)
+			r.Post("/counter/*", handlers.HandleStatusNotFound)
+			r.Post("/gauge/*", handlers.HandleStatusNotFound)
+			r.Post("/", handler.UpdateJSON)
+			r.Post("/*", handlers.HandleBadRequest)
+		})
+
+		r.Route("/updates", func(r chi.Router) {
+			r.Use(network.TrustedSubnetMiddleware(access))
+			requireScope(r, tokens, auth.ScopeWrite)
+			r.Use(middleware.AllowContentType("application/json"))
+			if keys.HasHMAC() {
+				r.Use(hash.HashMiddleware(keys, replayGuard))
+			}
+			r.Use(mwratelimit.RateLimitMiddleware(limiter, access.Resolver(), mwratelimit.BatchMetrics))
+			r.Post("/", handler.UpdateBatch)
+		})
 	})
 
+	if tokens != nil {
+		tokenHandler := handlers.NewTokenHandlers(tokens)
+		r.Route("/admin/tokens", func(r chi.Router) {
+			requireScope(r, tokens, auth.ScopeAdmin)
+			r.Get("/", tokenHandler.ListTokens)
+			r.Post("/", tokenHandler.CreateToken)
+			r.Delete("/{id}", tokenHandler.DeleteToken)
+		})
+	}
+
 	r.Get("/ping", handler.Ping)
+	r.Get("/ready", handler.Ready)
 
 	return r
 }
+
+// requireScope подключает проверку токена, если аутентификация включена
+func requireScope(r chi.Router, tokens auth.Store, scope auth.Scope) {
+	if tokens != nil {
+		r.Use(mwauth.AuthMiddleware(auth.NewAuthenticator(tokens), scope))
+	}
+}
@@ -32,7 +32,7 @@ func TestMain(m *testing.M) {
 func TestRouter(t *testing.T) {
 	var storage handlers.Storage
 	handler := handlers.NewHandlers(storage)
-	r := NewRouter(cfg, handler)
+	r := NewRouter(cfg, handler, nil, nil, nil, nil)
 
 	assert.NotEmpty(t, r)
 }
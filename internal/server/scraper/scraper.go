@@ -0,0 +1,265 @@
+// Модуль сбора метрик с агентов, работающих в режиме pull
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/audit"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/cardinality"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/metrics"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/validation"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/tenant"
+	log "github.com/sirupsen/logrus"
+)
+
+// MetricFailures имя собственной метрики сервера с числом неудачных опросов агентов
+const MetricFailures = "ScrapeFailures"
+
+// maxResponseSize максимальный размер ответа агента
+const maxResponseSize = 32 << 20
+
+// Storage хранилище, в которое записываются метрики агентов
+type Storage interface {
+	SetBatch(ctx context.Context, metrics []metrics.Metric) error
+}
+
+// Options настройки опроса агентов
+type Options struct {
+	Targets  []string // адреса JSON метрик агентов, например http://agent:9100/metrics.json
+	Tenant   string   // тенант метрик агентов, по умолчанию tenant.Default
+	Token    string   // токен, передаваемый агентам в заголовке Authorization
+	Interval time.Duration
+	Timeout  time.Duration
+}
+
+// Scraper периодически запрашивает метрики агентов и записывает их в хранилище.
+// Агент отдает накопленные с запуска значения счетчиков, поэтому в хранилище записывается
+// их приращение с прошлого опроса; при первом опросе значения счетчиков только запоминаются
+type Scraper struct {
+	store     Storage
+	options   Options
+	client    *http.Client
+	validator *validation.Validator
+	series    *cardinality.Guard
+	audit     *audit.Auditor
+
+	mu       sync.Mutex
+	counters map[string]map[string]int64 // значения счетчиков по адресу агента
+	failures atomic.Int64
+}
+
+// New создает Scraper. Если агенты не заданы, возвращает nil
+func New(store Storage, options Options) *Scraper {
+	if len(options.Targets) == 0 {
+		return nil
+	}
+	if options.Tenant == "" {
+		options.Tenant = tenant.Default
+	}
+	return &Scraper{
+		store:     store,
+		options:   options,
+		client:    &http.Client{Timeout: options.Timeout},
+		validator: validation.Default(),
+		counters:  make(map[string]map[string]int64),
+	}
+}
+
+// SetValidator задает правила проверки метрик агентов
+func (s *Scraper) SetValidator(v *validation.Validator) {
+	s.validator = v
+}
+
+// SetCardinality задает ограничение числа рядов для метрик агентов
+func (s *Scraper) SetCardinality(g *cardinality.Guard) {
+	s.series = g
+}
+
+// SetAuditor включает запись метрик агентов в журнал аудита
+func (s *Scraper) SetAuditor(a *audit.Auditor) {
+	s.audit = a
+}
+
+// Failures возвращает число неудачных опросов с предыдущего вызова
+func (s *Scraper) Failures() int64 {
+	if s == nil {
+		return 0
+	}
+	return s.failures.Swap(0)
+}
+
+// Run опрашивает всех агентов сразу после запуска и далее с интервалом Interval до отмены ctx
+func (s *Scraper) Run(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	ticker := time.NewTicker(s.options.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.scrapeAll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// scrapeAll параллельно опрашивает всех агентов
+func (s *Scraper) scrapeAll(ctx context.Context) {
+	var targets sync.WaitGroup
+	for _, target := range s.options.Targets {
+		targets.Add(1)
+		go func() {
+			defer targets.Done()
+			if err := s.Scrape(ctx, target); err != nil {
+				s.failures.Add(1)
+				log.Errorf("Scrape %s: %s", target, err)
+			}
+		}()
+	}
+	targets.Wait()
+}
+
+// Scrape запрашивает метрики одного агента и записывает их в хранилище.
+// Некорректные метрики и метрики сверх лимита рядов пропускаются, а база
+// пропущенных счетчиков не сдвигается
+func (s *Scraper) Scrape(ctx context.Context, target string) error {
+	batch, err := s.fetch(ctx, target)
+	if err != nil {
+		return err
+	}
+
+	ctx = tenant.WithTenant(ctx, s.options.Tenant)
+	data, totals := s.deltas(target, batch)
+	accepted := make([]metrics.Metric, 0, len(data))
+	var rejected []metrics.Metric
+	var reservations cardinality.Reservations
+	for _, m := range data {
+		if err := s.validator.Metric(m); err != nil {
+			log.Warn(err)
+			rejected = append(rejected, m)
+			continue
+		}
+		reservation, err := s.series.Reserve(ctx, m.MType, m.ID)
+		if err != nil {
+			log.Warn(err)
+			rejected = append(rejected, m)
+			continue
+		}
+		reservations = append(reservations, reservation)
+		accepted = append(accepted, m)
+	}
+	if len(accepted) > 0 {
+		if err := s.store.SetBatch(ctx, accepted); err != nil {
+			// база счетчиков не сдвигается, и приращения будут записаны следующим опросом
+			reservations.Release()
+			return err
+		}
+		reservations.Commit()
+		s.record(ctx, target, accepted)
+	}
+	s.commit(target, totals, rejected)
+	return nil
+}
+
+// record добавляет в журнал аудита запись метрик агента target
+func (s *Scraper) record(ctx context.Context, target string, batch []metrics.Metric) {
+	if s.audit == nil {
+		return
+	}
+	ids := make([]string, 0, len(batch))
+	for _, m := range batch {
+		ids = append(ids, m.ID)
+	}
+	ev := audit.NewEvent(ctx, audit.TransportScrape, ids)
+	ev.Identity = target
+	s.audit.Record(ev)
+}
+
+// fetch загружает JSON массив метрик агента
+func (s *Scraper) fetch(ctx context.Context, target string) ([]metrics.Metric, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.options.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.options.Token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	var batch []metrics.Metric
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&batch); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// deltas заменяет накопленные значения счетчиков агента их приращением с прошлого опроса
+// и возвращает новые накопленные значения, которые запоминаются commit после записи.
+// Если значение уменьшилось, агент перезапущен, и приращением считается само значение.
+// При первом опросе агента значения запоминаются сразу: приращений в нем нет
+func (s *Scraper) deltas(target string, batch []metrics.Metric) ([]metrics.Metric, map[string]int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	last, seen := s.counters[target]
+	current := make(map[string]int64)
+	result := make([]metrics.Metric, 0, len(batch))
+	for _, m := range batch {
+		if m.MType != "counter" || m.Delta == nil {
+			result = append(result, m)
+			continue
+		}
+		total := *m.Delta
+		current[m.ID] = total
+		if !seen {
+			continue
+		}
+
+		delta := total
+		if prev, ok := last[m.ID]; ok && total >= prev {
+			delta = total - prev
+		}
+		if delta != 0 {
+			result = append(result, metrics.Metric{ID: m.ID, MType: m.MType, Delta: &delta})
+		}
+	}
+	if !seen {
+		s.counters[target] = current
+	}
+	return result, current
+}
+
+// commit запоминает накопленные значения счетчиков агента после их записи.
+// Для отклоненных счетчиков сохраняется прежняя база
+func (s *Scraper) commit(target string, totals map[string]int64, rejected []metrics.Metric) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	last := s.counters[target]
+	for _, m := range rejected {
+		if m.MType != "counter" {
+			continue
+		}
+		if prev, ok := last[m.ID]; ok {
+			totals[m.ID] = prev
+		} else {
+			delete(totals, m.ID)
+		}
+	}
+	s.counters[target] = totals
+}
@@ -0,0 +1,238 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/audit"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/cardinality"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/metrics"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/storage"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/tenant"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	require.Nil(t, New(storage.NewMemStorage(""), Options{}))
+	require.Equal(t, int64(0), (*Scraper)(nil).Failures())
+}
+
+func TestScrape(t *testing.T) {
+	responses := []string{
+		`[{"id":"PollCount","type":"counter","delta":10},{"id":"Alloc","type":"gauge","value":1.5}]`,
+		`[{"id":"PollCount","type":"counter","delta":14},{"id":"Alloc","type":"gauge","value":2},{"id":"Errors","type":"counter","delta":2}]`,
+		// Агент перезапущен
+		`[{"id":"PollCount","type":"counter","delta":3},{"id":"bad name!","type":"gauge","value":1}]`,
+	}
+	var calls atomic.Int32
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		w.Write([]byte(responses[calls.Add(1)-1]))
+	}))
+	defer agent.Close()
+
+	store := storage.NewMemStorage("")
+	s := New(store, Options{Targets: []string{agent.URL}, Tenant: "edge", Token: "secret"})
+	ctx := tenant.WithTenant(context.Background(), "edge")
+
+	require.NoError(t, s.Scrape(context.Background(), agent.URL))
+	gauge, err := store.GetGauge(ctx, "Alloc")
+	require.NoError(t, err)
+	require.Equal(t, 1.5, gauge)
+	_, err = store.GetCounter(ctx, "PollCount")
+	require.Error(t, err, "first scrape only remembers counter totals")
+
+	require.NoError(t, s.Scrape(context.Background(), agent.URL))
+	counter, err := store.GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	require.Equal(t, int64(4), counter)
+	counter, err = store.GetCounter(ctx, "Errors")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), counter)
+
+	require.NoError(t, s.Scrape(context.Background(), agent.URL))
+	counter, err = store.GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	require.Equal(t, int64(7), counter)
+	_, err = store.GetGauge(ctx, "bad name!")
+	require.Error(t, err)
+
+	_, err = store.GetGauge(context.Background(), "Alloc")
+	require.Error(t, err, "metrics are written to the configured tenant")
+}
+
+func TestScrapeAudit(t *testing.T) {
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"id":"Alloc","type":"gauge","value":1},{"id":"bad name!","type":"gauge","value":1}]`))
+	}))
+	defer agent.Close()
+
+	var buf bytes.Buffer
+	auditor := audit.New(audit.NewWriterSink(&buf), 10)
+	s := New(storage.NewMemStorage(""), Options{Targets: []string{agent.URL}, Tenant: "edge"})
+	s.SetAuditor(auditor)
+
+	require.NoError(t, s.Scrape(context.Background(), agent.URL))
+	require.NoError(t, auditor.Close())
+
+	var ev audit.Event
+	require.NoError(t, json.NewDecoder(&buf).Decode(&ev))
+	require.Equal(t, audit.TransportScrape, ev.Transport)
+	require.Equal(t, agent.URL, ev.Identity)
+	require.Equal(t, "edge", ev.Tenant)
+	require.Equal(t, []string{"Alloc"}, ev.Metrics)
+}
+
+func TestScrapeCardinality(t *testing.T) {
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"id":"A","type":"gauge","value":1},{"id":"B","type":"gauge","value":2}]`))
+	}))
+	defer agent.Close()
+
+	store := storage.NewMemStorage("")
+	s := New(store, Options{Targets: []string{agent.URL}})
+	s.SetCardinality(cardinality.New(cardinality.Limits{MaxSeries: 1}))
+
+	require.NoError(t, s.Scrape(context.Background(), agent.URL))
+	_, err := store.GetGauge(context.Background(), "A")
+	require.NoError(t, err)
+	_, err = store.GetGauge(context.Background(), "B")
+	require.Error(t, err)
+}
+
+func TestScrapeRejectedCounterKeepsBase(t *testing.T) {
+	responses := []string{
+		`[{"id":"A","type":"gauge","value":1},{"id":"C","type":"counter","delta":10}]`,
+		`[{"id":"A","type":"gauge","value":1},{"id":"C","type":"counter","delta":15}]`,
+	}
+	var calls atomic.Int32
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(responses[calls.Add(1)-1]))
+	}))
+	defer agent.Close()
+
+	store := storage.NewMemStorage("")
+	s := New(store, Options{Targets: []string{agent.URL}})
+	s.SetCardinality(cardinality.New(cardinality.Limits{MaxSeries: 1}))
+
+	require.NoError(t, s.Scrape(context.Background(), agent.URL))
+	require.NoError(t, s.Scrape(context.Background(), agent.URL))
+	_, err := store.GetCounter(context.Background(), "C")
+	require.Error(t, err)
+	require.Equal(t, int64(10), s.counters[agent.URL]["C"], "rejected counter keeps its previous base")
+}
+
+func TestRunScrapesAtStartup(t *testing.T) {
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"id":"A","type":"gauge","value":1}]`))
+	}))
+	defer agent.Close()
+
+	store := storage.NewMemStorage("")
+	s := New(store, Options{Targets: []string{agent.URL}, Interval: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go s.Run(ctx, &wg)
+
+	require.Eventually(t, func() bool {
+		_, err := store.GetGauge(context.Background(), "A")
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+	cancel()
+	wg.Wait()
+}
+
+// failingStorage отклоняет первые fail записей
+type failingStorage struct {
+	*storage.MemStorage
+	fail int
+}
+
+func (s *failingStorage) SetBatch(ctx context.Context, batch []metrics.Metric) error {
+	if s.fail > 0 {
+		s.fail--
+		return storage.ErrUnavailable
+	}
+	return s.MemStorage.SetBatch(ctx, batch)
+}
+
+func TestScrapeCardinalityStorageError(t *testing.T) {
+	responses := []string{
+		`[{"id":"A","type":"gauge","value":1}]`,
+		`[{"id":"B","type":"gauge","value":2}]`,
+	}
+	var calls atomic.Int32
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(responses[calls.Add(1)-1]))
+	}))
+	defer agent.Close()
+
+	store := &failingStorage{MemStorage: storage.NewMemStorage(""), fail: 1}
+	s := New(store, Options{Targets: []string{agent.URL}})
+	s.SetCardinality(cardinality.New(cardinality.Limits{MaxSeries: 1}))
+
+	require.ErrorIs(t, s.Scrape(context.Background(), agent.URL), storage.ErrUnavailable)
+
+	// ряд A не записан и не занимает лимит
+	require.NoError(t, s.Scrape(context.Background(), agent.URL))
+	_, err := store.GetGauge(context.Background(), "B")
+	require.NoError(t, err)
+}
+
+func TestScrapeStorageErrorKeepsDeltas(t *testing.T) {
+	responses := []string{
+		`[{"id":"PollCount","type":"counter","delta":10}]`,
+		`[{"id":"PollCount","type":"counter","delta":14}]`,
+		`[{"id":"PollCount","type":"counter","delta":15}]`,
+	}
+	var calls atomic.Int32
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(responses[calls.Add(1)-1]))
+	}))
+	defer agent.Close()
+
+	store := &failingStorage{MemStorage: storage.NewMemStorage("")}
+	s := New(store, Options{Targets: []string{agent.URL}})
+
+	require.NoError(t, s.Scrape(context.Background(), agent.URL))
+
+	store.fail = 1
+	require.ErrorIs(t, s.Scrape(context.Background(), agent.URL), storage.ErrUnavailable)
+
+	// приращение неудачного опроса записывается следующим
+	require.NoError(t, s.Scrape(context.Background(), agent.URL))
+	counter, err := store.GetCounter(context.Background(), "PollCount")
+	require.NoError(t, err)
+	require.Equal(t, int64(5), counter)
+}
+
+func TestScrapeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{name: "unauthorized", handler: func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}},
+		{name: "invalid json", handler: func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"id":`))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent := httptest.NewServer(tt.handler)
+			defer agent.Close()
+
+			s := New(storage.NewMemStorage(""), Options{Targets: []string{agent.URL}})
+			require.Error(t, s.Scrape(context.Background(), agent.URL))
+		})
+	}
+}
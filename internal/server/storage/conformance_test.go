@@ -0,0 +1,15 @@
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/handlers"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/storage"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/storage/storagetest"
+)
+
+func TestMemStorageConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) handlers.Storage {
+		return storage.NewMemStorage("")
+	})
+}
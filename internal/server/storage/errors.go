@@ -0,0 +1,15 @@
+package storage
+
+import "errors"
+
+// Ошибки хранилища, по которым обработчики определяют код ответа
+var (
+	// ErrNotFound метрика с указанным именем и типом отсутствует
+	ErrNotFound = errors.New("metric not found")
+	// ErrTypeMismatch тип метрики не поддерживается или не совпадает с сохраненным
+	ErrTypeMismatch = errors.New("unexpected type of metric")
+	// ErrInvalidValue значение метрики отсутствует или некорректно
+	ErrInvalidValue = errors.New("invalid value of metric")
+	// ErrUnavailable хранилище временно недоступно
+	ErrUnavailable = errors.New("storage unavailable")
+)
@@ -4,20 +4,37 @@ package storage
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"os"
 	"strconv"
 	"sync"
 
 	"github.com/romanmendelproject/go-yandex-metrics/internal/server/metrics"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/tenant"
 )
 
 // MemStorage хранит информацию о метриках
 type MemStorage struct {
-	counter  sync.Map
-	gauge    sync.Map
-	filePath string
+	counterMu sync.Mutex
+	counter   sync.Map
+	gauge     sync.Map
+	filePath  string
+}
+
+// metricKey определяет ключ метрики в пространстве имен тенанта
+type metricKey struct {
+	tenant string
+	name   string
+}
+
+// fileMetric описывает метрику в файле хранилища
+type fileMetric struct {
+	Tenant string `json:"tenant,omitempty"`
+	metrics.Metric
+}
+
+func keyFromContext(ctx context.Context, name string) metricKey {
+	return metricKey{tenant: tenant.FromContext(ctx), name: name}
 }
 
 // Value определяет значение метрики
@@ -27,6 +44,13 @@ type Value struct {
 	Value interface{}
 }
 
+// Series определяет временной ряд метрики: ее тенант, тип и имя
+type Series struct {
+	Tenant string
+	Type   string
+	Name   string
+}
+
 // NewMemStorage создает экземпляр объекта MemStorage
 func NewMemStorage(filePath string) *MemStorage {
 	return &MemStorage{
@@ -36,28 +60,28 @@ func NewMemStorage(filePath string) *MemStorage {
 
 // SetGauge записывает в БД метрики типа Gauge
 func (m *MemStorage) SetGauge(ctx context.Context, name string, value float64) error {
-	m.gauge.Store(name, value)
+	m.gauge.Store(keyFromContext(ctx, name), value)
 	return nil
 }
 
 // SetCounter записывает в БД метрики типа Counter
 func (m *MemStorage) SetCounter(ctx context.Context, name string, value int64) error {
-	if _, err := m.GetCounter(ctx, name); err != nil {
-		m.counter.Store(name, value)
-	} else {
-		valueOld, ok := m.counter.Load(name)
-		if ok {
-			m.counter.Store(name, value+valueOld.(int64))
-		}
+	m.counterMu.Lock()
+	defer m.counterMu.Unlock()
+
+	key := keyFromContext(ctx, name)
+	if valueOld, ok := m.counter.Load(key); ok {
+		value += valueOld.(int64)
 	}
+	m.counter.Store(key, value)
 	return nil
 }
 
 // GetCounter получает из БД метрики типа Counter
 func (m *MemStorage) GetCounter(ctx context.Context, name string) (int64, error) {
-	value, ok := m.counter.Load(name)
+	value, ok := m.counter.Load(keyFromContext(ctx, name))
 	if !ok {
-		return 0, errors.New("invalid name of metrics")
+		return 0, fmt.Errorf("%w: %s", ErrNotFound, name)
 	}
 
 	return value.(int64), nil
@@ -65,9 +89,9 @@ func (m *MemStorage) GetCounter(ctx context.Context, name string) (int64, error)
 
 // GetGauge получает из БД метрики типа Gauge
 func (m *MemStorage) GetGauge(ctx context.Context, name string) (float64, error) {
-	value, ok := m.gauge.Load(name)
+	value, ok := m.gauge.Load(keyFromContext(ctx, name))
 	if !ok {
-		return 0, errors.New("invalid name of metrics")
+		return 0, fmt.Errorf("%w: %s", ErrNotFound, name)
 	}
 
 	return value.(float64), nil
@@ -76,28 +100,48 @@ func (m *MemStorage) GetGauge(ctx context.Context, name string) (float64, error)
 // GetAll получает полный набор метрик из БД
 func (m *MemStorage) GetAll(ctx context.Context) ([]Value, error) {
 	var values []Value
+	tenantName := tenant.FromContext(ctx)
 
 	m.gauge.Range(func(k, v interface{}) bool {
-		values = append(values, Value{
-			Name:  k.(string),
-			Type:  "gauge",
-			Value: strconv.FormatFloat(v.(float64), 'f', 1, 64),
-		})
+		if key := k.(metricKey); key.tenant == tenantName {
+			values = append(values, Value{
+				Name:  key.name,
+				Type:  "gauge",
+				Value: strconv.FormatFloat(v.(float64), 'f', 1, 64),
+			})
+		}
 		return true
 	})
 
 	m.counter.Range(func(k, v interface{}) bool {
-		values = append(values, Value{
-			Name:  k.(string),
-			Type:  "counter",
-			Value: v.(int64),
-		})
+		if key := k.(metricKey); key.tenant == tenantName {
+			values = append(values, Value{
+				Name:  key.name,
+				Type:  "counter",
+				Value: v.(int64),
+			})
+		}
 		return true
 	})
-	fmt.Println(values)
 	return values, nil
 }
 
+// ListSeries возвращает ряды метрик всех тенантов
+func (m *MemStorage) ListSeries(ctx context.Context) ([]Series, error) {
+	var series []Series
+	m.gauge.Range(func(k, v interface{}) bool {
+		key := k.(metricKey)
+		series = append(series, Series{Tenant: key.tenant, Type: "gauge", Name: key.name})
+		return true
+	})
+	m.counter.Range(func(k, v interface{}) bool {
+		key := k.(metricKey)
+		series = append(series, Series{Tenant: key.tenant, Type: "counter", Name: key.name})
+		return true
+	})
+	return series, nil
+}
+
 // SaveToFile сохраняет данные с метриками в файл
 func (m *MemStorage) SaveToFile() error {
 
@@ -129,7 +173,7 @@ func (m *MemStorage) RestoreFromFile() error {
 		return err
 	}
 
-	metricSlice := make([]metrics.Metric, 0)
+	metricSlice := make([]fileMetric, 0)
 
 	err = json.Unmarshal(file, &metricSlice)
 	if err != nil {
@@ -137,11 +181,15 @@ func (m *MemStorage) RestoreFromFile() error {
 	}
 
 	for _, metric := range metricSlice {
-		switch metric.MType {
-		case "gauge":
-			m.gauge.Store(metric.ID, *metric.Value)
-		case "counter":
-			m.counter.Store(metric.ID, *metric.Value)
+		key := metricKey{tenant: metric.Tenant, name: metric.ID}
+		if key.tenant == "" {
+			key.tenant = tenant.Default
+		}
+		switch {
+		case metric.MType == "gauge" && metric.Value != nil:
+			m.gauge.Store(key, *metric.Value)
+		case metric.MType == "counter" && metric.Delta != nil:
+			m.counter.Store(key, *metric.Delta)
 		}
 	}
 
@@ -149,11 +197,12 @@ func (m *MemStorage) RestoreFromFile() error {
 }
 
 func toJSON(m *MemStorage) ([]byte, error) {
-	metric := make([]metrics.Metric, 0)
+	metric := make([]fileMetric, 0)
 
 	m.gauge.Range(func(k, v interface{}) bool {
-		var m metrics.Metric
-		m.ID = k.(string)
+		var m fileMetric
+		m.Tenant = k.(metricKey).tenant
+		m.ID = k.(metricKey).name
 		m.MType = "gauge"
 		newValue := v.(float64)
 		m.Value = &newValue
@@ -162,8 +211,9 @@ func toJSON(m *MemStorage) ([]byte, error) {
 	})
 
 	m.counter.Range(func(k, v interface{}) bool {
-		var m metrics.Metric
-		m.ID = k.(string)
+		var m fileMetric
+		m.Tenant = k.(metricKey).tenant
+		m.ID = k.(metricKey).name
 		m.MType = "counter"
 		newDelta := v.(int64)
 		m.Delta = &newDelta
@@ -181,5 +231,23 @@ func (m *MemStorage) Ping(ctx context.Context) error {
 
 // SetBatch обновляет все метрки в БД за один запрос
 func (m *MemStorage) SetBatch(ctx context.Context, metrics []metrics.Metric) error {
+	for _, metric := range metrics {
+		switch {
+		case metric.MType == "gauge" && metric.Value == nil,
+			metric.MType == "counter" && metric.Delta == nil:
+			return fmt.Errorf("%w: %s", ErrInvalidValue, metric.ID)
+		case metric.MType != "gauge" && metric.MType != "counter":
+			return fmt.Errorf("%w: %s", ErrTypeMismatch, metric.ID)
+		}
+	}
+
+	for _, metric := range metrics {
+		switch metric.MType {
+		case "gauge":
+			m.SetGauge(ctx, metric.ID, *metric.Value)
+		case "counter":
+			m.SetCounter(ctx, metric.ID, *metric.Delta)
+		}
+	}
 	return nil
 }
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/romanmendelproject/go-yandex-metrics/internal/server/metrics"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/tenant"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -34,7 +35,7 @@ func TestMemStorage_SetGauge(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			storage.SetGauge(ctx, tt.args.name, tt.args.value)
-			v, ok := storage.gauge.Load("Test")
+			v, ok := storage.gauge.Load(metricKey{tenant.Default, "Test"})
 			if ok {
 				require.Equal(t, v, tt.want)
 			}
@@ -69,7 +70,7 @@ func TestMemStorage_SetCount(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			storage.SetCounter(ctx, tt.args.name, tt.args.value)
-			v, ok := storage.counter.Load("Test")
+			v, ok := storage.counter.Load(metricKey{tenant.Default, "Test"})
 			if ok {
 				require.Equal(t, v, tt.want)
 			}
@@ -0,0 +1,264 @@
+// Модуль общего набора тестов для реализаций хранилища метрик
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/handlers"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/metrics"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/storage"
+	"github.com/romanmendelproject/go-yandex-metrics/internal/tenant"
+	"github.com/romanmendelproject/go-yandex-metrics/utils"
+	"github.com/stretchr/testify/require"
+)
+
+// Factory создает пустое хранилище для очередного теста
+type Factory func(t *testing.T) handlers.Storage
+
+// Run проверяет соответствие хранилища контракту handlers.Storage
+func Run(t *testing.T, newStorage Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s handlers.Storage)
+	}{
+		{"Ping", testPing},
+		{"GaugeSetGet", testGaugeSetGet},
+		{"GaugeOverwrite", testGaugeOverwrite},
+		{"CounterAccumulate", testCounterAccumulate},
+		{"MissingMetric", testMissingMetric},
+		{"TypeIsolation", testTypeIsolation},
+		{"Batch", testBatch},
+		{"BatchAccumulate", testBatchAccumulate},
+		{"BatchInvalid", testBatchInvalid},
+		{"GetAll", testGetAll},
+		{"TenantIsolation", testTenantIsolation},
+		{"ConcurrentCounter", testConcurrentCounter},
+		{"ConcurrentBatch", testConcurrentBatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStorage(t))
+		})
+	}
+}
+
+func testPing(t *testing.T, s handlers.Storage) {
+	require.NoError(t, s.Ping(context.Background()))
+}
+
+func testGaugeSetGet(t *testing.T, s handlers.Storage) {
+	ctx := context.Background()
+
+	require.NoError(t, s.SetGauge(ctx, "Alloc", 0.5))
+
+	value, err := s.GetGauge(ctx, "Alloc")
+	require.NoError(t, err)
+	require.Equal(t, 0.5, value)
+}
+
+func testGaugeOverwrite(t *testing.T, s handlers.Storage) {
+	ctx := context.Background()
+
+	require.NoError(t, s.SetGauge(ctx, "Alloc", 1))
+	require.NoError(t, s.SetGauge(ctx, "Alloc", 2.5))
+
+	value, err := s.GetGauge(ctx, "Alloc")
+	require.NoError(t, err)
+	require.Equal(t, 2.5, value)
+}
+
+func testCounterAccumulate(t *testing.T, s handlers.Storage) {
+	ctx := context.Background()
+
+	for _, delta := range []int64{1, 2, 3} {
+		require.NoError(t, s.SetCounter(ctx, "PollCount", delta))
+	}
+
+	value, err := s.GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	require.Equal(t, int64(6), value)
+}
+
+func testMissingMetric(t *testing.T, s handlers.Storage) {
+	ctx := context.Background()
+
+	_, err := s.GetGauge(ctx, "Unknown")
+	require.ErrorIs(t, err, storage.ErrNotFound)
+
+	_, err = s.GetCounter(ctx, "Unknown")
+	require.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func testTypeIsolation(t *testing.T, s handlers.Storage) {
+	ctx := context.Background()
+
+	require.NoError(t, s.SetGauge(ctx, "Gauge", 1))
+	require.NoError(t, s.SetCounter(ctx, "Counter", 1))
+
+	_, err := s.GetCounter(ctx, "Gauge")
+	require.ErrorIs(t, err, storage.ErrNotFound)
+
+	_, err = s.GetGauge(ctx, "Counter")
+	require.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func testBatch(t *testing.T, s handlers.Storage) {
+	ctx := context.Background()
+
+	batch := []metrics.Metric{
+		{ID: "Alloc", MType: "gauge", Value: utils.GetFloatPtr(1.5)},
+		{ID: "PollCount", MType: "counter", Delta: utils.ToPointer(int64(3))},
+	}
+	require.NoError(t, s.SetBatch(ctx, batch))
+
+	gauge, err := s.GetGauge(ctx, "Alloc")
+	require.NoError(t, err)
+	require.Equal(t, 1.5, gauge)
+
+	counter, err := s.GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	require.Equal(t, int64(3), counter)
+}
+
+func testBatchAccumulate(t *testing.T, s handlers.Storage) {
+	ctx := context.Background()
+
+	require.NoError(t, s.SetCounter(ctx, "PollCount", 10))
+
+	batch := []metrics.Metric{
+		{ID: "PollCount", MType: "counter", Delta: utils.ToPointer(int64(1))},
+		{ID: "PollCount", MType: "counter", Delta: utils.ToPointer(int64(2))},
+		{ID: "Alloc", MType: "gauge", Value: utils.GetFloatPtr(1)},
+		{ID: "Alloc", MType: "gauge", Value: utils.GetFloatPtr(2)},
+	}
+	require.NoError(t, s.SetBatch(ctx, batch))
+
+	counter, err := s.GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	require.Equal(t, int64(13), counter)
+
+	gauge, err := s.GetGauge(ctx, "Alloc")
+	require.NoError(t, err)
+	require.Equal(t, float64(2), gauge)
+}
+
+func testBatchInvalid(t *testing.T, s handlers.Storage) {
+	ctx := context.Background()
+
+	err := s.SetBatch(ctx, []metrics.Metric{
+		{ID: "Alloc", MType: "gauge", Value: utils.GetFloatPtr(1)},
+		{ID: "Alloc", MType: "histogram", Value: utils.GetFloatPtr(1)},
+	})
+	require.ErrorIs(t, err, storage.ErrTypeMismatch)
+
+	err = s.SetBatch(ctx, []metrics.Metric{{ID: "PollCount", MType: "counter"}})
+	require.ErrorIs(t, err, storage.ErrInvalidValue)
+
+	_, err = s.GetGauge(ctx, "Alloc")
+	require.ErrorIs(t, err, storage.ErrNotFound, "rejected batch must not be applied partially")
+}
+
+func testGetAll(t *testing.T, s handlers.Storage) {
+	ctx := context.Background()
+
+	require.NoError(t, s.SetGauge(ctx, "Alloc", 1))
+	require.NoError(t, s.SetCounter(ctx, "PollCount", 1))
+
+	values, err := s.GetAll(ctx)
+	require.NoError(t, err)
+
+	got := make(map[string]string)
+	for _, v := range values {
+		got[v.Name] = v.Type
+	}
+	require.Equal(t, map[string]string{"Alloc": "gauge", "PollCount": "counter"}, got)
+}
+
+func testTenantIsolation(t *testing.T, s handlers.Storage) {
+	ctxA := tenant.WithTenant(context.Background(), "payments")
+	ctxB := tenant.WithTenant(context.Background(), "infra")
+
+	require.NoError(t, s.SetGauge(ctxA, "Alloc", 1))
+	require.NoError(t, s.SetCounter(ctxA, "PollCount", 1))
+	require.NoError(t, s.SetBatch(ctxB, []metrics.Metric{
+		{ID: "PollCount", MType: "counter", Delta: utils.ToPointer(int64(5))},
+	}))
+
+	_, err := s.GetGauge(ctxB, "Alloc")
+	require.ErrorIs(t, err, storage.ErrNotFound)
+
+	_, err = s.GetGauge(context.Background(), "Alloc")
+	require.ErrorIs(t, err, storage.ErrNotFound)
+
+	counter, err := s.GetCounter(ctxA, "PollCount")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), counter)
+
+	counter, err = s.GetCounter(ctxB, "PollCount")
+	require.NoError(t, err)
+	require.Equal(t, int64(5), counter)
+
+	values, err := s.GetAll(ctxB)
+	require.NoError(t, err)
+	require.Len(t, values, 1)
+	require.Equal(t, "PollCount", values[0].Name)
+}
+
+func testConcurrentCounter(t *testing.T, s handlers.Storage) {
+	const workers, iterations = 8, 25
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				if err := s.SetCounter(ctx, "PollCount", 1); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	value, err := s.GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	require.Equal(t, int64(workers*iterations), value)
+}
+
+func testConcurrentBatch(t *testing.T, s handlers.Storage) {
+	const workers = 8
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			batch := []metrics.Metric{
+				{ID: "PollCount", MType: "counter", Delta: utils.ToPointer(int64(1))},
+				{ID: fmt.Sprintf("Gauge%d", w), MType: "gauge", Value: utils.GetFloatPtr(float64(w))},
+			}
+			if err := s.SetBatch(ctx, batch); err != nil {
+				t.Error(err)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	value, err := s.GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	require.Equal(t, int64(workers), value)
+
+	for w := 0; w < workers; w++ {
+		gauge, err := s.GetGauge(ctx, fmt.Sprintf("Gauge%d", w))
+		require.NoError(t, err)
+		require.Equal(t, float64(w), gauge)
+	}
+}
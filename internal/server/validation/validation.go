@@ -0,0 +1,115 @@
+// Модуль проверки имен и значений метрик перед записью
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/metrics"
+)
+
+// Значения правил по умолчанию. Длина имени ограничена размером колонки в БД
+const (
+	DefaultNamePattern   = `^[A-Za-z0-9_.:-]+$`
+	DefaultMaxNameLength = 128
+)
+
+// ErrInvalidMetric возвращается, если имя или значение метрики не проходит проверку
+var ErrInvalidMetric = errors.New("invalid metric")
+
+// Rules правила проверки метрик
+type Rules struct {
+	NamePattern        string // регулярное выражение для имени, по умолчанию DefaultNamePattern
+	MaxNameLength      int    // максимальная длина имени, по умолчанию DefaultMaxNameLength
+	AllowNonFinite     bool   // разрешить NaN и ±Inf в значениях gauge
+	AllowNegativeDelta bool   // разрешить отрицательное приращение counter
+}
+
+// Validator проверяет метрики по правилам
+type Validator struct {
+	rules Rules
+	name  *regexp.Regexp
+}
+
+// New создает Validator. Незаданные шаблон и длина имени заменяются значениями по умолчанию
+func New(rules Rules) (*Validator, error) {
+	if rules.NamePattern == "" {
+		rules.NamePattern = DefaultNamePattern
+	}
+	if rules.MaxNameLength <= 0 {
+		rules.MaxNameLength = DefaultMaxNameLength
+	}
+	name, err := regexp.Compile(rules.NamePattern)
+	if err != nil {
+		return nil, fmt.Errorf("metric name pattern: %w", err)
+	}
+	return &Validator{rules: rules, name: name}, nil
+}
+
+// Default возвращает Validator с правилами по умолчанию: NaN, Inf запрещены,
+// отрицательные приращения счетчиков разрешены
+func Default() *Validator {
+	v, _ := New(Rules{AllowNegativeDelta: true})
+	return v
+}
+
+// Name проверяет имя метрики
+func (v *Validator) Name(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("%w: empty name", ErrInvalidMetric)
+	case len(name) > v.rules.MaxNameLength:
+		return fmt.Errorf("%w %.32q...: name is longer than %d characters", ErrInvalidMetric, name, v.rules.MaxNameLength)
+	case !v.name.MatchString(name):
+		return fmt.Errorf("%w %q: name does not match %s", ErrInvalidMetric, name, v.rules.NamePattern)
+	}
+	return nil
+}
+
+// Gauge проверяет имя и значение метрики типа gauge
+func (v *Validator) Gauge(name string, value float64) error {
+	if err := v.Name(name); err != nil {
+		return err
+	}
+	if !v.rules.AllowNonFinite && (math.IsNaN(value) || math.IsInf(value, 0)) {
+		return fmt.Errorf("%w %q: value %v is not a finite number", ErrInvalidMetric, name, value)
+	}
+	return nil
+}
+
+// Counter проверяет имя и приращение метрики типа counter
+func (v *Validator) Counter(name string, delta int64) error {
+	if err := v.Name(name); err != nil {
+		return err
+	}
+	if !v.rules.AllowNegativeDelta && delta < 0 {
+		return fmt.Errorf("%w %q: negative delta %d", ErrInvalidMetric, name, delta)
+	}
+	return nil
+}
+
+// Metric проверяет метрику по ее типу. Метрики без значения и неизвестного типа
+// проверяет хранилище, здесь для них проверяется только имя
+func (v *Validator) Metric(m metrics.Metric) error {
+	switch {
+	case m.MType == "gauge" && m.Value != nil:
+		return v.Gauge(m.ID, *m.Value)
+	case m.MType == "counter" && m.Delta != nil:
+		return v.Counter(m.ID, *m.Delta)
+	default:
+		return v.Name(m.ID)
+	}
+}
+
+// Batch проверяет все метрики пачки и возвращает ошибки по каждой некорректной метрике
+func (v *Validator) Batch(batch []metrics.Metric) error {
+	var errs []error
+	for _, m := range batch {
+		if err := v.Metric(m); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
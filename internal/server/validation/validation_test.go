@@ -0,0 +1,74 @@
+package validation
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/romanmendelproject/go-yandex-metrics/internal/server/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestValidator(t *testing.T) {
+	strict, err := New(Rules{MaxNameLength: 16})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		validator *Validator
+		metric    metrics.Metric
+		wantErr   string
+	}{
+		{"valid gauge", Default(), metrics.Metric{ID: "Alloc", MType: "gauge", Value: ptr(1.5)}, ""},
+		{"valid counter", Default(), metrics.Metric{ID: "app.requests:total", MType: "counter", Delta: ptr(int64(1))}, ""},
+		{"empty name", Default(), metrics.Metric{ID: "", MType: "gauge", Value: ptr(1.0)}, "empty name"},
+		{"slash in name", Default(), metrics.Metric{ID: "disk/sda", MType: "gauge", Value: ptr(1.0)}, `"disk/sda": name does not match`},
+		{"space in name", Default(), metrics.Metric{ID: "cpu load", MType: "gauge", Value: ptr(1.0)}, `"cpu load": name does not match`},
+		{"long name", Default(), metrics.Metric{ID: strings.Repeat("a", 129), MType: "gauge", Value: ptr(1.0)}, "longer than 128 characters"},
+		{"custom max length", strict, metrics.Metric{ID: strings.Repeat("a", 17), MType: "gauge", Value: ptr(1.0)}, "longer than 16 characters"},
+		{"NaN", Default(), metrics.Metric{ID: "cpu", MType: "gauge", Value: ptr(math.NaN())}, `"cpu": value NaN is not a finite number`},
+		{"Inf", Default(), metrics.Metric{ID: "cpu", MType: "gauge", Value: ptr(math.Inf(-1))}, "is not a finite number"},
+		{"negative delta allowed", Default(), metrics.Metric{ID: "tx", MType: "counter", Delta: ptr(int64(-1))}, ""},
+		{"negative delta rejected", strict, metrics.Metric{ID: "tx", MType: "counter", Delta: ptr(int64(-1))}, `"tx": negative delta -1`},
+		{"unknown type checks name only", Default(), metrics.Metric{ID: "tx", MType: "histogram"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.validator.Metric(tt.metric)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, ErrInvalidMetric)
+			require.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestAllowNonFinite(t *testing.T) {
+	v, err := New(Rules{AllowNonFinite: true})
+	require.NoError(t, err)
+	require.NoError(t, v.Gauge("cpu", math.Inf(1)))
+}
+
+func TestBatch(t *testing.T) {
+	err := Default().Batch([]metrics.Metric{
+		{ID: "ok", MType: "gauge", Value: ptr(1.0)},
+		{ID: "bad name", MType: "gauge", Value: ptr(1.0)},
+		{ID: "cpu", MType: "gauge", Value: ptr(math.NaN())},
+	})
+	require.ErrorIs(t, err, ErrInvalidMetric)
+	require.Contains(t, err.Error(), `"bad name"`)
+	require.Contains(t, err.Error(), `"cpu"`)
+	require.NotContains(t, err.Error(), `"ok"`)
+}
+
+func TestInvalidPattern(t *testing.T) {
+	_, err := New(Rules{NamePattern: "["})
+	require.Error(t, err)
+}
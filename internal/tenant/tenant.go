@@ -0,0 +1,91 @@
+// Модуль определения пространства имен (тенанта) метрик
+package tenant
+
+import (
+	"context"
+	"errors"
+	"regexp"
+)
+
+// Default тенант, используемый, если клиент не указал свой
+const Default = "default"
+
+// Заголовки HTTP и ключи метаданных gRPC, из которых определяется тенант
+const (
+	HeaderTenant   = "X-Tenant-ID"
+	HeaderAPIKey   = "X-API-Key"
+	MetadataTenant = "x-tenant-id"
+	MetadataAPIKey = "x-api-key"
+)
+
+// Ошибки определения тенанта
+var (
+	ErrUnknownKey  = errors.New("unknown api key")
+	ErrKeyRequired = errors.New("api key required")
+	ErrForbidden   = errors.New("tenant does not match api key")
+	ErrInvalidName = errors.New("invalid tenant name")
+)
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+type ctxKey struct{}
+
+type authenticatedKey struct{}
+
+// WithTenant сохраняет тенант в контексте запроса
+func WithTenant(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, name)
+}
+
+// FromContext возвращает тенант запроса или Default
+func FromContext(ctx context.Context) string {
+	if name, ok := ctx.Value(ctxKey{}).(string); ok && name != "" {
+		return name
+	}
+	return Default
+}
+
+// WithAuthenticated сохраняет в контексте тенант, подтвержденный API ключом
+func WithAuthenticated(ctx context.Context, name string) context.Context {
+	return context.WithValue(WithTenant(ctx, name), authenticatedKey{}, name)
+}
+
+// Authenticated сообщает, подтвержден ли тенант запроса API ключом
+func Authenticated(ctx context.Context) bool {
+	name, ok := ctx.Value(authenticatedKey{}).(string)
+	return ok && name == FromContext(ctx)
+}
+
+// Resolver определяет тенант по API ключу или явно указанному имени
+type Resolver struct {
+	keys map[string]string
+}
+
+// NewResolver создает Resolver. keys сопоставляет API ключ с тенантом; если
+// ключи заданы, запросы без ключа отклоняются. Без ключа доступен только тенант Default
+func NewResolver(keys map[string]string) *Resolver {
+	return &Resolver{keys: keys}
+}
+
+// Resolve возвращает тенант для API ключа apiKey и запрошенного имени name
+func (r *Resolver) Resolve(apiKey, name string) (string, error) {
+	if name != "" && !validName.MatchString(name) {
+		return "", ErrInvalidName
+	}
+
+	if apiKey != "" {
+		keyTenant, ok := r.keys[apiKey]
+		if !ok {
+			return "", ErrUnknownKey
+		}
+		if name != "" && name != keyTenant {
+			return "", ErrForbidden
+		}
+		return keyTenant, nil
+	}
+
+	if len(r.keys) > 0 || (name != "" && name != Default) {
+		return "", ErrKeyRequired
+	}
+	return Default, nil
+}
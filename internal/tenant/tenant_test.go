@@ -0,0 +1,57 @@
+package tenant
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromContext(t *testing.T) {
+	require.Equal(t, Default, FromContext(context.Background()))
+	require.Equal(t, "payments", FromContext(WithTenant(context.Background(), "payments")))
+}
+
+func TestAuthenticated(t *testing.T) {
+	require.False(t, Authenticated(context.Background()))
+	require.False(t, Authenticated(WithTenant(context.Background(), "payments")))
+
+	ctx := WithAuthenticated(context.Background(), "payments")
+	require.True(t, Authenticated(ctx))
+	require.Equal(t, "payments", FromContext(ctx))
+
+	// переопределенный тенант не наследует подтверждение
+	require.False(t, Authenticated(WithTenant(ctx, "infra")))
+}
+
+func TestResolve(t *testing.T) {
+	keys := map[string]string{"secret": "payments"}
+
+	tests := []struct {
+		name    string
+		keys    map[string]string
+		apiKey  string
+		tenant  string
+		want    string
+		wantErr error
+	}{
+		{"default tenant", nil, "", "", Default, nil},
+		{"explicit default tenant", nil, "", Default, Default, nil},
+		{"foreign tenant without key", nil, "", "infra", "", ErrKeyRequired},
+		{"invalid name", nil, "", "in fra/1", "", ErrInvalidName},
+		{"tenant by key", keys, "secret", "", "payments", nil},
+		{"key and matching header", keys, "secret", "payments", "payments", nil},
+		{"key and other header", keys, "secret", "infra", "", ErrForbidden},
+		{"unknown key", keys, "guess", "", "", ErrUnknownKey},
+		{"unknown key without keys", nil, "guess", "infra", "", ErrUnknownKey},
+		{"key required", keys, "", "payments", "", ErrKeyRequired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewResolver(tt.keys).Resolve(tt.apiKey, tt.tenant)
+			require.ErrorIs(t, err, tt.wantErr)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,212 @@
+// Модуль определения адреса клиента и проверки его принадлежности доверенным сетям
+package trusted
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Заголовки с адресом клиента, выставляемые прокси
+const (
+	HeaderForwardedFor = "X-Forwarded-For"
+	HeaderRealIP       = "X-Real-IP"
+)
+
+// ErrInvalidAddr возвращается, если адрес клиента не удалось разобрать
+var ErrInvalidAddr = errors.New("invalid client address")
+
+// Networks набор доверенных сетей IPv4 и IPv6
+type Networks struct {
+	prefixes []netip.Prefix
+}
+
+// ParseList разбирает список сетей через запятую
+func ParseList(list string) (*Networks, error) {
+	var cidrs []string
+	for _, cidr := range strings.Split(list, ",") {
+		if cidr = strings.TrimSpace(cidr); cidr != "" {
+			cidrs = append(cidrs, cidr)
+		}
+	}
+	return NewNetworks(cidrs)
+}
+
+// NewNetworks создает набор сетей из CIDR. Одиночный адрес считается сетью из одного адреса
+func NewNetworks(cidrs []string) (*Networks, error) {
+	n := &Networks{}
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if !strings.Contains(cidr, "/") {
+			addr, err := netip.ParseAddr(cidr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid network %q: %w", cidr, err)
+			}
+			addr = addr.Unmap()
+			n.prefixes = append(n.prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %w", cidr, err)
+		}
+		if prefix.Addr().Is4In6() {
+			if prefix.Bits() < 96 {
+				return nil, fmt.Errorf("invalid network %q: IPv4-mapped prefix shorter than /96", cidr)
+			}
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		n.prefixes = append(n.prefixes, prefix.Masked())
+	}
+	return n, nil
+}
+
+// Empty сообщает, что сети не заданы
+func (n *Networks) Empty() bool {
+	return n == nil || len(n.prefixes) == 0
+}
+
+// Contains проверяет, входит ли адрес в одну из сетей
+func (n *Networks) Contains(addr netip.Addr) bool {
+	if n == nil || !addr.IsValid() {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range n.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolver определяет адрес клиента. Заголовкам X-Forwarded-For и X-Real-IP
+// доверяет, только если запрос пришел от доверенного прокси
+type Resolver struct {
+	proxies *Networks
+}
+
+// NewResolver создает Resolver с набором доверенных прокси
+func NewResolver(proxies *Networks) *Resolver {
+	return &Resolver{proxies: proxies}
+}
+
+// Resolve возвращает адрес клиента по адресу соединения remote и заголовкам прокси.
+// X-Forwarded-For просматривается справа налево до первого адреса, не являющегося
+// доверенным прокси
+func (r *Resolver) Resolve(remote netip.Addr, forwardedFor []string, realIP string) (netip.Addr, error) {
+	remote = remote.Unmap()
+	if r == nil || !r.proxies.Contains(remote) {
+		return remote, nil
+	}
+
+	var hops []string
+	for _, value := range forwardedFor {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+
+	if len(hops) > 0 {
+		var client netip.Addr
+		for i := len(hops) - 1; i >= 0; i-- {
+			addr, err := ParseAddr(hops[i])
+			if err != nil {
+				return netip.Addr{}, err
+			}
+			client = addr
+			if !r.proxies.Contains(addr) {
+				break
+			}
+		}
+		return client, nil
+	}
+
+	if realIP != "" {
+		return ParseAddr(realIP)
+	}
+	return remote, nil
+}
+
+// ParseAddr разбирает адрес с портом или без него
+func ParseAddr(value string) (netip.Addr, error) {
+	value = strings.TrimSpace(value)
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		value = host
+	}
+	value = strings.Trim(value, "[]")
+
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("%w: %q", ErrInvalidAddr, value)
+	}
+	return addr.Unmap(), nil
+}
+
+// Access пропускает только клиентов из доверенных сетей
+type Access struct {
+	subnets  *Networks
+	resolver *Resolver
+}
+
+// NewAccess создает Access по спискам доверенных сетей и прокси через запятую.
+// Пустой список сетей отключает проверку
+func NewAccess(subnets, proxies string) (*Access, error) {
+	subnetList, err := ParseList(subnets)
+	if err != nil {
+		return nil, err
+	}
+	proxyList, err := ParseList(proxies)
+	if err != nil {
+		return nil, err
+	}
+	return &Access{subnets: subnetList, resolver: NewResolver(proxyList)}, nil
+}
+
+// Enabled сообщает, включена ли проверка
+func (a *Access) Enabled() bool {
+	return a != nil && !a.subnets.Empty()
+}
+
+// Allow определяет адрес клиента и проверяет, что он входит в доверенные сети.
+// Если проверка отключена, разрешен любой клиент
+func (a *Access) Allow(remote netip.Addr, forwardedFor []string, realIP string) (netip.Addr, bool, error) {
+	client, err := a.Resolver().Resolve(remote, forwardedFor, realIP)
+	if err != nil {
+		return netip.Addr{}, false, err
+	}
+	return client, !a.Enabled() || a.subnets.Contains(client), nil
+}
+
+// AllowRequest проверяет клиента HTTP запроса
+func (a *Access) AllowRequest(req *http.Request) (netip.Addr, bool, error) {
+	remote, err := ParseAddr(req.RemoteAddr)
+	if err != nil {
+		return netip.Addr{}, false, err
+	}
+	return a.Allow(remote, req.Header.Values(HeaderForwardedFor), req.Header.Get(HeaderRealIP))
+}
+
+// Resolver возвращает Resolver с доверенными прокси. nil Resolver не доверяет заголовкам прокси
+func (a *Access) Resolver() *Resolver {
+	if a == nil {
+		return nil
+	}
+	return a.resolver
+}
+
+type ctxKey struct{}
+
+// WithClient сохраняет адрес клиента в контексте запроса
+func WithClient(ctx context.Context, client netip.Addr) context.Context {
+	return context.WithValue(ctx, ctxKey{}, client)
+}
+
+// ClientFromContext возвращает адрес клиента, определенный при проверке доступа
+func ClientFromContext(ctx context.Context) (netip.Addr, bool) {
+	client, ok := ctx.Value(ctxKey{}).(netip.Addr)
+	return client, ok
+}
@@ -0,0 +1,83 @@
+package trusted
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetworks(t *testing.T) {
+	networks, err := ParseList("192.168.1.0/24, 2001:db8::/32,10.0.0.1")
+	require.NoError(t, err)
+
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"192.168.1.200", true},
+		{"192.168.2.1", false},
+		{"::ffff:192.168.1.5", true},
+		{"2001:db8:1::1", true},
+		{"2001:db9::1", false},
+		{"10.0.0.1", true},
+		{"10.0.0.2", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			require.Equal(t, tt.want, networks.Contains(netip.MustParseAddr(tt.addr)))
+		})
+	}
+
+	_, err = ParseList("192.168.1.0/33")
+	require.Error(t, err)
+	_, err = ParseList("localhost")
+	require.Error(t, err)
+	_, err = ParseList("::ffff:0.0.0.0/95")
+	require.Error(t, err)
+
+	mapped, err := ParseList("::ffff:172.16.0.0/108")
+	require.NoError(t, err)
+	require.True(t, mapped.Contains(netip.MustParseAddr("172.16.1.1")))
+	require.False(t, mapped.Contains(netip.MustParseAddr("172.32.0.1")))
+
+	empty, err := ParseList("")
+	require.NoError(t, err)
+	require.True(t, empty.Empty())
+}
+
+func TestResolve(t *testing.T) {
+	proxies, err := ParseList("10.0.0.0/8")
+	require.NoError(t, err)
+	resolver := NewResolver(proxies)
+
+	tests := []struct {
+		name         string
+		remote       string
+		forwardedFor []string
+		realIP       string
+		want         string
+		wantErr      bool
+	}{
+		{"direct client ignores headers", "203.0.113.5", []string{"192.168.1.1"}, "192.168.1.1", "203.0.113.5", false},
+		{"proxy without headers", "10.0.0.1", nil, "", "10.0.0.1", false},
+		{"proxy with real ip", "10.0.0.1", nil, "192.168.1.1", "192.168.1.1", false},
+		{"rightmost untrusted hop", "10.0.0.1", []string{"1.1.1.1, 192.168.1.1", "10.0.0.2"}, "", "192.168.1.1", false},
+		{"all hops are proxies", "10.0.0.1", []string{"10.0.0.3, 10.0.0.2"}, "", "10.0.0.3", false},
+		{"ipv6 hop with port", "10.0.0.1", []string{"[2001:db8::1]:443"}, "", "2001:db8::1", false},
+		{"invalid hop", "10.0.0.1", []string{"unknown"}, "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolver.Resolve(netip.MustParseAddr(tt.remote), tt.forwardedFor, tt.realIP)
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrInvalidAddr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got.String())
+		})
+	}
+}
@@ -3,9 +3,11 @@ package utils
 import (
 	"errors"
 	"net"
+	"net/netip"
 	"strconv"
 	"strings"
 
+	"github.com/romanmendelproject/go-yandex-metrics/internal/trusted"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -70,24 +72,19 @@ func GetIP() string {
 	return resIP
 }
 
-// ISinTrustedNetwork - проверяем находится ли IP адрес в диапазоне доверенной сети
+// ISinTrustedNetwork - проверяем находится ли IP адрес в диапазоне доверенных сетей cidr (через запятую)
 func ISinTrustedNetwork(checkIP, cidr string) bool {
-	mask := strings.Split(cidr, "/")
-	subnetBIT := StringToInt(mask[1])
-	if mask[0] != "" && subnetBIT == 0 {
+	networks, err := trusted.ParseList(cidr)
+	if err != nil {
+		log.Errorf("Error service.ISinTrustedNetwork: %s", err)
 		return false
 	}
-	ip := net.ParseIP(checkIP)
-	ipNet := net.IPNet{
-		IP:   net.ParseIP(mask[0]),
-		Mask: net.CIDRMask(subnetBIT, 32),
-	}
-	if ipNet.Contains(ip) {
-		log.Info("service.ISinTrustedNetwork", "IP-адрес находится в подсети CIDR")
-	} else {
-		log.Info("service.ISinTrustedNetwork", "IP-адрес НЕ находится в подсети CIDR")
+	ip, err := netip.ParseAddr(strings.TrimSpace(checkIP))
+	if err != nil {
+		log.Errorf("Error service.ISinTrustedNetwork: %s", err)
+		return false
 	}
-	return ipNet.Contains(ip)
+	return networks.Contains(ip)
 }
 
 // StringToInt
@@ -161,6 +161,12 @@ func TestISinTrustedNetwork(t *testing.T) {
 			cidr:     "192.168.1.0/33", // Invalid CIDR
 			expected: false,
 		},
+		{
+			name:     "IPv6 in one of CIDR ranges",
+			checkIP:  "::1",
+			cidr:     "127.0.0.1/32,::1/128",
+			expected: true,
+		},
 	}
 
 	for _, tt := range tests {